@@ -0,0 +1,15 @@
+package eventbus
+
+import "time"
+
+type Config struct {
+	Channel           string        `mapstructure:"channel"`           // LISTEN/NOTIFY channel, "event_bus" by default
+	PollInterval      time.Duration `mapstructure:"pollInterval"`      // Time between polls without notifications, defaults to 1s
+	BatchSize         int           `mapstructure:"batchSize"`         // Messages fetched per poll, defaults to 100
+	VisibilityTimeout time.Duration `mapstructure:"visibilityTimeout"` // How long a claimed delivery is hidden from other members, defaults to 30s
+	MaxAttempts       int           `mapstructure:"maxAttempts"`       // Failed attempts after which a delivery is dead, 0 means unlimited
+	InitialBackoff    time.Duration `mapstructure:"initialBackoff"`    // Delay after the first failure, doubled per attempt, defaults to 1s
+	MaxBackoff        time.Duration `mapstructure:"maxBackoff"`        // Upper bound of the retry delay, defaults to 5m
+	Retention         time.Duration `mapstructure:"retention"`         // Messages older than this are purged once handled, 0 keeps them
+	RetentionInterval time.Duration `mapstructure:"retentionInterval"` // How often messages are purged, defaults to 1h
+}
@@ -0,0 +1,366 @@
+// Package eventbus implements a messaging.EventBus on PostgreSQL, for deployments that
+// do not want to run a message broker next to their database.
+//
+// Published events are stored in a table. Consumer groups receive a delivery row per
+// event, which members claim with FOR UPDATE SKIP LOCKED, retry with backoff and delete
+// once handled. Subscribers are woken with LISTEN/NOTIFY and poll as a fallback, so
+// other databases supported by GORM work too, with a single consumer per group.
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/config"
+	"github.com/ebrickdev/ebrick/messaging"
+	"github.com/ebrickdev/extensions/v1/db/postgresql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultChannel           = "event_bus"
+	defaultPollInterval      = time.Second
+	defaultBatchSize         = 100
+	defaultVisibilityTimeout = 30 * time.Second
+	defaultInitialBackoff    = time.Second
+	defaultMaxBackoff        = 5 * time.Minute
+	defaultRetentionInterval = time.Hour
+	errorSleepDuration       = time.Second
+	// defaultShutdownTimeout is how long Close waits for in-flight handlers.
+	defaultShutdownTimeout = 10 * time.Second
+)
+
+var errClosed = errors.New("eventbus is closed")
+
+// Init connects to the configured database, migrates the event bus tables and sets up
+// the default event bus.
+func Init() *PostgresEventBus {
+	var cfg Config
+	err := config.LoadConfigByKey("application", "messaging.postgres", []string{"."}, &cfg, map[string]any{})
+	if err != nil {
+		log.Fatalf("PostgreSQL EventBus: unable to load config: %v", err)
+	}
+	db := postgresql.Init()
+	if err := Migrate(db); err != nil {
+		log.Fatalf("PostgreSQL EventBus: failed to migrate tables: %v", err)
+	}
+	bus, err := NewEventBus(db, &cfg)
+	if err != nil {
+		log.Fatalf("PostgreSQL EventBus: %v", err)
+	}
+	return bus
+}
+
+// PostgresEventBus stores events in database tables. The tables must have been created
+// with Migrate. The database connection is owned by the caller and not closed by Close.
+type PostgresEventBus struct {
+	db       *gorm.DB
+	cfg      Config
+	postgres bool // LISTEN/NOTIFY and SKIP LOCKED are only used on PostgreSQL
+
+	mu     sync.Mutex // Protects closed and subs
+	closed bool
+	subs   map[*Subscription]struct{}
+
+	stop    context.CancelFunc // Stops the listener and the janitor
+	workers sync.WaitGroup
+}
+
+// NewEventBus creates an event bus on db. On PostgreSQL it starts listening for
+// notifications; when Retention is set it purges old messages in the background.
+func NewEventBus(db *gorm.DB, cfg *Config) (*PostgresEventBus, error) {
+	if db == nil {
+		return nil, errors.New("database must not be nil")
+	}
+
+	b := &PostgresEventBus{
+		db:       db,
+		cfg:      *cfg,
+		postgres: db.Dialector.Name() == "postgres",
+		subs:     make(map[*Subscription]struct{}),
+	}
+	if b.cfg.Channel == "" {
+		b.cfg.Channel = defaultChannel
+	}
+	if b.cfg.PollInterval <= 0 {
+		b.cfg.PollInterval = defaultPollInterval
+	}
+	if b.cfg.BatchSize <= 0 {
+		b.cfg.BatchSize = defaultBatchSize
+	}
+	if b.cfg.VisibilityTimeout <= 0 {
+		b.cfg.VisibilityTimeout = defaultVisibilityTimeout
+	}
+	if b.cfg.InitialBackoff <= 0 {
+		b.cfg.InitialBackoff = defaultInitialBackoff
+	}
+	if b.cfg.MaxBackoff <= 0 {
+		b.cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if b.cfg.RetentionInterval <= 0 {
+		b.cfg.RetentionInterval = defaultRetentionInterval
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	b.stop = stop
+	if b.postgres {
+		b.goWork(func() { b.listen(ctx) })
+	}
+	if b.cfg.Retention > 0 {
+		b.goWork(func() { b.runJanitor(ctx) })
+	}
+	return b, nil
+}
+
+// Publish stores the event in its own transaction and wakes the subscribers of topic.
+func (b *PostgresEventBus) Publish(ctx context.Context, topic string, event cloudevents.Event) error {
+	if b.isClosed() {
+		return errClosed
+	}
+	err := b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return b.PublishTx(tx, topic, event)
+	})
+	if err != nil {
+		return err
+	}
+	b.wake(topic)
+	return nil
+}
+
+// PublishTx stores the event within tx, so it is published only if tx commits. The
+// consumer groups registered on topic receive a delivery each, and subscribers are
+// notified when tx commits.
+func (b *PostgresEventBus) PublishTx(tx *gorm.DB, topic string, event cloudevents.Event) error {
+	if topic == "" {
+		return errors.New("topic must not be empty")
+	}
+	if event.Type() == "" || event.ID() == "" {
+		return errors.New("event must have a valid ID and Type")
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	now := time.Now().UTC()
+	msg := Message{Topic: topic, EventID: event.ID(), Payload: payload, CreatedAt: now}
+	if err := tx.Create(&msg).Error; err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	err = tx.Exec(fmt.Sprintf(
+		"INSERT INTO %s (group_name, topic, message_id, attempts, last_error, next_attempt_at, created_at) "+
+			"SELECT group_name, topic, ?, 0, '', ?, ? FROM %s WHERE topic = ?",
+		DeliveriesTableName, GroupsTableName), msg.ID, now, now, topic).Error
+	if err != nil {
+		return fmt.Errorf("failed to create deliveries: %w", err)
+	}
+
+	if b.postgres {
+		if err := tx.Exec("SELECT pg_notify(?, ?)", b.cfg.Channel, topic).Error; err != nil {
+			return fmt.Errorf("failed to notify subscribers: %w", err)
+		}
+	}
+	return nil
+}
+
+// Subscribe registers a handler for the specified topic. Consumer group deliveries
+// are removed once the handler returns; use SubscribeHandler to report failures and
+// have deliveries retried, or to obtain a Subscription handle.
+// Subscriptions created here are stopped by Close or Shutdown.
+func (b *PostgresEventBus) Subscribe(topic string, handler func(ctx context.Context, event cloudevents.Event), options ...messaging.SubscriptionOption) error {
+	_, err := b.SubscribeHandler(topic, func(ctx context.Context, event cloudevents.Event) error {
+		handler(ctx, event)
+		return nil
+	}, WithMessagingOptions(options...))
+	return err
+}
+
+// SubscribeHandler subscribes to a topic with a handler that reports failures.
+//   - With a consumer group (SubscriptionOptions.Group) the group is registered on the
+//     topic and receives every event published from then on, also while no member is
+//     running. Members claim deliveries in batches; a failed delivery is retried with
+//     exponential backoff until MaxAttempts is reached, after which it is kept as dead.
+//     Events are handled in publish order by a single member; with several members
+//     a retried event may be handled after later ones.
+//   - Without a group the subscription tails the messages published after it started.
+//     Handler failures are logged and not retried. Like core NATS this is best effort:
+//     a message committed after a later one by a concurrent transaction may be missed.
+func (b *PostgresEventBus) SubscribeHandler(topic string, handler Handler, options ...SubscriptionOption) (*Subscription, error) {
+	if b.isClosed() {
+		return nil, errClosed
+	}
+	if topic == "" {
+		return nil, errors.New("topic must not be empty")
+	}
+
+	opts := SubscriptionOptions{}
+	for _, o := range options {
+		o(&opts)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &Subscription{
+		topic:   topic,
+		group:   opts.Group,
+		name:    opts.Name,
+		handler: handler,
+		bus:     b,
+		wakeCh:  make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	var err error
+	if sub.group != "" {
+		err = b.registerGroup(ctx, sub.group, topic)
+	} else {
+		sub.lastID, err = b.lastMessageID(ctx, topic)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := b.addSubscription(sub); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go sub.run()
+	if opts.Group != "" {
+		log.Printf("PostgreSQL EventBus: Joining consumer group '%s' on topic '%s'", opts.Group, topic)
+	}
+	if opts.Name != "" {
+		log.Printf("PostgreSQL EventBus: Subscriber '%s'", opts.Name)
+	}
+	return sub, nil
+}
+
+// registerGroup records a consumer group on a topic unless it exists already.
+func (b *PostgresEventBus) registerGroup(ctx context.Context, group, topic string) error {
+	err := b.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&Group{GroupName: group, Topic: topic, CreatedAt: time.Now().UTC()}).Error
+	if err != nil {
+		return fmt.Errorf("failed to register consumer group %s: %w", group, err)
+	}
+	return nil
+}
+
+// DeleteGroup removes a consumer group from a topic together with its pending and
+// dead deliveries. Events published afterwards are no longer kept for the group.
+func (b *PostgresEventBus) DeleteGroup(ctx context.Context, group, topic string) error {
+	return b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_name = ? AND topic = ?", group, topic).Delete(&Group{}).Error; err != nil {
+			return fmt.Errorf("failed to delete consumer group %s: %w", group, err)
+		}
+		if err := tx.Where("group_name = ? AND topic = ?", group, topic).Delete(&Delivery{}).Error; err != nil {
+			return fmt.Errorf("failed to delete deliveries of consumer group %s: %w", group, err)
+		}
+		return nil
+	})
+}
+
+// lastMessageID returns the ID of the latest message of topic, or 0.
+func (b *PostgresEventBus) lastMessageID(ctx context.Context, topic string) (uint64, error) {
+	var id uint64
+	err := b.db.WithContext(ctx).Model(&Message{}).Where("topic = ?", topic).
+		Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the latest message: %w", err)
+	}
+	return id, nil
+}
+
+// wake tells the subscriptions of topic that new messages may be available.
+func (b *PostgresEventBus) wake(topic string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if topic == "" || sub.topic == topic {
+			sub.wake()
+		}
+	}
+}
+
+// Close stops all subscriptions and waits up to 10 seconds for in-flight handlers.
+// Use Shutdown to control the deadline.
+func (b *PostgresEventBus) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	return b.Shutdown(ctx)
+}
+
+// Shutdown stops polling on all subscriptions and waits for in-flight handlers until
+// ctx expires. Handlers that did not finish are reported in a *DrainError; their
+// consumer group deliveries become due again after the visibility timeout.
+func (b *PostgresEventBus) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return errors.New("eventbus is already closed")
+	}
+	b.closed = true
+	subs := make([]*Subscription, 0, len(b.subs))
+	for sub := range b.subs {
+		subs = append(subs, sub)
+	}
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.cancel()
+	}
+	b.stop()
+	b.workers.Wait()
+
+	var drainErr DrainError
+	for _, sub := range subs {
+		var err *DrainError
+		if errors.As(sub.Drain(ctx), &err) {
+			drainErr.Unfinished = append(drainErr.Unfinished, err.Unfinished...)
+		}
+	}
+	if len(drainErr.Unfinished) > 0 {
+		return &drainErr
+	}
+	return nil
+}
+
+func (b *PostgresEventBus) goWork(fn func()) {
+	b.workers.Add(1)
+	go func() {
+		defer b.workers.Done()
+		fn()
+	}()
+}
+
+func (b *PostgresEventBus) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// addSubscription registers a subscription unless the bus is closed.
+func (b *PostgresEventBus) addSubscription(sub *Subscription) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errClosed
+	}
+	b.subs[sub] = struct{}{}
+	return nil
+}
+
+func (b *PostgresEventBus) removeSubscription(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, sub)
+}
@@ -0,0 +1,398 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "eventbus.db")+"?_pragma=busy_timeout(5000)"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	// SQLite does not lock rows, so serialize the claims of concurrent members.
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, Migrate(db))
+	return db
+}
+
+func newTestBus(t *testing.T, db *gorm.DB, cfg Config) *PostgresEventBus {
+	t.Helper()
+
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 20 * time.Millisecond
+	}
+	if cfg.InitialBackoff == 0 {
+		cfg.InitialBackoff = 10 * time.Millisecond
+	}
+	bus, err := NewEventBus(db, &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bus.Close() })
+	return bus
+}
+
+func newTestEvent(id string) cloudevents.Event {
+	event := cloudevents.NewEvent()
+	event.SetID(id)
+	event.SetType("order.created")
+	event.SetSource("eventbus_test")
+	return event
+}
+
+// collect returns a handler that forwards event IDs to the returned channel.
+func collect() (func(ctx context.Context, event cloudevents.Event), chan string) {
+	ch := make(chan string, 100)
+	return func(ctx context.Context, event cloudevents.Event) {
+		ch <- event.ID()
+	}, ch
+}
+
+func receive(t *testing.T, ch chan string, n int) []string {
+	t.Helper()
+
+	var ids []string
+	for len(ids) < n {
+		select {
+		case id := <-ch:
+			ids = append(ids, id)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after receiving %d of %d events", len(ids), n)
+		}
+	}
+	return ids
+}
+
+func assertNoMore(t *testing.T, ch chan string) {
+	t.Helper()
+
+	select {
+	case id := <-ch:
+		t.Fatalf("unexpected event %s", id)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	bus := newTestBus(t, newTestDB(t), Config{})
+	ctx := context.Background()
+
+	// Subscriptions outside a group start after the latest message.
+	require.NoError(t, bus.Publish(ctx, "orders", newTestEvent("0")))
+
+	handler, ch := collect()
+	require.NoError(t, bus.Subscribe("orders", handler))
+
+	require.NoError(t, bus.Publish(ctx, "orders", newTestEvent("1")))
+	require.NoError(t, bus.Publish(ctx, "payments", newTestEvent("2")))
+	require.NoError(t, bus.Publish(ctx, "orders", newTestEvent("3")))
+	assert.Equal(t, []string{"1", "3"}, receive(t, ch, 2))
+	assertNoMore(t, ch)
+}
+
+func TestPublishValidation(t *testing.T) {
+	bus := newTestBus(t, newTestDB(t), Config{})
+
+	assert.Error(t, bus.Publish(context.Background(), "", newTestEvent("1")))
+	assert.Error(t, bus.Publish(context.Background(), "orders", cloudevents.NewEvent()))
+
+	require.NoError(t, bus.Close())
+	assert.Error(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+}
+
+func TestPublishTxIsTransactional(t *testing.T) {
+	db := newTestDB(t)
+	bus := newTestBus(t, db, Config{})
+
+	handler, ch := collect()
+	require.NoError(t, bus.Subscribe("orders", handler, messaging.WithConsumerGroup("billing")))
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		require.NoError(t, bus.PublishTx(tx, "orders", newTestEvent("1")))
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return bus.PublishTx(tx, "orders", newTestEvent("2"))
+	}))
+
+	assert.Equal(t, []string{"2"}, receive(t, ch, 1))
+	assertNoMore(t, ch)
+}
+
+func TestConsumerGroupKeepsEventsWhileStopped(t *testing.T) {
+	db := newTestDB(t)
+	bus := newTestBus(t, db, Config{})
+	ctx := context.Background()
+
+	handler, ch := collect()
+	sub, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		handler(ctx, event)
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	assert.Equal(t, "billing", sub.Group())
+
+	require.NoError(t, bus.Publish(ctx, "orders", newTestEvent("1")))
+	assert.Equal(t, []string{"1"}, receive(t, ch, 1))
+	require.NoError(t, sub.Drain(ctx))
+
+	// Events published while the group has no members are kept for it.
+	require.NoError(t, bus.Publish(ctx, "orders", newTestEvent("2")))
+	require.NoError(t, bus.Publish(ctx, "orders", newTestEvent("3")))
+
+	restarted := newTestBus(t, db, Config{})
+	handler, ch = collect()
+	require.NoError(t, restarted.Subscribe("orders", handler, messaging.WithConsumerGroup("billing")))
+	assert.Equal(t, []string{"2", "3"}, receive(t, ch, 2))
+	assertNoMore(t, ch)
+
+	var pending int64
+	require.NoError(t, db.Model(&Delivery{}).Count(&pending).Error)
+	assert.Zero(t, pending)
+}
+
+func TestConsumerGroupMembersShareEvents(t *testing.T) {
+	db := newTestDB(t)
+	bus := newTestBus(t, db, Config{BatchSize: 2})
+
+	var first, second atomic.Int64
+	ch := make(chan string, 100)
+	for _, count := range []*atomic.Int64{&first, &second} {
+		count := count
+		_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+			count.Add(1)
+			ch <- event.ID()
+			return nil
+		}, WithConsumerGroup("billing"))
+		require.NoError(t, err)
+	}
+	// Every group receives each event once.
+	handler, audit := collect()
+	require.NoError(t, bus.Subscribe("orders", handler, messaging.WithConsumerGroup("audit")))
+
+	var expected []string
+	for i := 1; i <= 20; i++ {
+		require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent(fmt.Sprint(i))))
+		expected = append(expected, fmt.Sprint(i))
+	}
+
+	assert.ElementsMatch(t, expected, receive(t, ch, len(expected)))
+	assert.Equal(t, expected, receive(t, audit, len(expected)))
+	assertNoMore(t, ch)
+	assert.Equal(t, int64(20), first.Load()+second.Load())
+}
+
+func TestSlowBatchIsNotHandledTwice(t *testing.T) {
+	db := newTestDB(t)
+	// Handling the batch takes longer than the visibility timeout.
+	bus := newTestBus(t, db, Config{VisibilityTimeout: 150 * time.Millisecond})
+
+	ch := make(chan string, 100)
+	handler := func(ctx context.Context, event cloudevents.Event) error {
+		time.Sleep(100 * time.Millisecond)
+		ch <- event.ID()
+		return nil
+	}
+	_, err := bus.SubscribeHandler("orders", handler, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+
+	// Publish the events at once, so that the first member claims them in one batch.
+	var expected []string
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		for i := 1; i <= 5; i++ {
+			expected = append(expected, fmt.Sprint(i))
+			if err := bus.PublishTx(tx, "orders", newTestEvent(fmt.Sprint(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	assert.Equal(t, "1", receive(t, ch, 1)[0])
+
+	_, err = bus.SubscribeHandler("orders", handler, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, expected[1:], receive(t, ch, len(expected)-1))
+	assertNoMore(t, ch)
+}
+
+func TestFailedDeliveryIsRetried(t *testing.T) {
+	db := newTestDB(t)
+	bus := newTestBus(t, db, Config{})
+
+	var attempts atomic.Int64
+	handled := make(chan string, 10)
+	_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		switch attempts.Add(1) {
+		case 1:
+			return fmt.Errorf("temporary failure")
+		case 2:
+			panic("boom")
+		}
+		handled <- event.ID()
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	assert.Equal(t, []string{"1"}, receive(t, handled, 1))
+	assert.Equal(t, int64(3), attempts.Load())
+}
+
+func TestReleaseKeepsClaimsOfOtherMembers(t *testing.T) {
+	db := newTestDB(t)
+	bus := newTestBus(t, db, Config{})
+	ctx := context.Background()
+
+	sub, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	require.NoError(t, sub.Drain(ctx))
+	require.NoError(t, bus.Publish(ctx, "orders", newTestEvent("1")))
+
+	var delivery Delivery
+	require.NoError(t, db.First(&delivery).Error)
+	// Claimed once by this member, then again by another one.
+	require.NoError(t, db.Model(&delivery).Update("attempts", 2).Error)
+
+	bus.release([]Delivery{{ID: delivery.ID, Attempts: 1}})
+	require.NoError(t, db.First(&delivery).Error)
+	assert.Equal(t, 2, delivery.Attempts)
+
+	bus.release([]Delivery{{ID: delivery.ID, Attempts: 2}})
+	require.NoError(t, db.First(&delivery).Error)
+	assert.Equal(t, 1, delivery.Attempts)
+}
+
+func TestFailedDeliveryIsDeadAfterMaxAttempts(t *testing.T) {
+	db := newTestDB(t)
+	bus := newTestBus(t, db, Config{MaxAttempts: 2})
+
+	attempts := make(chan string, 10)
+	_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		attempts <- event.ID()
+		return fmt.Errorf("permanent failure")
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	assert.Equal(t, []string{"1", "1"}, receive(t, attempts, 2))
+	assertNoMore(t, attempts)
+
+	var delivery Delivery
+	require.NoError(t, db.First(&delivery).Error)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.Equal(t, "permanent failure", delivery.LastError)
+	assert.NotNil(t, delivery.DeadAt)
+}
+
+func TestDeleteGroup(t *testing.T) {
+	db := newTestDB(t)
+	bus := newTestBus(t, db, Config{})
+	ctx := context.Background()
+
+	sub, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	require.NoError(t, sub.Drain(ctx))
+	require.NoError(t, bus.Publish(ctx, "orders", newTestEvent("1")))
+
+	require.NoError(t, bus.DeleteGroup(ctx, "billing", "orders"))
+	require.NoError(t, bus.Publish(ctx, "orders", newTestEvent("2")))
+
+	var groups, deliveries int64
+	require.NoError(t, db.Model(&Group{}).Count(&groups).Error)
+	require.NoError(t, db.Model(&Delivery{}).Count(&deliveries).Error)
+	assert.Zero(t, groups)
+	assert.Zero(t, deliveries)
+}
+
+func TestPurge(t *testing.T) {
+	db := newTestDB(t)
+	bus := newTestBus(t, db, Config{Retention: time.Hour})
+	ctx := context.Background()
+
+	require.NoError(t, bus.registerGroup(ctx, "billing", "orders"))
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, bus.Publish(ctx, "orders", newTestEvent(fmt.Sprint(i))))
+	}
+	old := time.Now().UTC().Add(-2 * time.Hour)
+	require.NoError(t, db.Model(&Message{}).Where("event_id IN ?", []string{"1", "2"}).Update("created_at", old).Error)
+	// The delivery of 1 is handled, 2 is dead and 3 is recent.
+	require.NoError(t, db.Where("message_id = (SELECT id FROM event_bus_messages WHERE event_id = '1')").Delete(&Delivery{}).Error)
+	require.NoError(t, db.Model(&Delivery{}).Where("message_id = (SELECT id FROM event_bus_messages WHERE event_id = '2')").Update("dead_at", old).Error)
+
+	n, err := bus.Purge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	var remaining []Message
+	require.NoError(t, db.Find(&remaining).Error)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "3", remaining[0].EventID)
+	var deliveries int64
+	require.NoError(t, db.Model(&Delivery{}).Count(&deliveries).Error)
+	assert.Equal(t, int64(1), deliveries)
+}
+
+func TestPurgeKeepsPendingDeliveries(t *testing.T) {
+	db := newTestDB(t)
+	bus := newTestBus(t, db, Config{Retention: time.Hour})
+	ctx := context.Background()
+
+	require.NoError(t, bus.registerGroup(ctx, "billing", "orders"))
+	require.NoError(t, bus.Publish(ctx, "orders", newTestEvent("1")))
+	require.NoError(t, db.Model(&Message{}).Where("1 = 1").Update("created_at", time.Now().UTC().Add(-2*time.Hour)).Error)
+
+	n, err := bus.Purge(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestBackoff(t *testing.T) {
+	bus := &PostgresEventBus{cfg: Config{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}}
+	assert.Equal(t, time.Second, bus.backoff(1))
+	assert.Equal(t, 2*time.Second, bus.backoff(2))
+	assert.Equal(t, 4*time.Second, bus.backoff(3))
+	assert.Equal(t, 5*time.Second, bus.backoff(4))
+}
+
+func TestShutdownReportsUnfinishedHandlers(t *testing.T) {
+	bus := newTestBus(t, newTestDB(t), Config{})
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	require.NoError(t, bus.Subscribe("orders", func(ctx context.Context, event cloudevents.Event) {
+		close(started)
+		<-release
+	}, messaging.WithConsumerGroup("billing")))
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := bus.Shutdown(ctx)
+
+	var drainErr *DrainError
+	require.ErrorAs(t, err, &drainErr)
+	assert.Equal(t, []UnfinishedHandlers{{Topic: "orders", Group: "billing", InFlight: 1}}, drainErr.Unfinished)
+}
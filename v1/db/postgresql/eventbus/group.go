@@ -0,0 +1,179 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// processGroupBatch claims the due deliveries of a consumer group member and handles
+// them in order. It returns the number of claimed deliveries.
+func (b *PostgresEventBus) processGroupBatch(sub *Subscription) (int, error) {
+	deliveries, messages, err := b.claim(sub.ctx, sub.group, sub.topic)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range deliveries {
+		d := &deliveries[i]
+		if sub.ctx.Err() != nil {
+			// Hand the rest of the batch back instead of hiding it until the visibility timeout.
+			b.release(deliveries[i:])
+			break
+		}
+
+		if !b.renew(d) {
+			continue
+		}
+
+		msg, ok := messages[d.MessageID]
+		if !ok {
+			log.Printf("PostgreSQL EventBus: message %d of delivery %d no longer exists", d.MessageID, d.ID)
+			b.complete(d)
+			continue
+		}
+		event, err := msg.Event()
+		if err != nil {
+			// An undecodable message will never succeed.
+			b.fail(d, err, true)
+			continue
+		}
+
+		if err := sub.invoke(event); err != nil {
+			b.fail(d, err, false)
+		} else {
+			b.complete(d)
+		}
+	}
+	return len(deliveries), nil
+}
+
+// claim selects up to BatchSize due deliveries of a group and hides them from the other
+// members for the visibility timeout. On PostgreSQL rows locked by a concurrent claim
+// are skipped, so members never claim the same delivery. Handling a batch may take
+// longer than the visibility timeout, so each delivery is renewed before it is handled.
+func (b *PostgresEventBus) claim(ctx context.Context, group, topic string) ([]Delivery, map[uint64]*Message, error) {
+	var deliveries []Delivery
+	messages := make(map[uint64]*Message)
+	now := time.Now().UTC()
+
+	err := b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("group_name = ? AND topic = ? AND dead_at IS NULL AND next_attempt_at <= ?", group, topic, now)
+		if b.postgres {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := query.Order("id").Limit(b.cfg.BatchSize).Find(&deliveries).Error; err != nil {
+			return fmt.Errorf("failed to select deliveries: %w", err)
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uint64, len(deliveries))
+		messageIDs := make([]uint64, len(deliveries))
+		for i, d := range deliveries {
+			ids[i] = d.ID
+			messageIDs[i] = d.MessageID
+		}
+		err := tx.Model(&Delivery{}).Where("id IN ?", ids).Updates(map[string]any{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": now.Add(b.cfg.VisibilityTimeout),
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to claim deliveries: %w", err)
+		}
+
+		var rows []Message
+		if err := tx.Where("id IN ?", messageIDs).Find(&rows).Error; err != nil {
+			return fmt.Errorf("failed to select messages: %w", err)
+		}
+		for i := range rows {
+			messages[rows[i].ID] = &rows[i]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range deliveries {
+		deliveries[i].Attempts++
+	}
+	return deliveries, messages, nil
+}
+
+// renew hides a claimed delivery for another visibility timeout right before it is
+// handled and reports whether this member still owns it. A delivery whose claim expired
+// while the earlier ones of its batch were handled may have been claimed by another
+// member since, which incremented its attempts.
+func (b *PostgresEventBus) renew(d *Delivery) bool {
+	res := b.db.Model(&Delivery{}).
+		Where("id = ? AND attempts = ? AND dead_at IS NULL", d.ID, d.Attempts).
+		Update("next_attempt_at", time.Now().UTC().Add(b.cfg.VisibilityTimeout))
+	if res.Error != nil {
+		log.Printf("PostgreSQL EventBus: failed to renew delivery %d: %v", d.ID, res.Error)
+		return false
+	}
+	return res.RowsAffected == 1
+}
+
+// complete removes a handled delivery.
+func (b *PostgresEventBus) complete(d *Delivery) {
+	if err := b.db.Delete(&Delivery{}, d.ID).Error; err != nil {
+		log.Printf("PostgreSQL EventBus: failed to complete delivery %d: %v", d.ID, err)
+	}
+}
+
+// fail schedules the next attempt of a delivery, or marks it dead once MaxAttempts
+// is reached or when it can never succeed.
+func (b *PostgresEventBus) fail(d *Delivery, cause error, permanent bool) {
+	now := time.Now().UTC()
+	values := map[string]any{
+		"last_error":      cause.Error(),
+		"next_attempt_at": now.Add(b.backoff(d.Attempts)),
+	}
+	if permanent || (b.cfg.MaxAttempts > 0 && d.Attempts >= b.cfg.MaxAttempts) {
+		log.Printf("PostgreSQL EventBus: giving up on delivery %d (group %s) after %d attempts: %v", d.ID, d.GroupName, d.Attempts, cause)
+		values["dead_at"] = now
+	} else {
+		log.Printf("PostgreSQL EventBus: handler failed on delivery %d (group %s), attempt %d: %v", d.ID, d.GroupName, d.Attempts, cause)
+	}
+
+	if err := b.db.Model(&Delivery{}).Where("id = ?", d.ID).Updates(values).Error; err != nil {
+		log.Printf("PostgreSQL EventBus: failed to update delivery %d: %v", d.ID, err)
+	}
+}
+
+// release makes claimed but unhandled deliveries due again without counting an attempt.
+func (b *PostgresEventBus) release(deliveries []Delivery) {
+	now := time.Now().UTC()
+	for _, d := range deliveries {
+		// Like renew, only a delivery this member still owns is released; another
+		// member may have claimed it since the visibility timeout expired.
+		err := b.db.Model(&Delivery{}).
+			Where("id = ? AND attempts = ? AND dead_at IS NULL", d.ID, d.Attempts).
+			Updates(map[string]any{
+				"attempts":        gorm.Expr("attempts - 1"),
+				"next_attempt_at": now,
+			}).Error
+		if err != nil {
+			log.Printf("PostgreSQL EventBus: failed to release delivery %d: %v", d.ID, err)
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt after the given number of failures.
+func (b *PostgresEventBus) backoff(attempts int) time.Duration {
+	d := b.cfg.InitialBackoff
+	for i := 1; i < attempts && d < b.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > b.cfg.MaxBackoff {
+		d = b.cfg.MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,71 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// listen holds a connection in LISTEN mode and wakes the subscriptions of the topics
+// named in notifications. The connection is re-established after errors; while it is
+// down subscriptions keep polling.
+func (b *PostgresEventBus) listen(ctx context.Context) {
+	for {
+		err := b.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("PostgreSQL EventBus: listener failed, falling back to polling: %v", err)
+		if errors.Is(err, errUnsupportedDriver) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(errorSleepDuration):
+		}
+	}
+}
+
+var errUnsupportedDriver = errors.New("LISTEN requires the pgx driver")
+
+func (b *PostgresEventBus) listenOnce(ctx context.Context) error {
+	sqlDB, err := b.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errUnsupportedDriver
+		}
+		pc := c.Conn()
+		channel := pgx.Identifier{b.cfg.Channel}.Sanitize()
+		if _, err := pc.Exec(ctx, "LISTEN "+channel); err != nil {
+			return err
+		}
+		// Stop listening before the connection goes back to the pool.
+		defer func() { _, _ = pc.Exec(context.Background(), "UNLISTEN "+channel) }()
+
+		// Catch up on notifications missed while no connection was listening.
+		b.wake("")
+		for {
+			n, err := pc.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			b.wake(n.Payload)
+		}
+	})
+}
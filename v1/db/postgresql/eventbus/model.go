@@ -0,0 +1,78 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"gorm.io/gorm"
+)
+
+// Names of the event bus tables.
+const (
+	MessagesTableName   = "event_bus_messages"
+	GroupsTableName     = "event_bus_groups"
+	DeliveriesTableName = "event_bus_deliveries"
+)
+
+// Message is a published event.
+type Message struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement"`
+	Topic     string    `gorm:"not null;index"`
+	EventID   string    `gorm:"not null"`
+	Payload   []byte    `gorm:"not null"` // JSON encoded CloudEvent
+	CreatedAt time.Time `gorm:"not null;index"`
+}
+
+// TableName implements the GORM tabler interface.
+func (Message) TableName() string {
+	return MessagesTableName
+}
+
+// Event decodes the CloudEvent stored in the row.
+func (m *Message) Event() (cloudevents.Event, error) {
+	var event cloudevents.Event
+	if err := json.Unmarshal(m.Payload, &event); err != nil {
+		return cloudevents.Event{}, fmt.Errorf("failed to decode message %d: %w", m.ID, err)
+	}
+	return event, nil
+}
+
+// Group registers a consumer group on a topic. Every message published to the topic
+// afterwards gets a Delivery for the group, also while none of its members is running.
+type Group struct {
+	GroupName string    `gorm:"primaryKey"`
+	Topic     string    `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// TableName implements the GORM tabler interface.
+func (Group) TableName() string {
+	return GroupsTableName
+}
+
+// Delivery is a message waiting to be handled by a consumer group. It is deleted once
+// a member handled it. While a member handles it, NextAttemptAt is pushed out by the
+// visibility timeout, so the delivery becomes due again if that member dies.
+type Delivery struct {
+	ID            uint64     `gorm:"primaryKey;autoIncrement"`
+	GroupName     string     `gorm:"not null;index:idx_event_bus_deliveries_due,priority:1"`
+	Topic         string     `gorm:"not null;index:idx_event_bus_deliveries_due,priority:2"`
+	MessageID     uint64     `gorm:"not null;index"`
+	Attempts      int        `gorm:"not null;default:0"`
+	LastError     string     `gorm:"not null;default:''"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_event_bus_deliveries_due,priority:3"`
+	DeadAt        *time.Time // Set once MaxAttempts failed; dead deliveries are not retried
+	CreatedAt     time.Time  `gorm:"not null"`
+}
+
+// TableName implements the GORM tabler interface.
+func (Delivery) TableName() string {
+	return DeliveriesTableName
+}
+
+// Migrate creates or updates the event bus tables.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Message{}, &Group{}, &Delivery{})
+}
@@ -0,0 +1,44 @@
+package eventbus
+
+import (
+	"context"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+)
+
+// Handler processes an event and reports whether it succeeded.
+// In consumer groups a delivery is removed only when the handler returns nil;
+// otherwise it is retried with backoff.
+type Handler func(ctx context.Context, event cloudevents.Event) error
+
+// SubscriptionOptions extends messaging.SubscriptionOptions with event bus specific settings.
+type SubscriptionOptions struct {
+	messaging.SubscriptionOptions
+}
+
+// SubscriptionOption defines a function to set subscription options.
+type SubscriptionOption func(opts *SubscriptionOptions)
+
+// WithConsumerGroup specifies the consumer group.
+func WithConsumerGroup(group string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.Group = group
+	}
+}
+
+// WithConsumerName specifies the consumer name.
+func WithConsumerName(name string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.Name = name
+	}
+}
+
+// WithMessagingOptions applies the generic messaging subscription options.
+func WithMessagingOptions(options ...messaging.SubscriptionOption) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		for _, o := range options {
+			o(&opts.SubscriptionOptions)
+		}
+	}
+}
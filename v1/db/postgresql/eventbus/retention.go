@@ -0,0 +1,57 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Purge deletes the messages older than the configured retention together with their
+// dead deliveries. Messages that a consumer group still has to handle are kept until
+// the group handled them or gave up. It returns the number of deleted messages.
+func (b *PostgresEventBus) Purge(ctx context.Context) (int64, error) {
+	if b.cfg.Retention <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().UTC().Add(-b.cfg.Retention)
+
+	var purged int64
+	err := b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		old := tx.Model(&Message{}).Select("id").Where("created_at < ?", cutoff)
+		if err := tx.Where("dead_at IS NOT NULL AND message_id IN (?)", old).Delete(&Delivery{}).Error; err != nil {
+			return fmt.Errorf("failed to purge dead deliveries: %w", err)
+		}
+
+		result := tx.Where(fmt.Sprintf("created_at < ? AND NOT EXISTS (SELECT 1 FROM %s WHERE %s.message_id = %s.id)",
+			DeliveriesTableName, DeliveriesTableName, MessagesTableName), cutoff).Delete(&Message{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to purge messages: %w", result.Error)
+		}
+		purged = result.RowsAffected
+		return nil
+	})
+	return purged, err
+}
+
+// runJanitor purges old messages every RetentionInterval until ctx is cancelled.
+func (b *PostgresEventBus) runJanitor(ctx context.Context) {
+	ticker := time.NewTicker(b.cfg.RetentionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := b.Purge(ctx)
+			if err != nil {
+				log.Printf("PostgreSQL EventBus: %v", err)
+			} else if n > 0 {
+				log.Printf("PostgreSQL EventBus: purged %d messages", n)
+			}
+		}
+	}
+}
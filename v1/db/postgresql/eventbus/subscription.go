@@ -0,0 +1,173 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// Subscription is a handle to an active subscription created by SubscribeHandler.
+type Subscription struct {
+	topic   string
+	group   string
+	name    string
+	handler Handler
+	lastID  uint64 // Latest message seen by a subscription outside a consumer group
+
+	bus      *PostgresEventBus
+	wakeCh   chan struct{} // Signalled when new messages may be available
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{} // Closed once the polling loop has returned
+	inFlight atomic.Int64
+}
+
+// Topic returns the topic the subscription consumes.
+func (s *Subscription) Topic() string {
+	return s.topic
+}
+
+// Group returns the consumer group of the subscription, if any.
+func (s *Subscription) Group() string {
+	return s.group
+}
+
+// InFlight returns the number of handler invocations currently running.
+func (s *Subscription) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// Unsubscribe stops polling. A handler already running is not waited for; its delivery
+// is still removed when it succeeds. The consumer group stays registered, see DeleteGroup.
+func (s *Subscription) Unsubscribe() error {
+	s.cancel()
+	s.bus.removeSubscription(s)
+	return nil
+}
+
+// Drain stops polling and waits for the in-flight handler to finish. If ctx expires
+// first, a *DrainError describing the unfinished handler is returned.
+func (s *Subscription) Drain(ctx context.Context) error {
+	_ = s.Unsubscribe()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return &DrainError{Unfinished: []UnfinishedHandlers{s.unfinished()}}
+	}
+}
+
+// run polls until the subscription stops. It polls again right away after a full
+// batch and otherwise waits for a notification or PollInterval.
+func (s *Subscription) run() {
+	defer close(s.done)
+
+	for {
+		n, err := s.poll()
+		if s.ctx.Err() != nil {
+			return
+		}
+		wait := s.bus.cfg.PollInterval
+		if err != nil {
+			log.Printf("PostgreSQL EventBus: failed to poll topic '%s': %v", s.topic, err)
+			wait = errorSleepDuration
+		} else if n == s.bus.cfg.BatchSize {
+			continue
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.wakeCh:
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (s *Subscription) poll() (int, error) {
+	if s.group != "" {
+		return s.bus.processGroupBatch(s)
+	}
+	return s.tail()
+}
+
+// tail handles the messages published after the last one seen.
+func (s *Subscription) tail() (int, error) {
+	var messages []Message
+	err := s.bus.db.WithContext(s.ctx).Where("topic = ? AND id > ?", s.topic, s.lastID).
+		Order("id").Limit(s.bus.cfg.BatchSize).Find(&messages).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to select messages: %w", err)
+	}
+
+	for i := range messages {
+		if s.ctx.Err() != nil {
+			return i, nil
+		}
+		s.lastID = messages[i].ID
+		event, err := messages[i].Event()
+		if err == nil {
+			err = s.invoke(event)
+		}
+		if err != nil {
+			log.Printf("PostgreSQL EventBus: handler failed on topic '%s': %v", s.topic, err)
+		}
+	}
+	return len(messages), nil
+}
+
+// invoke runs the handler as a tracked invocation, converting a panic into an error.
+func (s *Subscription) invoke(event cloudevents.Event) (err error) {
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("handler panicked: %v", rec)
+		}
+	}()
+	return s.handler(context.Background(), event)
+}
+
+// wake signals the polling loop without blocking.
+func (s *Subscription) wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Subscription) unfinished() UnfinishedHandlers {
+	return UnfinishedHandlers{Topic: s.topic, Group: s.group, Name: s.name, InFlight: s.InFlight()}
+}
+
+// UnfinishedHandlers describes handlers of a subscription that were still running
+// when a drain deadline expired.
+type UnfinishedHandlers struct {
+	Topic    string
+	Group    string
+	Name     string
+	InFlight int64
+}
+
+// DrainError is returned by Drain and Shutdown when handlers did not finish in time.
+type DrainError struct {
+	Unfinished []UnfinishedHandlers
+}
+
+func (e *DrainError) Error() string {
+	parts := make([]string, 0, len(e.Unfinished))
+	for _, u := range e.Unfinished {
+		if u.Group != "" {
+			parts = append(parts, fmt.Sprintf("%s (group %s): %d", u.Topic, u.Group, u.InFlight))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s: %d", u.Topic, u.InFlight))
+		}
+	}
+	return "handlers did not finish before the deadline: " + strings.Join(parts, ", ")
+}
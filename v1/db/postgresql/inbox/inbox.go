@@ -0,0 +1,79 @@
+// Package inbox records handled events in a PostgreSQL table so consumers can skip
+// redelivered duplicates. Store satisfies the idempotency.Store interface of the
+// messaging idempotency extension.
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TableName is the name of the inbox table.
+const TableName = "event_inbox"
+
+// Record is a row of the inbox table.
+type Record struct {
+	Key       string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// TableName implements the GORM tabler interface.
+func (Record) TableName() string {
+	return TableName
+}
+
+// Migrate creates or updates the inbox table.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Record{})
+}
+
+// Store claims event keys in the inbox table. Claims are atomic, so concurrent copies
+// of an event are handled only once.
+type Store struct {
+	db *gorm.DB
+}
+
+// NewStore creates a Store on db.
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// Claim inserts key, replacing an expired record of the same key. It reports false
+// if an unexpired record exists.
+func (s *Store) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	record := Record{Key: key, ExpiresAt: now.Add(ttl), CreatedAt: now}
+
+	result := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at", "created_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Lt{Column: clause.Column{Table: TableName, Name: "expires_at"}, Value: now}}},
+	}).Create(&record)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim %s: %w", key, result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Release deletes the record of key.
+func (s *Store) Release(ctx context.Context, key string) error {
+	if err := s.db.WithContext(ctx).Delete(&Record{Key: key}).Error; err != nil {
+		return fmt.Errorf("failed to release %s: %w", key, err)
+	}
+	return nil
+}
+
+// PurgeExpired deletes expired records and returns their number.
+// Expired records are replaced on Claim anyway; purging only keeps the table small.
+func (s *Store) PurgeExpired(ctx context.Context) (int64, error) {
+	result := s.db.WithContext(ctx).Where("expires_at < ?", time.Now().UTC()).Delete(&Record{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge inbox: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
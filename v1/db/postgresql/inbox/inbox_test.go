@@ -0,0 +1,89 @@
+package inbox
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "inbox.db")+"?_pragma=busy_timeout(5000)"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, Migrate(db))
+	return NewStore(db)
+}
+
+func TestClaim(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	claimed, err := s.Claim(ctx, "billing:orders:1", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = s.Claim(ctx, "billing:orders:1", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	require.NoError(t, s.Release(ctx, "billing:orders:1"))
+	claimed, err = s.Claim(ctx, "billing:orders:1", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestClaimReplacesExpiredRecords(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	claimed, err := s.Claim(ctx, "key", 20*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	time.Sleep(30 * time.Millisecond)
+	claimed, err = s.Claim(ctx, "key", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = s.Claim(ctx, "other", time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	time.Sleep(5 * time.Millisecond)
+
+	n, err := s.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestClaimIsAtomic(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	var claims atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claimed, err := s.Claim(ctx, "key", time.Hour)
+			assert.NoError(t, err)
+			if claimed {
+				claims.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), claims.Load())
+}
@@ -0,0 +1,81 @@
+// Package outbox implements the transactional outbox pattern on top of GORM.
+//
+// Events are written to an outbox table in the same transaction as the state change
+// that produced them, and a Relay publishes them to a messaging.EventBus afterwards.
+// Delivery is at least once: an event may be published again if the relay stops
+// between publishing it and marking its row as sent.
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"gorm.io/gorm"
+)
+
+// TableName is the name of the outbox table.
+const TableName = "event_outbox"
+
+// Message is a row of the outbox table.
+type Message struct {
+	ID            uint64     `gorm:"primaryKey;autoIncrement"`
+	Topic         string     `gorm:"not null"`
+	EventID       string     `gorm:"not null"`
+	Payload       []byte     `gorm:"not null"`           // JSON encoded CloudEvent
+	Attempts      int        `gorm:"not null;default:0"` // Failed publish attempts
+	LastError     string     `gorm:"not null;default:''"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_event_outbox_pending,priority:2"`
+	SentAt        *time.Time `gorm:"index:idx_event_outbox_pending,priority:1"`
+	CreatedAt     time.Time  `gorm:"not null"`
+}
+
+// TableName implements the GORM tabler interface.
+func (Message) TableName() string {
+	return TableName
+}
+
+// Event decodes the CloudEvent stored in the row.
+func (m *Message) Event() (cloudevents.Event, error) {
+	var event cloudevents.Event
+	if err := json.Unmarshal(m.Payload, &event); err != nil {
+		return cloudevents.Event{}, fmt.Errorf("failed to decode outbox message %d: %w", m.ID, err)
+	}
+	return event, nil
+}
+
+// Migrate creates or updates the outbox table.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Message{})
+}
+
+// Enqueue stores an event for publication on topic. Call it with the transaction that
+// changes the state the event describes, so the event is published only if it commits.
+func Enqueue(tx *gorm.DB, topic string, event cloudevents.Event) error {
+	if topic == "" {
+		return errors.New("topic must not be empty")
+	}
+	if err := event.Validate(); err != nil {
+		return fmt.Errorf("invalid event: %w", err)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	now := time.Now().UTC()
+	msg := Message{
+		Topic:         topic,
+		EventID:       event.ID(),
+		Payload:       payload,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if err := tx.Create(&msg).Error; err != nil {
+		return fmt.Errorf("failed to enqueue event: %w", err)
+	}
+	return nil
+}
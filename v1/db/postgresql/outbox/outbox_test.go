@@ -0,0 +1,256 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeBus records published events and fails while failing is set. If cancel is set,
+// the publish with number cancelAt cancels the relay's context and fails.
+type fakeBus struct {
+	mu        sync.Mutex
+	published []string
+	failing   bool
+	calls     int
+	cancelAt  int
+	cancel    context.CancelFunc
+}
+
+var _ messaging.EventBus = (*fakeBus)(nil)
+
+func (b *fakeBus) Publish(ctx context.Context, topic string, event cloudevents.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls++
+	if b.cancel != nil && b.calls == b.cancelAt {
+		b.cancel()
+		return ctx.Err()
+	}
+	if b.failing {
+		return errors.New("broker unavailable")
+	}
+	b.published = append(b.published, topic+"/"+event.ID())
+	return nil
+}
+
+func (b *fakeBus) Subscribe(topic string, handler func(ctx context.Context, event cloudevents.Event), opts ...messaging.SubscriptionOption) error {
+	return nil
+}
+
+func (b *fakeBus) Close() error { return nil }
+
+func (b *fakeBus) setFailing(failing bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failing = failing
+}
+
+func (b *fakeBus) events() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.published...)
+}
+
+// order is a model changed together with the outbox.
+type order struct {
+	ID     uint
+	Status string
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "outbox.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, Migrate(db))
+	require.NoError(t, db.AutoMigrate(&order{}))
+	return db
+}
+
+func newTestEvent(id string) cloudevents.Event {
+	event := cloudevents.NewEvent()
+	event.SetID(id)
+	event.SetType("order.created")
+	event.SetSource("outbox_test")
+	return event
+}
+
+func TestEnqueueIsTransactional(t *testing.T) {
+	db := newTestDB(t)
+
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		require.NoError(t, tx.Create(&order{Status: "created"}).Error)
+		return Enqueue(tx, "orders", newTestEvent("1"))
+	}))
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		require.NoError(t, tx.Create(&order{Status: "created"}).Error)
+		require.NoError(t, Enqueue(tx, "orders", newTestEvent("2")))
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+
+	var messages []Message
+	require.NoError(t, db.Find(&messages).Error)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "1", messages[0].EventID)
+
+	event, err := messages[0].Event()
+	require.NoError(t, err)
+	assert.Equal(t, "order.created", event.Type())
+
+	assert.Error(t, Enqueue(db, "", newTestEvent("3")))
+	assert.Error(t, Enqueue(db, "orders", cloudevents.NewEvent()))
+}
+
+func TestRelayPublishesAndMarksSent(t *testing.T) {
+	db := newTestDB(t)
+	bus := &fakeBus{}
+	relay := NewRelay(db, bus, RelayConfig{BatchSize: 2})
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, Enqueue(db, "orders", newTestEvent(fmt.Sprint(i))))
+	}
+
+	n, err := relay.ProcessBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	n, err = relay.ProcessBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = relay.ProcessBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	assert.Equal(t, []string{"orders/1", "orders/2", "orders/3"}, bus.events())
+	var unsent int64
+	require.NoError(t, db.Model(&Message{}).Where("sent_at IS NULL").Count(&unsent).Error)
+	assert.Zero(t, unsent)
+}
+
+func TestRelayRetriesWithBackoff(t *testing.T) {
+	db := newTestDB(t)
+	bus := &fakeBus{failing: true}
+	relay := NewRelay(db, bus, RelayConfig{InitialBackoff: 50 * time.Millisecond, MaxAttempts: 3})
+	ctx := context.Background()
+
+	require.NoError(t, Enqueue(db, "orders", newTestEvent("1")))
+
+	n, err := relay.ProcessBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	var msg Message
+	require.NoError(t, db.First(&msg).Error)
+	assert.Equal(t, 1, msg.Attempts)
+	assert.Equal(t, "broker unavailable", msg.LastError)
+	assert.Nil(t, msg.SentAt)
+
+	// The row is not due again before its backoff expires.
+	n, err = relay.ProcessBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	time.Sleep(60 * time.Millisecond)
+	bus.setFailing(false)
+	n, err = relay.ProcessBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"orders/1"}, bus.events())
+
+	require.NoError(t, db.First(&msg).Error)
+	assert.NotNil(t, msg.SentAt)
+	assert.Empty(t, msg.LastError)
+}
+
+func TestRelayGivesUpAfterMaxAttempts(t *testing.T) {
+	db := newTestDB(t)
+	bus := &fakeBus{failing: true}
+	relay := NewRelay(db, bus, RelayConfig{InitialBackoff: time.Millisecond, MaxAttempts: 2})
+	ctx := context.Background()
+
+	require.NoError(t, Enqueue(db, "orders", newTestEvent("1")))
+	for i := 0; i < 2; i++ {
+		time.Sleep(5 * time.Millisecond)
+		n, err := relay.ProcessBatch(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	bus.setFailing(false)
+	n, err := relay.ProcessBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Empty(t, bus.events())
+}
+
+func TestRelayStopsBatchWhenCancelled(t *testing.T) {
+	db := newTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := &fakeBus{cancelAt: 2, cancel: cancel}
+	relay := NewRelay(db, bus, RelayConfig{})
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, Enqueue(db, "orders", newTestEvent(fmt.Sprint(i))))
+	}
+
+	n, err := relay.ProcessBatch(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"orders/1"}, bus.events())
+	assert.Equal(t, 2, bus.calls, "nothing is published after the cancellation")
+
+	// The published row stays sent, and the interrupted one is not counted as a failure.
+	var messages []Message
+	require.NoError(t, db.Order("id").Find(&messages).Error)
+	require.Len(t, messages, 3)
+	assert.NotNil(t, messages[0].SentAt)
+	assert.Nil(t, messages[1].SentAt)
+	assert.Zero(t, messages[1].Attempts)
+	assert.Nil(t, messages[2].SentAt)
+}
+
+func TestRelayRun(t *testing.T) {
+	db := newTestDB(t)
+	bus := &fakeBus{}
+	relay := NewRelay(db, bus, RelayConfig{PollInterval: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- relay.Run(ctx) }()
+
+	require.NoError(t, Enqueue(db, "orders", newTestEvent("1")))
+	assert.Eventually(t, func() bool {
+		return len(bus.events()) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestBackoff(t *testing.T) {
+	relay := NewRelay(nil, nil, RelayConfig{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second})
+	assert.Equal(t, time.Second, relay.backoff(1))
+	assert.Equal(t, 2*time.Second, relay.backoff(2))
+	assert.Equal(t, 4*time.Second, relay.backoff(3))
+	assert.Equal(t, 5*time.Second, relay.backoff(4))
+	assert.Equal(t, 5*time.Second, relay.backoff(40))
+}
@@ -0,0 +1,171 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ebrickdev/ebrick/messaging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPollInterval   = time.Second
+	defaultBatchSize      = 100
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = 5 * time.Minute
+)
+
+// RelayConfig configures a Relay. Zero values select the defaults.
+type RelayConfig struct {
+	PollInterval   time.Duration `yaml:"pollInterval"`   // Time between polls when the outbox is drained, defaults to 1s
+	BatchSize      int           `yaml:"batchSize"`      // Rows locked and published per transaction, defaults to 100
+	MaxAttempts    int           `yaml:"maxAttempts"`    // Failed attempts after which a row is given up, 0 means unlimited
+	InitialBackoff time.Duration `yaml:"initialBackoff"` // Delay after the first failure, doubled per attempt, defaults to 1s
+	MaxBackoff     time.Duration `yaml:"maxBackoff"`     // Upper bound of the retry delay, defaults to 5m
+}
+
+// Relay publishes enqueued events to an event bus.
+//
+// Each batch is selected with FOR UPDATE SKIP LOCKED on PostgreSQL, so several relays
+// can run against the same table. Other databases do not support row locking and must
+// run a single relay. A failed row does not block later rows, so events of a topic may
+// be published out of order after a failure.
+type Relay struct {
+	db  *gorm.DB
+	bus messaging.EventBus
+	cfg RelayConfig
+}
+
+// NewRelay creates a relay that publishes the outbox of db to bus.
+func NewRelay(db *gorm.DB, bus messaging.EventBus, cfg RelayConfig) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	return &Relay{db: db, bus: bus, cfg: cfg}
+}
+
+// Run publishes batches until ctx is cancelled. It polls again immediately after a
+// full batch and waits PollInterval otherwise.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		n, err := r.ProcessBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Outbox: %v", err)
+		}
+
+		if err == nil && n == r.cfg.BatchSize {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.cfg.PollInterval):
+		}
+	}
+}
+
+// ProcessBatch locks up to BatchSize due rows, publishes them and records the outcome.
+// It returns the number of processed rows. Once ctx is cancelled no further row is
+// published, and the rows published so far are still marked sent.
+func (r *Relay) ProcessBatch(ctx context.Context) (int, error) {
+	var processed int
+	// Cancelling the transaction would roll back the rows already marked sent, so
+	// they would be published again.
+	err := r.db.WithContext(context.WithoutCancel(ctx)).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("sent_at IS NULL AND next_attempt_at <= ?", time.Now().UTC())
+		if r.cfg.MaxAttempts > 0 {
+			query = query.Where("attempts < ?", r.cfg.MaxAttempts)
+		}
+		if tx.Dialector.Name() == "postgres" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+
+		var messages []Message
+		if err := query.Order("id").Limit(r.cfg.BatchSize).Find(&messages).Error; err != nil {
+			return fmt.Errorf("failed to select outbox messages: %w", err)
+		}
+
+		for i := range messages {
+			if ctx.Err() != nil {
+				return nil
+			}
+			sent, err := r.publish(ctx, tx, &messages[i])
+			if err != nil {
+				return err
+			}
+			if !sent {
+				return nil
+			}
+			processed++
+		}
+		return nil
+	})
+	if err == nil {
+		err = ctx.Err()
+	}
+	return processed, err
+}
+
+// publish sends one row and marks it sent, or schedules its next attempt. It reports
+// false without touching the row if the publish failed because ctx was cancelled.
+func (r *Relay) publish(ctx context.Context, tx *gorm.DB, msg *Message) (bool, error) {
+	now := time.Now().UTC()
+
+	event, err := msg.Event()
+	if err == nil {
+		err = r.bus.Publish(ctx, msg.Topic, event)
+	}
+	if err == nil {
+		return true, r.update(tx, msg, map[string]any{"sent_at": now, "last_error": ""})
+	}
+	if ctx.Err() != nil {
+		// Stopping is not a failed attempt.
+		return false, nil
+	}
+
+	attempts := msg.Attempts + 1
+	if r.cfg.MaxAttempts > 0 && attempts >= r.cfg.MaxAttempts {
+		log.Printf("Outbox: giving up on message %d (event %s) after %d attempts: %v", msg.ID, msg.EventID, attempts, err)
+	} else {
+		log.Printf("Outbox: failed to publish message %d (event %s), attempt %d: %v", msg.ID, msg.EventID, attempts, err)
+	}
+	return true, r.update(tx, msg, map[string]any{
+		"attempts":        attempts,
+		"last_error":      err.Error(),
+		"next_attempt_at": now.Add(r.backoff(attempts)),
+	})
+}
+
+func (r *Relay) update(tx *gorm.DB, msg *Message, values map[string]any) error {
+	if err := tx.Model(msg).Updates(values).Error; err != nil {
+		return fmt.Errorf("failed to update outbox message %d: %w", msg.ID, err)
+	}
+	return nil
+}
+
+// backoff returns the delay before the next attempt after the given number of failures.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.cfg.InitialBackoff
+	for i := 1; i < attempts && d < r.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.cfg.MaxBackoff {
+		d = r.cfg.MaxBackoff
+	}
+	return d
+}
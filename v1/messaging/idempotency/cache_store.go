@@ -0,0 +1,46 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ebrickdev/ebrick/cache/store"
+)
+
+// CacheStore keeps claims in a cache store, such as the go_cache or redis stores of
+// the cache extensions. Caches offer no atomic set-if-absent, so two copies of an event
+// delivered at the same moment may both be handled. Use RedisStore or the PostgreSQL
+// inbox store where duplicates must never run concurrently.
+type CacheStore struct {
+	cache store.Store
+}
+
+var _ Store = (*CacheStore)(nil)
+
+// NewCacheStore creates a Store backed by a cache store.
+func NewCacheStore(cache store.Store) *CacheStore {
+	return &CacheStore{cache: cache}
+}
+
+// Claim implements Store.
+func (s *CacheStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	_, err := s.cache.Get(ctx, key)
+	if err == nil {
+		return false, nil
+	}
+	var notFound *store.NotFound
+	if !errors.As(err, &notFound) {
+		return false, err
+	}
+
+	if err := s.cache.Set(ctx, key, time.Now().UTC().Format(time.RFC3339), store.WithExpiration(ttl)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Release implements Store.
+func (s *CacheStore) Release(ctx context.Context, key string) error {
+	return s.cache.Delete(ctx, key)
+}
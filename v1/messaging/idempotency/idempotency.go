@@ -0,0 +1,118 @@
+// Package idempotency deduplicates redelivered events before they reach a handler.
+//
+// Events are identified by their CloudEvents source and id. A Deduplicator claims an
+// event in a Store before calling the handler and keeps the claim for a TTL, so an event
+// is handled successfully at most once per TTL window. When the handler fails the claim
+// is released, letting the redelivered event be handled again.
+//
+// The guarantee holds only with a Store that claims atomically: RedisStore, or the
+// Store of the PostgreSQL inbox extension. CacheStore checks and sets in two steps, so
+// copies of an event delivered concurrently may all be handled.
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// DefaultTTL is how long handled events are remembered by default.
+const DefaultTTL = 24 * time.Hour
+
+// Store records claimed event keys.
+type Store interface {
+	// Claim records key for ttl. It reports false if key is already recorded.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Release forgets key so the event can be claimed again.
+	Release(ctx context.Context, key string) error
+}
+
+// Deduplicator wraps event handlers to skip duplicate events.
+type Deduplicator struct {
+	store Store
+	opts  Options
+}
+
+// Options configures a Deduplicator.
+type Options struct {
+	TTL   time.Duration // How long a handled event is remembered
+	Scope string        // Prefix separating the keys of different consumers
+}
+
+// Option defines a function to set Deduplicator options.
+type Option func(opts *Options)
+
+// WithTTL sets how long a handled event is remembered; duplicates arriving later are
+// handled again.
+func WithTTL(ttl time.Duration) Option {
+	return func(opts *Options) {
+		opts.TTL = ttl
+	}
+}
+
+// WithScope separates the keys of different consumers, e.g. by consumer group, so an
+// event handled by one group is not skipped by another sharing the store.
+func WithScope(scope string) Option {
+	return func(opts *Options) {
+		opts.Scope = scope
+	}
+}
+
+// New creates a Deduplicator backed by store.
+func New(store Store, options ...Option) *Deduplicator {
+	opts := Options{TTL: DefaultTTL}
+	for _, o := range options {
+		o(&opts)
+	}
+	return &Deduplicator{store: store, opts: opts}
+}
+
+// Key returns the store key of an event.
+func (d *Deduplicator) Key(event cloudevents.Event) string {
+	return fmt.Sprintf("%s:%s:%s", d.opts.Scope, event.Source(), event.ID())
+}
+
+// Wrap returns a handler that calls handler once per event and TTL window.
+// Duplicates are skipped and reported as handled. If the store fails, the error is
+// returned so the event is redelivered. The result can be passed to
+// NatsEventBus.SubscribeHandler and RedisStream.SubscribeHandler.
+func (d *Deduplicator) Wrap(handler func(ctx context.Context, event cloudevents.Event) error) func(ctx context.Context, event cloudevents.Event) error {
+	return func(ctx context.Context, event cloudevents.Event) error {
+		key := d.Key(event)
+		claimed, err := d.store.Claim(ctx, key, d.opts.TTL)
+		if err != nil {
+			return fmt.Errorf("failed to claim event %s: %w", key, err)
+		}
+		if !claimed {
+			log.Printf("Idempotency: skipping duplicate event %s", key)
+			return nil
+		}
+
+		if err := handler(ctx, event); err != nil {
+			if releaseErr := d.store.Release(ctx, key); releaseErr != nil {
+				log.Printf("Idempotency: failed to release event %s: %v", key, releaseErr)
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+// WrapFunc is Wrap for handlers of messaging.EventBus.Subscribe, which cannot report
+// failures. If the store fails the handler is called anyway, preferring a duplicate
+// over a lost event.
+func (d *Deduplicator) WrapFunc(handler func(ctx context.Context, event cloudevents.Event)) func(ctx context.Context, event cloudevents.Event) {
+	wrapped := d.Wrap(func(ctx context.Context, event cloudevents.Event) error {
+		handler(ctx, event)
+		return nil
+	})
+	return func(ctx context.Context, event cloudevents.Event) {
+		if err := wrapped(ctx, event); err != nil {
+			log.Printf("Idempotency: %v", err)
+			handler(ctx, event)
+		}
+	}
+}
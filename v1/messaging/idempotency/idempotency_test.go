@@ -0,0 +1,195 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/cache/store"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryCache is a minimal store.Store honouring expirations.
+type memoryCache struct {
+	mu      sync.Mutex
+	values  map[string]time.Time // Key to expiry
+	failing bool
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{values: make(map[string]time.Time)}
+}
+
+func (c *memoryCache) Get(ctx context.Context, key any) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failing {
+		return nil, errors.New("cache unavailable")
+	}
+	expiry, ok := c.values[key.(string)]
+	if !ok || time.Now().After(expiry) {
+		return nil, store.NotFoundWithCause(errors.New("missing"))
+	}
+	return "", nil
+}
+
+func (c *memoryCache) GetWithTTL(ctx context.Context, key any) (any, time.Duration, error) {
+	v, err := c.Get(ctx, key)
+	return v, 0, err
+}
+
+func (c *memoryCache) Set(ctx context.Context, key any, value any, options ...store.Option) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key.(string)] = time.Now().Add(store.ApplyOptions(options...).Expiration)
+	return nil
+}
+
+func (c *memoryCache) Delete(ctx context.Context, key any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.values, key.(string))
+	return nil
+}
+
+func (c *memoryCache) Invalidate(ctx context.Context, options ...store.InvalidateOption) error {
+	return nil
+}
+
+func (c *memoryCache) Clear(ctx context.Context) error { return nil }
+
+func (c *memoryCache) GetType() string { return "memory" }
+
+func newTestEvent(id string) cloudevents.Event {
+	event := cloudevents.NewEvent()
+	event.SetID(id)
+	event.SetType("test.event")
+	event.SetSource("idempotency_test")
+	return event
+}
+
+// counter returns a handler counting its calls per event ID that fails while fail is set.
+func counter(fail *bool) (func(ctx context.Context, event cloudevents.Event) error, map[string]int) {
+	calls := map[string]int{}
+	return func(ctx context.Context, event cloudevents.Event) error {
+		calls[event.ID()]++
+		if *fail {
+			return errors.New("handler failed")
+		}
+		return nil
+	}, calls
+}
+
+func TestWrapSkipsDuplicates(t *testing.T) {
+	d := New(NewCacheStore(newMemoryCache()))
+	fail := false
+	handler, calls := counter(&fail)
+	wrapped := d.Wrap(handler)
+	ctx := context.Background()
+
+	require.NoError(t, wrapped(ctx, newTestEvent("1")))
+	require.NoError(t, wrapped(ctx, newTestEvent("1")))
+	require.NoError(t, wrapped(ctx, newTestEvent("2")))
+
+	// The same ID from another source is a different event.
+	other := newTestEvent("1")
+	other.SetSource("elsewhere")
+	require.NoError(t, wrapped(ctx, other))
+
+	assert.Equal(t, map[string]int{"1": 2, "2": 1}, calls)
+}
+
+func TestWrapReleasesFailedEvents(t *testing.T) {
+	d := New(NewCacheStore(newMemoryCache()))
+	fail := true
+	handler, calls := counter(&fail)
+	wrapped := d.Wrap(handler)
+	ctx := context.Background()
+
+	assert.Error(t, wrapped(ctx, newTestEvent("1")))
+	fail = false
+	require.NoError(t, wrapped(ctx, newTestEvent("1")))
+	require.NoError(t, wrapped(ctx, newTestEvent("1")))
+
+	assert.Equal(t, 2, calls["1"])
+}
+
+func TestWrapTTLAndScope(t *testing.T) {
+	cache := newMemoryCache()
+	fail := false
+	handler, calls := counter(&fail)
+	ctx := context.Background()
+
+	billing := New(NewCacheStore(cache), WithScope("billing"), WithTTL(50*time.Millisecond)).Wrap(handler)
+	shipping := New(NewCacheStore(cache), WithScope("shipping")).Wrap(handler)
+
+	require.NoError(t, billing(ctx, newTestEvent("1")))
+	require.NoError(t, shipping(ctx, newTestEvent("1")))
+	require.NoError(t, billing(ctx, newTestEvent("1")))
+	assert.Equal(t, 2, calls["1"])
+
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, billing(ctx, newTestEvent("1")))
+	assert.Equal(t, 3, calls["1"])
+}
+
+func TestStoreFailure(t *testing.T) {
+	cache := newMemoryCache()
+	cache.failing = true
+	d := New(NewCacheStore(cache))
+	ctx := context.Background()
+
+	fail := false
+	handler, calls := counter(&fail)
+	assert.Error(t, d.Wrap(handler)(ctx, newTestEvent("1")))
+	assert.Zero(t, calls["1"])
+
+	// Handlers that cannot report failures are called anyway.
+	called := 0
+	d.WrapFunc(func(ctx context.Context, event cloudevents.Event) { called++ })(ctx, newTestEvent("1"))
+	assert.Equal(t, 1, called)
+}
+
+func TestRedisStoreClaimsOnce(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	d := New(NewRedisStore(client), WithTTL(time.Minute))
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	calls := 0
+	wrapped := d.Wrap(func(ctx context.Context, event cloudevents.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return nil
+	})
+
+	// Copies delivered at the same moment are handled once.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, wrapped(ctx, newTestEvent("1")))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, calls)
+
+	key := d.Key(newTestEvent("1"))
+	assert.Equal(t, time.Minute, mr.TTL(key))
+
+	store := NewRedisStore(client)
+	require.NoError(t, store.Release(ctx, key))
+	claimed, err := store.Claim(ctx, key, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+}
@@ -0,0 +1,163 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Content modes of the CloudEvents Kafka protocol binding.
+const (
+	ContentModeStructured = "structured" // The whole event as a JSON value (default)
+	ContentModeBinary     = "binary"     // Attributes as "ce_" headers, data as the value
+)
+
+// DefaultPartitionKeyExtension is the extension whose value becomes the record key,
+// as defined by the CloudEvents partitioning extension.
+const DefaultPartitionKeyExtension = "partitionkey"
+
+const (
+	headerPrefix          = "ce_"
+	headerContentType     = "content-type"
+	structuredContentType = "application/cloudevents+json"
+)
+
+func validateContentMode(mode string) error {
+	switch mode {
+	case "", ContentModeStructured, ContentModeBinary:
+		return nil
+	}
+	return fmt.Errorf("unsupported content mode %q", mode)
+}
+
+// encodeRecord encodes an event as a Kafka record in the given content mode. The value
+// of the keyExtension extension, if set, becomes the record key so that events sharing
+// it land on the same partition.
+func encodeRecord(topic string, event cloudevents.Event, mode, keyExtension string) (*kgo.Record, error) {
+	rec := &kgo.Record{Topic: topic}
+	if value, ok := event.Extensions()[keyExtension]; ok {
+		key, err := types.Format(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid partition key: %w", err)
+		}
+		rec.Key = []byte(key)
+	}
+
+	if mode != ContentModeBinary {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		rec.Headers = []kgo.RecordHeader{{Key: headerContentType, Value: []byte(structuredContentType)}}
+		rec.Value = data
+		return rec, nil
+	}
+
+	attrs, err := eventAttributes(event)
+	if err != nil {
+		return nil, err
+	}
+	rec.Headers = make([]kgo.RecordHeader, 0, len(attrs)+1)
+	for name, value := range attrs {
+		rec.Headers = append(rec.Headers, kgo.RecordHeader{Key: headerPrefix + name, Value: []byte(value)})
+	}
+	if ct := event.DataContentType(); ct != "" {
+		rec.Headers = append(rec.Headers, kgo.RecordHeader{Key: headerContentType, Value: []byte(ct)})
+	}
+	rec.Value = event.Data()
+	return rec, nil
+}
+
+// decodeRecord decodes a record in either content mode. Records carrying a
+// "ce_specversion" header are in binary mode; anything else is a structured JSON event.
+func decodeRecord(rec *kgo.Record) (cloudevents.Event, error) {
+	attrs := make(map[string]string)
+	var contentType string
+	for _, h := range rec.Headers {
+		key := strings.ToLower(h.Key)
+		switch {
+		case key == headerContentType:
+			contentType = string(h.Value)
+		case strings.HasPrefix(key, headerPrefix):
+			attrs[strings.TrimPrefix(key, headerPrefix)] = string(h.Value)
+		}
+	}
+
+	if _, ok := attrs["specversion"]; !ok {
+		var event cloudevents.Event
+		err := json.Unmarshal(rec.Value, &event)
+		return event, err
+	}
+	return eventFromAttributes(attrs, contentType, rec.Value)
+}
+
+// eventAttributes returns the context attributes and extensions of an event as strings,
+// except datacontenttype which protocol bindings carry in their own content type field.
+func eventAttributes(event cloudevents.Event) (map[string]string, error) {
+	attrs := map[string]string{
+		"specversion": event.SpecVersion(),
+		"id":          event.ID(),
+		"source":      event.Source(),
+		"type":        event.Type(),
+	}
+	if v := event.Subject(); v != "" {
+		attrs["subject"] = v
+	}
+	if v := event.DataSchema(); v != "" {
+		attrs["dataschema"] = v
+	}
+	if v := event.Time(); !v.IsZero() {
+		attrs["time"] = types.FormatTime(v)
+	}
+	for name, value := range event.Extensions() {
+		s, err := types.Format(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extension %s: %w", name, err)
+		}
+		attrs[name] = s
+	}
+	return attrs, nil
+}
+
+// eventFromAttributes builds an event from string attributes as produced by eventAttributes.
+// Unknown attributes become extensions.
+func eventFromAttributes(attrs map[string]string, contentType string, data []byte) (cloudevents.Event, error) {
+	event := cloudevents.NewEvent(attrs["specversion"])
+	for name, value := range attrs {
+		switch name {
+		case "specversion":
+		case "id":
+			event.SetID(value)
+		case "source":
+			event.SetSource(value)
+		case "type":
+			event.SetType(value)
+		case "subject":
+			event.SetSubject(value)
+		case "dataschema":
+			event.SetDataSchema(value)
+		case "time":
+			t, err := types.ParseTime(value)
+			if err != nil {
+				return cloudevents.Event{}, fmt.Errorf("invalid time attribute: %w", err)
+			}
+			event.SetTime(t)
+		default:
+			event.SetExtension(name, value)
+		}
+	}
+	if contentType != "" {
+		event.SetDataContentType(contentType)
+	}
+	if len(data) > 0 {
+		event.DataEncoded = data
+	}
+	if err := event.Validate(); err != nil {
+		return cloudevents.Event{}, err
+	}
+	return event, nil
+}
@@ -0,0 +1,79 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func newBindingEvent() cloudevents.Event {
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetType("order.created")
+	event.SetSource("kafka_test")
+	event.SetSubject("orders/1")
+	event.SetTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	event.SetExtension(DefaultPartitionKeyExtension, "customer-1")
+	_ = event.SetData(cloudevents.ApplicationJSON, map[string]string{"id": "1"})
+	return event
+}
+
+func header(rec *kgo.Record, key string) string {
+	for _, h := range rec.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestEncodeRecordStructured(t *testing.T) {
+	event := newBindingEvent()
+
+	rec, err := encodeRecord("orders", event, ContentModeStructured, DefaultPartitionKeyExtension)
+	require.NoError(t, err)
+	assert.Equal(t, "orders", rec.Topic)
+	assert.Equal(t, "customer-1", string(rec.Key))
+	assert.Equal(t, structuredContentType, header(rec, headerContentType))
+
+	decoded, err := decodeRecord(rec)
+	require.NoError(t, err)
+	assert.Equal(t, event.String(), decoded.String())
+}
+
+func TestEncodeRecordBinary(t *testing.T) {
+	event := newBindingEvent()
+
+	rec, err := encodeRecord("orders", event, ContentModeBinary, DefaultPartitionKeyExtension)
+	require.NoError(t, err)
+	assert.Equal(t, "customer-1", string(rec.Key))
+	assert.Equal(t, "1.0", header(rec, "ce_specversion"))
+	assert.Equal(t, "order.created", header(rec, "ce_type"))
+	assert.Equal(t, "customer-1", header(rec, "ce_partitionkey"))
+	assert.Equal(t, cloudevents.ApplicationJSON, header(rec, headerContentType))
+	assert.JSONEq(t, `{"id":"1"}`, string(rec.Value))
+
+	decoded, err := decodeRecord(rec)
+	require.NoError(t, err)
+	assert.Equal(t, event.String(), decoded.String())
+}
+
+func TestEncodeRecordWithoutPartitionKey(t *testing.T) {
+	event := newBindingEvent()
+
+	rec, err := encodeRecord("orders", event, ContentModeStructured, "tenant")
+	require.NoError(t, err)
+	assert.Nil(t, rec.Key)
+}
+
+func TestDecodeRecordRejectsInvalidEvents(t *testing.T) {
+	_, err := decodeRecord(&kgo.Record{Value: []byte("not json")})
+	assert.Error(t, err)
+
+	_, err = decodeRecord(&kgo.Record{Headers: []kgo.RecordHeader{{Key: "ce_specversion", Value: []byte("1.0")}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,15 @@
+package kafka
+
+import "time"
+
+type KafkaConfig struct {
+	Brokers               []string      `mapstructure:"brokers"`               // host:port of the seed brokers
+	ClientID              string        `mapstructure:"clientId"`              // Client ID reported to the brokers
+	ContentMode           string        `mapstructure:"contentMode"`           // "structured" (default) or "binary"; both are read
+	PartitionKeyExtension string        `mapstructure:"partitionKeyExtension"` // Event extension used as the record key, "partitionkey" by default
+	StartOffset           string        `mapstructure:"startOffset"`           // "latest" (default) or "earliest"; where groups without committed offsets start
+	AutoCreateTopics      bool          `mapstructure:"autoCreateTopics"`      // Let the brokers create unknown topics on publish
+	SessionTimeout        time.Duration `mapstructure:"sessionTimeout"`        // Consumer group session timeout, 0 uses the client default of 45s
+	RetryBackoff          time.Duration `mapstructure:"retryBackoff"`          // Wait before a failed handler is retried, 0 uses 1 second
+	MaxAttempts           int           `mapstructure:"maxAttempts"`           // Handler attempts before a record is skipped, 0 means unlimited
+}
@@ -0,0 +1,305 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/config"
+	"github.com/ebrickdev/ebrick/messaging"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Start offsets of subscriptions without a committed position.
+const (
+	StartOffsetLatest   = "latest"   // Only records produced after the subscription started (default)
+	StartOffsetEarliest = "earliest" // Every record still retained by the topic
+)
+
+const (
+	errorSleepDuration  = time.Second
+	defaultRetryBackoff = time.Second
+	commitTimeout       = 10 * time.Second
+	// maxPollRecords bounds the records handled between two commits.
+	maxPollRecords = 100
+	// defaultShutdownTimeout is how long Close waits for in-flight handlers.
+	defaultShutdownTimeout = 10 * time.Second
+)
+
+var errClosed = errors.New("eventbus is closed")
+
+// Init loads configuration and sets up the default event bus.
+func Init() *KafkaEventBus {
+	var cfg KafkaConfig
+	err := config.LoadConfigByKey("application", "messaging.kafka", []string{"."}, &cfg, map[string]any{})
+	if err != nil {
+		log.Fatalf("Kafka: unable to load Kafka config: %v", err)
+	}
+	bus, err := NewEventBus(&cfg)
+	if err != nil {
+		log.Fatalf("Kafka: %v", err)
+	}
+	return bus
+}
+
+// KafkaEventBus publishes and consumes CloudEvents on Kafka topics.
+// Every subscription uses its own client so that each one can join its consumer group.
+type KafkaEventBus struct {
+	client *kgo.Client // Producing client
+	cfg    KafkaConfig
+
+	mu     sync.Mutex // Protects closed and subs
+	closed bool
+	subs   map[*Subscription]struct{}
+}
+
+// NewEventBus creates a new KafkaEventBus and verifies that a broker is reachable.
+func NewEventBus(cfg *KafkaConfig) (*KafkaEventBus, error) {
+	if err := validateContentMode(cfg.ContentMode); err != nil {
+		return nil, err
+	}
+	if err := validateStartOffset(cfg.StartOffset); err != nil {
+		return nil, err
+	}
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("at least one Kafka broker is required")
+	}
+
+	b := &KafkaEventBus{
+		cfg:  *cfg,
+		subs: make(map[*Subscription]struct{}),
+	}
+	if b.cfg.PartitionKeyExtension == "" {
+		b.cfg.PartitionKeyExtension = DefaultPartitionKeyExtension
+	}
+	if b.cfg.RetryBackoff <= 0 {
+		b.cfg.RetryBackoff = defaultRetryBackoff
+	}
+
+	opts := b.clientOptions()
+	if cfg.AutoCreateTopics {
+		opts = append(opts, kgo.AllowAutoTopicCreation())
+	}
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kafka configuration: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("unable to connect to Kafka: %w", err)
+	}
+	log.Println("Kafka: Kafka event bus initialized successfully")
+
+	b.client = client
+	return b, nil
+}
+
+// clientOptions returns the options shared by the producing and consuming clients.
+func (b *KafkaEventBus) clientOptions() []kgo.Opt {
+	opts := []kgo.Opt{kgo.SeedBrokers(b.cfg.Brokers...)}
+	if b.cfg.ClientID != "" {
+		opts = append(opts, kgo.ClientID(b.cfg.ClientID))
+	}
+	return opts
+}
+
+// Publish encodes the event with the CloudEvents Kafka binding in the configured content
+// mode and waits until the record is acknowledged. The partition key extension, if set,
+// becomes the record key, so that events sharing it keep their order.
+func (b *KafkaEventBus) Publish(ctx context.Context, topic string, event cloudevents.Event) error {
+	if b.isClosed() {
+		return errClosed
+	}
+	if topic == "" {
+		return errors.New("topic must not be empty")
+	}
+	if event.Type() == "" || event.ID() == "" {
+		return errors.New("event must have a valid ID and Type")
+	}
+
+	rec, err := encodeRecord(topic, event, b.cfg.ContentMode, b.cfg.PartitionKeyExtension)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if err := b.client.ProduceSync(ctx, rec).FirstErr(); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+// Subscribe registers a handler for the specified topic. With a consumer group the
+// offsets are committed once the handler returns; use SubscribeHandler to report
+// failures and have records retried, or to obtain a Subscription handle.
+// Subscriptions created here are stopped by Close or Shutdown.
+func (b *KafkaEventBus) Subscribe(topic string, handler func(ctx context.Context, event cloudevents.Event), options ...messaging.SubscriptionOption) error {
+	_, err := b.SubscribeHandler(topic, func(ctx context.Context, event cloudevents.Event) error {
+		handler(ctx, event)
+		return nil
+	}, WithMessagingOptions(options...))
+	return err
+}
+
+// SubscribeHandler subscribes to a topic with a handler that reports failures.
+//   - With a consumer group (SubscriptionOptions.Group) the partitions of the topic are
+//     balanced across the group's members and the offset of a record is committed only
+//     after its handler succeeded. A failing handler is retried after RetryBackoff until
+//     it succeeds or MaxAttempts is reached. Meanwhile only its partition is paused;
+//     the other partitions keep being consumed and rebalances are not held off.
+//   - Without a group every partition is consumed from the start offset and nothing
+//     is committed.
+func (b *KafkaEventBus) SubscribeHandler(topic string, handler Handler, options ...SubscriptionOption) (*Subscription, error) {
+	if b.isClosed() {
+		return nil, errClosed
+	}
+	if topic == "" {
+		return nil, errors.New("topic must not be empty")
+	}
+
+	opts := SubscriptionOptions{
+		StartOffset: b.cfg.StartOffset,
+		MaxAttempts: b.cfg.MaxAttempts,
+	}
+	for _, o := range options {
+		o(&opts)
+	}
+	if err := validateStartOffset(opts.StartOffset); err != nil {
+		return nil, err
+	}
+
+	sub := &Subscription{
+		topic:        topic,
+		group:        opts.Group,
+		name:         opts.Name,
+		maxAttempts:  opts.MaxAttempts,
+		retryBackoff: b.cfg.RetryBackoff,
+		bus:          b,
+		done:         make(chan struct{}),
+		held:         make(map[topicPartition]*heldRecords),
+	}
+
+	clientOpts := append(b.clientOptions(),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(resetOffset(opts.StartOffset)),
+	)
+	if opts.Group != "" {
+		clientOpts = append(clientOpts,
+			kgo.ConsumerGroup(opts.Group),
+			kgo.DisableAutoCommit(),
+			kgo.BlockRebalanceOnPoll(),
+			kgo.OnPartitionsRevoked(sub.revoked),
+			kgo.OnPartitionsLost(sub.revoked),
+		)
+		if b.cfg.SessionTimeout > 0 {
+			clientOpts = append(clientOpts, kgo.SessionTimeout(b.cfg.SessionTimeout))
+		}
+	}
+	client, err := kgo.NewClient(clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to event: %w", err)
+	}
+
+	sub.client = client
+	sub.ctx, sub.cancel = context.WithCancel(context.Background())
+	if err := b.addSubscription(sub); err != nil {
+		sub.cancel()
+		client.Close()
+		return nil, err
+	}
+
+	go sub.consume(handler)
+	if opts.Group != "" {
+		log.Printf("Kafka: Joining consumer group '%s' on topic '%s'", opts.Group, topic)
+	}
+	if opts.Name != "" {
+		log.Printf("Kafka: Subscriber '%s'", opts.Name)
+	}
+	return sub, nil
+}
+
+// Close stops all subscriptions, waits up to 10 seconds for in-flight handlers
+// and closes the Kafka clients. Use Shutdown to control the deadline.
+func (b *KafkaEventBus) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	return b.Shutdown(ctx)
+}
+
+// Shutdown stops polling on all subscriptions, waits for in-flight handlers until ctx
+// expires and closes the Kafka clients. Handlers that did not finish are reported in a
+// *DrainError; their records stay uncommitted and are delivered again.
+func (b *KafkaEventBus) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return errors.New("eventbus is already closed")
+	}
+	b.closed = true
+	subs := make([]*Subscription, 0, len(b.subs))
+	for sub := range b.subs {
+		subs = append(subs, sub)
+	}
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.cancel()
+	}
+
+	var drainErr DrainError
+	for _, sub := range subs {
+		var err *DrainError
+		if errors.As(sub.Drain(ctx), &err) {
+			drainErr.Unfinished = append(drainErr.Unfinished, err.Unfinished...)
+		}
+	}
+
+	b.client.Close()
+	if len(drainErr.Unfinished) > 0 {
+		return &drainErr
+	}
+	return nil
+}
+
+func (b *KafkaEventBus) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// addSubscription registers a subscription unless the bus is closed.
+func (b *KafkaEventBus) addSubscription(sub *Subscription) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errClosed
+	}
+	b.subs[sub] = struct{}{}
+	return nil
+}
+
+func (b *KafkaEventBus) removeSubscription(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, sub)
+}
+
+func validateStartOffset(offset string) error {
+	switch offset {
+	case "", StartOffsetLatest, StartOffsetEarliest:
+		return nil
+	}
+	return fmt.Errorf("unsupported start offset %q", offset)
+}
+
+func resetOffset(offset string) kgo.Offset {
+	if offset == StartOffsetEarliest {
+		return kgo.NewOffset().AtStart()
+	}
+	return kgo.NewOffset().AtEnd()
+}
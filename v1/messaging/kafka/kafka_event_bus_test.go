@@ -0,0 +1,350 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// runCluster starts an in-process fake Kafka cluster with the given topics.
+func runCluster(t *testing.T, topics ...string) *kfake.Cluster {
+	t.Helper()
+
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(3, topics...))
+	require.NoError(t, err)
+	t.Cleanup(cluster.Close)
+	return cluster
+}
+
+func newTestBus(t *testing.T, cluster *kfake.Cluster, cfg KafkaConfig) *KafkaEventBus {
+	t.Helper()
+
+	cfg.Brokers = cluster.ListenAddrs()
+	if cfg.StartOffset == "" {
+		// Consumers join asynchronously, so tests read every record they published.
+		cfg.StartOffset = StartOffsetEarliest
+	}
+	if cfg.RetryBackoff == 0 {
+		cfg.RetryBackoff = 10 * time.Millisecond
+	}
+	bus, err := NewEventBus(&cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bus.Close() })
+	return bus
+}
+
+func newTestEvent(id string) cloudevents.Event {
+	event := cloudevents.NewEvent()
+	event.SetID(id)
+	event.SetType("test.event")
+	event.SetSource("kafka_test")
+	_ = event.SetData(cloudevents.ApplicationJSON, map[string]string{"id": id})
+	return event
+}
+
+// collect returns a handler that forwards event IDs to the returned channel.
+func collect() (func(ctx context.Context, event cloudevents.Event), chan string) {
+	ch := make(chan string, 100)
+	return func(ctx context.Context, event cloudevents.Event) {
+		ch <- event.ID()
+	}, ch
+}
+
+func receive(t *testing.T, ch chan string, n int) []string {
+	t.Helper()
+
+	var ids []string
+	for len(ids) < n {
+		select {
+		case id := <-ch:
+			ids = append(ids, id)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out after receiving %d of %d events", len(ids), n)
+		}
+	}
+	return ids
+}
+
+func assertNoMore(t *testing.T, ch chan string) {
+	t.Helper()
+
+	select {
+	case id := <-ch:
+		t.Fatalf("unexpected event %s", id)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	cluster := runCluster(t, "orders")
+	bus := newTestBus(t, cluster, KafkaConfig{})
+
+	handler, ch := collect()
+	require.NoError(t, bus.Subscribe("orders", handler))
+
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	assert.Equal(t, []string{"1"}, receive(t, ch, 1))
+}
+
+func TestPublishValidation(t *testing.T) {
+	cluster := runCluster(t, "orders")
+	bus := newTestBus(t, cluster, KafkaConfig{})
+
+	assert.Error(t, bus.Publish(context.Background(), "", newTestEvent("1")))
+	assert.Error(t, bus.Publish(context.Background(), "orders", cloudevents.NewEvent()))
+
+	require.NoError(t, bus.Close())
+	assert.Error(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+}
+
+func TestNewEventBusRejectsInvalidConfig(t *testing.T) {
+	tests := []KafkaConfig{
+		{},
+		{Brokers: []string{"127.0.0.1:1"}, ContentMode: "packed"},
+		{Brokers: []string{"127.0.0.1:1"}, StartOffset: "middle"},
+	}
+	for _, cfg := range tests {
+		_, err := NewEventBus(&cfg)
+		assert.Error(t, err, "%+v", cfg)
+	}
+}
+
+func TestEventsWithSamePartitionKeyKeepOrder(t *testing.T) {
+	cluster := runCluster(t, "orders")
+	bus := newTestBus(t, cluster, KafkaConfig{ContentMode: ContentModeBinary})
+
+	handler, ch := collect()
+	require.NoError(t, bus.Subscribe("orders", handler))
+
+	var expected []string
+	for i := 1; i <= 10; i++ {
+		event := newTestEvent(fmt.Sprint(i))
+		event.SetExtension(DefaultPartitionKeyExtension, "customer-1")
+		require.NoError(t, bus.Publish(context.Background(), "orders", event))
+		expected = append(expected, fmt.Sprint(i))
+	}
+	assert.Equal(t, expected, receive(t, ch, len(expected)))
+}
+
+func TestConsumerGroupResumesFromCommittedOffset(t *testing.T) {
+	cluster := runCluster(t, "orders")
+	ctx := context.Background()
+
+	bus := newTestBus(t, cluster, KafkaConfig{})
+	handler, ch := collect()
+	require.NoError(t, bus.Subscribe("orders", handler, messaging.WithConsumerGroup("billing")))
+
+	require.NoError(t, bus.Publish(ctx, "orders", newTestEvent("1")))
+	assert.Equal(t, []string{"1"}, receive(t, ch, 1))
+	require.NoError(t, bus.Close())
+
+	// Events published while the group has no members must not be lost.
+	publisher := newTestBus(t, cluster, KafkaConfig{})
+	require.NoError(t, publisher.Publish(ctx, "orders", newTestEvent("2")))
+	require.NoError(t, publisher.Publish(ctx, "orders", newTestEvent("3")))
+
+	restarted := newTestBus(t, cluster, KafkaConfig{})
+	handler, ch = collect()
+	require.NoError(t, restarted.Subscribe("orders", handler, messaging.WithConsumerGroup("billing")))
+	assert.ElementsMatch(t, []string{"2", "3"}, receive(t, ch, 2))
+	assertNoMore(t, ch)
+}
+
+func TestConsumerGroupBalancesPartitions(t *testing.T) {
+	cluster := runCluster(t, "orders")
+	bus := newTestBus(t, cluster, KafkaConfig{})
+
+	var first, second atomic.Int64
+	ch := make(chan string, 100)
+	for _, count := range []*atomic.Int64{&first, &second} {
+		count := count
+		_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+			count.Add(1)
+			ch <- event.ID()
+			return nil
+		}, WithConsumerGroup("billing"))
+		require.NoError(t, err)
+	}
+
+	var expected []string
+	for i := 1; i <= 20; i++ {
+		event := newTestEvent(fmt.Sprint(i))
+		event.SetExtension(DefaultPartitionKeyExtension, fmt.Sprint(i))
+		require.NoError(t, bus.Publish(context.Background(), "orders", event))
+		expected = append(expected, fmt.Sprint(i))
+	}
+
+	// Each record is handled by exactly one member of the group.
+	assert.ElementsMatch(t, expected, receive(t, ch, len(expected)))
+	assertNoMore(t, ch)
+	assert.Equal(t, int64(20), first.Load()+second.Load())
+}
+
+func TestFailedHandlerIsRetriedBeforeCommit(t *testing.T) {
+	cluster := runCluster(t, "orders")
+	bus := newTestBus(t, cluster, KafkaConfig{})
+
+	var attempts atomic.Int64
+	handled := make(chan string, 10)
+	_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		if attempts.Add(1) == 1 {
+			return fmt.Errorf("temporary failure")
+		}
+		handled <- event.ID()
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	assert.Equal(t, []string{"1"}, receive(t, handled, 1))
+	assert.Equal(t, int64(2), attempts.Load())
+	require.NoError(t, bus.Close())
+
+	// The record was committed once the retry succeeded.
+	restarted := newTestBus(t, cluster, KafkaConfig{})
+	handler, ch := collect()
+	require.NoError(t, restarted.Subscribe("orders", handler, messaging.WithConsumerGroup("billing")))
+	assertNoMore(t, ch)
+}
+
+func TestHandlerPanicIsRetried(t *testing.T) {
+	cluster := runCluster(t, "orders")
+	bus := newTestBus(t, cluster, KafkaConfig{})
+
+	var attempts atomic.Int64
+	handled := make(chan string, 10)
+	_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		if attempts.Add(1) == 1 {
+			panic("boom")
+		}
+		handled <- event.ID()
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	assert.Equal(t, []string{"1"}, receive(t, handled, 1))
+	assert.Equal(t, int64(2), attempts.Load())
+}
+
+func TestMaxAttemptsSkipsRecord(t *testing.T) {
+	cluster := runCluster(t, "orders")
+	bus := newTestBus(t, cluster, KafkaConfig{MaxAttempts: 2})
+
+	attempts := make(chan string, 10)
+	_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		attempts <- event.ID()
+		if event.ID() == "1" {
+			return fmt.Errorf("permanent failure")
+		}
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+
+	event := newTestEvent("1")
+	event.SetExtension(DefaultPartitionKeyExtension, "customer-1")
+	require.NoError(t, bus.Publish(context.Background(), "orders", event))
+	event = newTestEvent("2")
+	event.SetExtension(DefaultPartitionKeyExtension, "customer-1")
+	require.NoError(t, bus.Publish(context.Background(), "orders", event))
+
+	assert.Equal(t, []string{"1", "1", "2"}, receive(t, attempts, 3))
+	assertNoMore(t, attempts)
+}
+
+func TestFailingRecordHoldsBackOnlyItsPartition(t *testing.T) {
+	cluster := runCluster(t, "orders")
+	bus := newTestBus(t, cluster, KafkaConfig{})
+
+	producer, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...), kgo.RecordPartitioner(kgo.ManualPartitioner()))
+	require.NoError(t, err)
+	defer producer.Close()
+	produce := func(partition int32, id string) {
+		rec, err := encodeRecord("orders", newTestEvent(id), ContentModeStructured, DefaultPartitionKeyExtension)
+		require.NoError(t, err)
+		rec.Partition = partition
+		require.NoError(t, producer.ProduceSync(context.Background(), rec).FirstErr())
+	}
+
+	var poisonAttempts atomic.Int64
+	handled := make(chan string, 10)
+	handler := func(ctx context.Context, event cloudevents.Event) error {
+		if event.ID() == "poison" {
+			poisonAttempts.Add(1)
+			return fmt.Errorf("permanent failure")
+		}
+		handled <- event.ID()
+		return nil
+	}
+	_, err = bus.SubscribeHandler("orders", handler, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+
+	produce(0, "poison")
+	produce(0, "behind-poison")
+	produce(1, "1")
+	produce(1, "2")
+	assert.Equal(t, []string{"1", "2"}, receive(t, handled, 2))
+	require.Eventually(t, func() bool { return poisonAttempts.Load() >= 3 }, 5*time.Second, 10*time.Millisecond)
+	assertNoMore(t, handled)
+
+	// A member joining the group is not held off by the failing record.
+	_, err = bus.SubscribeHandler("orders", handler, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	produce(1, "3")
+	produce(2, "4")
+	assert.ElementsMatch(t, []string{"3", "4"}, receive(t, handled, 2))
+	assertNoMore(t, handled)
+}
+
+func TestUnsubscribe(t *testing.T) {
+	cluster := runCluster(t, "orders")
+	bus := newTestBus(t, cluster, KafkaConfig{})
+
+	handler, ch := collect()
+	sub, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		handler(ctx, event)
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	assert.Equal(t, "orders", sub.Topic())
+	assert.Equal(t, "billing", sub.Group())
+
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	assert.Equal(t, []string{"1"}, receive(t, ch, 1))
+
+	require.NoError(t, sub.Drain(context.Background()))
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("2")))
+	assertNoMore(t, ch)
+}
+
+func TestShutdownReportsUnfinishedHandlers(t *testing.T) {
+	cluster := runCluster(t, "orders")
+	bus := newTestBus(t, cluster, KafkaConfig{})
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	require.NoError(t, bus.Subscribe("orders", func(ctx context.Context, event cloudevents.Event) {
+		close(started)
+		<-release
+	}, messaging.WithConsumerGroup("billing")))
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := bus.Shutdown(ctx)
+
+	var drainErr *DrainError
+	require.ErrorAs(t, err, &drainErr)
+	assert.Equal(t, []UnfinishedHandlers{{Topic: "orders", Group: "billing", InFlight: 1}}, drainErr.Unfinished)
+}
@@ -0,0 +1,63 @@
+package kafka
+
+import (
+	"context"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+)
+
+// Handler processes an event and reports whether it succeeded.
+// In consumer groups the offset of a record is committed only once the handler returns
+// nil; a failing handler is retried before the partition moves on.
+type Handler func(ctx context.Context, event cloudevents.Event) error
+
+// SubscriptionOptions extends messaging.SubscriptionOptions with Kafka specific settings.
+type SubscriptionOptions struct {
+	messaging.SubscriptionOptions
+	StartOffset string // Overrides KafkaConfig.StartOffset for a single subscription
+	MaxAttempts int    // Overrides KafkaConfig.MaxAttempts for a single subscription
+}
+
+// SubscriptionOption defines a function to set Kafka subscription options.
+type SubscriptionOption func(opts *SubscriptionOptions)
+
+// WithConsumerGroup specifies the consumer group.
+func WithConsumerGroup(group string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.Group = group
+	}
+}
+
+// WithConsumerName specifies the consumer name.
+func WithConsumerName(name string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.Name = name
+	}
+}
+
+// WithStartOffset selects where a subscription starts, StartOffsetEarliest or
+// StartOffsetLatest. For consumer groups it only applies while the group has no
+// committed offsets.
+func WithStartOffset(offset string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.StartOffset = offset
+	}
+}
+
+// WithMaxAttempts bounds the handler attempts per record; once exhausted the record
+// is logged and skipped.
+func WithMaxAttempts(n int) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.MaxAttempts = n
+	}
+}
+
+// WithMessagingOptions applies the generic messaging subscription options.
+func WithMessagingOptions(options ...messaging.SubscriptionOption) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		for _, o := range options {
+			o(&opts.SubscriptionOptions)
+		}
+	}
+}
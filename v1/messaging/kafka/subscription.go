@@ -0,0 +1,302 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Subscription is a handle to an active subscription created by SubscribeHandler.
+type Subscription struct {
+	topic        string
+	group        string
+	name         string
+	maxAttempts  int
+	retryBackoff time.Duration
+
+	bus      *KafkaEventBus
+	client   *kgo.Client     // Consuming client owned by the subscription
+	ctx      context.Context // Cancelled when the subscription stops polling
+	cancel   context.CancelFunc
+	done     chan struct{} // Closed once the consume loop has returned
+	inFlight atomic.Int64
+
+	mu   sync.Mutex // Serializes handling with revocations, protects held
+	held map[topicPartition]*heldRecords
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// heldRecords are the records of a partition held back behind a failed record, which
+// comes first.
+type heldRecords struct {
+	records  []*kgo.Record
+	attempts int // Failed attempts of the first record
+	retryAt  time.Time
+}
+
+// Topic returns the topic the subscription consumes.
+func (s *Subscription) Topic() string {
+	return s.topic
+}
+
+// Group returns the consumer group of the subscription, if any.
+func (s *Subscription) Group() string {
+	return s.group
+}
+
+// InFlight returns the number of handler invocations currently running.
+func (s *Subscription) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// Unsubscribe stops polling new records. A handler already running is not waited for;
+// its offset is still committed when it succeeds.
+func (s *Subscription) Unsubscribe() error {
+	s.cancel()
+	s.bus.removeSubscription(s)
+	return nil
+}
+
+// Drain stops polling new records and waits for the in-flight handler to finish and
+// its offset to be committed. If ctx expires first, a *DrainError describing the
+// unfinished handler is returned.
+func (s *Subscription) Drain(ctx context.Context) error {
+	_ = s.Unsubscribe()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return &DrainError{Unfinished: []UnfinishedHandlers{s.unfinished()}}
+	}
+}
+
+// consume polls records and hands them to the handler one at a time, in partition order.
+// Rebalances are held off while a batch is processed, so the offsets committed after it
+// always belong to partitions this member still owns. A failing record only holds back
+// its own partition: the partition is paused and the record is retried from the poll
+// loop, so the other partitions keep flowing and rebalances can proceed between retries.
+func (s *Subscription) consume(handler Handler) {
+	defer close(s.done)
+	defer s.client.Close()
+
+	for {
+		fetches := s.poll()
+		if s.ctx.Err() != nil || fetches.IsClientClosed() {
+			s.client.AllowRebalance()
+			return
+		}
+
+		failed := false
+		fetches.EachError(func(topic string, partition int32, err error) {
+			if errors.Is(err, context.DeadlineExceeded) {
+				// The poll woke up to retry a held back record.
+				return
+			}
+			log.Printf("Kafka: fetch failed on topic '%s' partition %d: %v", topic, partition, err)
+			failed = true
+		})
+
+		// A poll that returned no records does not hold off rebalances, so retries are
+		// serialized with revocations by mu instead.
+		s.mu.Lock()
+		handled := s.retryHeld(handler)
+		fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+			tp := topicPartition{topic: p.Topic, partition: p.Partition}
+			if held, ok := s.held[tp]; ok {
+				// Records taken before the partition was paused queue up behind the failed one.
+				held.records = append(held.records, p.Records...)
+				return
+			}
+			handled = append(handled, s.handlePartition(tp, p.Records, 0, handler)...)
+		})
+		s.commit(handled)
+		s.mu.Unlock()
+		s.client.AllowRebalance()
+
+		if failed && len(handled) == 0 {
+			s.sleep(errorSleepDuration)
+		}
+	}
+}
+
+// poll waits for records, or until the next held back record is due for a retry.
+func (s *Subscription) poll() kgo.Fetches {
+	ctx := s.ctx
+	s.mu.Lock()
+	var retryAt time.Time
+	for _, held := range s.held {
+		if retryAt.IsZero() || held.retryAt.Before(retryAt) {
+			retryAt = held.retryAt
+		}
+	}
+	s.mu.Unlock()
+
+	if !retryAt.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, retryAt)
+		defer cancel()
+	}
+	return s.client.PollRecords(ctx, maxPollRecords)
+}
+
+// retryHeld retries the held back partitions whose backoff has elapsed and returns the
+// records handled.
+func (s *Subscription) retryHeld(handler Handler) []*kgo.Record {
+	var handled []*kgo.Record
+	now := time.Now()
+	for tp, held := range s.held {
+		if held.retryAt.After(now) {
+			continue
+		}
+		handled = append(handled, s.handlePartition(tp, held.records, held.attempts, handler)...)
+	}
+	return handled
+}
+
+// handlePartition runs the handler for consecutive records of a partition, the first of
+// which already failed attempts times, and returns the records handled. When a handler
+// fails, the partition is paused and the failed record is held back with the records
+// after it until it is retried after RetryBackoff.
+func (s *Subscription) handlePartition(tp topicPartition, records []*kgo.Record, attempts int, handler Handler) []*kgo.Record {
+	var handled []*kgo.Record
+	for i, rec := range records {
+		if !s.handle(rec, attempts+1, handler) {
+			s.hold(tp, records[i:], attempts+1)
+			return handled
+		}
+		handled = append(handled, rec)
+		attempts = 0
+	}
+	s.release(tp)
+	return handled
+}
+
+// handle runs one attempt of the handler for a record. It reports false if the record
+// should be retried, i.e. the handler failed and the attempts are not exhausted.
+func (s *Subscription) handle(rec *kgo.Record, attempt int, handler Handler) bool {
+	event, err := decodeRecord(rec)
+	if err != nil {
+		// An undecodable record will never succeed, so move past it.
+		log.Printf("Kafka: failed to decode event on topic '%s' partition %d offset %d: %v", rec.Topic, rec.Partition, rec.Offset, err)
+		return true
+	}
+
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+
+	if err := invokeHandler(context.Background(), handler, event); err != nil {
+		log.Printf("Kafka: handler failed on topic '%s' partition %d offset %d (attempt %d): %v", rec.Topic, rec.Partition, rec.Offset, attempt, err)
+		if s.maxAttempts > 0 && attempt >= s.maxAttempts {
+			log.Printf("Kafka: skipping event %s after %d attempts", event.ID(), attempt)
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// invokeHandler calls the handler, converting a panic into an error.
+func invokeHandler(ctx context.Context, handler Handler, event cloudevents.Event) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("handler panicked: %v", rec)
+		}
+	}()
+	return handler(ctx, event)
+}
+
+// hold pauses fetching a partition and keeps its records for a retry.
+func (s *Subscription) hold(tp topicPartition, records []*kgo.Record, attempts int) {
+	if _, ok := s.held[tp]; !ok {
+		s.client.PauseFetchPartitions(map[string][]int32{tp.topic: {tp.partition}})
+	}
+	s.held[tp] = &heldRecords{records: records, attempts: attempts, retryAt: time.Now().Add(s.retryBackoff)}
+}
+
+// release resumes fetching a partition once its held back records are handled.
+func (s *Subscription) release(tp topicPartition) {
+	if _, ok := s.held[tp]; !ok {
+		return
+	}
+	delete(s.held, tp)
+	s.client.ResumeFetchPartitions(map[string][]int32{tp.topic: {tp.partition}})
+}
+
+// revoked drops the held back records of partitions the member no longer owns. They
+// were not committed, so the new owner receives them again.
+func (s *Subscription) revoked(_ context.Context, client *kgo.Client, partitions map[string][]int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for topic, ps := range partitions {
+		for _, p := range ps {
+			delete(s.held, topicPartition{topic: topic, partition: p})
+		}
+	}
+	client.ResumeFetchPartitions(partitions)
+}
+
+// commit commits the offsets of handled records. Subscriptions outside a consumer group
+// have no committed position.
+func (s *Subscription) commit(records []*kgo.Record) {
+	if s.group == "" || len(records) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+	defer cancel()
+	if err := s.client.CommitRecords(ctx, records...); err != nil {
+		log.Printf("Kafka: failed to commit offsets on topic '%s' for group '%s': %v", s.topic, s.group, err)
+	}
+}
+
+// sleep waits for d and reports false if the subscription stopped in the meantime.
+func (s *Subscription) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+func (s *Subscription) unfinished() UnfinishedHandlers {
+	return UnfinishedHandlers{Topic: s.topic, Group: s.group, Name: s.name, InFlight: s.InFlight()}
+}
+
+// UnfinishedHandlers describes handlers of a subscription that were still running
+// when a drain deadline expired.
+type UnfinishedHandlers struct {
+	Topic    string
+	Group    string
+	Name     string
+	InFlight int64
+}
+
+// DrainError is returned by Drain and Shutdown when handlers did not finish in time.
+type DrainError struct {
+	Unfinished []UnfinishedHandlers
+}
+
+func (e *DrainError) Error() string {
+	parts := make([]string, 0, len(e.Unfinished))
+	for _, u := range e.Unfinished {
+		if u.Group != "" {
+			parts = append(parts, fmt.Sprintf("%s (group %s): %d", u.Topic, u.Group, u.InFlight))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s: %d", u.Topic, u.InFlight))
+		}
+	}
+	return "handlers did not finish before the deadline: " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,19 @@
+package memory
+
+type Config struct {
+	Messaging MessagingConfig `yaml:"messaging"`
+}
+
+type MessagingConfig struct {
+	Memory MemoryConfig `yaml:"memory"`
+}
+
+type MemoryConfig struct {
+	// Synchronous delivers events inside Publish, in subscription order, and returns
+	// handler errors from Publish. Intended for deterministic tests.
+	Synchronous bool `yaml:"synchronous"`
+	// BufferSize is the number of events queued per subscription in asynchronous mode
+	// before Publish blocks, or fails with ErrQueueFull when called from a handler.
+	// Defaults to 1024.
+	BufferSize int `yaml:"bufferSize"`
+}
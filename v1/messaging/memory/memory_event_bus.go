@@ -0,0 +1,206 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/config"
+	"github.com/ebrickdev/ebrick/messaging"
+)
+
+const (
+	defaultBufferSize = 1024
+	// defaultShutdownTimeout is how long Close waits for in-flight handlers.
+	defaultShutdownTimeout = 10 * time.Second
+)
+
+var errClosed = errors.New("eventbus is closed")
+
+// ErrQueueFull is returned by Publish when a handler publishes to a subscription whose
+// queue is full. Waiting could deadlock: the handler may be the worker emptying that
+// queue, or the target's handler may be waiting for room in the handler's own queue.
+var ErrQueueFull = errors.New("subscription queue is full")
+
+func Init() messaging.EventBus {
+	var cfg Config
+	err := config.LoadConfig("application", []string{"."}, &cfg)
+	if err != nil {
+		log.Fatalf("Memory: error loading config %v", err)
+	}
+	return NewEventBus(&cfg.Messaging.Memory)
+}
+
+// MemoryEventBus delivers events between subscribers of the same process.
+// Topics follow NATS subject semantics, including the '*' and '>' wildcards in
+// subscriptions, and subscribers sharing a consumer group receive each event once,
+// round-robin.
+type MemoryEventBus struct {
+	cfg MemoryConfig
+
+	mu     sync.RWMutex // Protects closed, subs and next
+	closed bool
+	subs   []*Subscription   // In subscription order
+	next   map[string]uint64 // Round-robin position of each consumer group
+}
+
+// NewEventBus creates a new MemoryEventBus.
+func NewEventBus(cfg *MemoryConfig) *MemoryEventBus {
+	b := &MemoryEventBus{cfg: *cfg, next: make(map[string]uint64)}
+	if b.cfg.BufferSize <= 0 {
+		b.cfg.BufferSize = defaultBufferSize
+	}
+	return b
+}
+
+// Publish delivers the event to every matching subscription and to one member of
+// every matching consumer group. Each subscriber receives its own copy of the event.
+//
+// In synchronous mode the handlers run before Publish returns and their errors are
+// returned. Otherwise the event is queued and Publish only blocks while a queue is full.
+// A handler publishing to a full queue gets ErrQueueFull instead of blocking,
+// provided it passes on the context it was called with.
+func (b *MemoryEventBus) Publish(ctx context.Context, topic string, event cloudevents.Event) error {
+	if err := validateTopic(topic); err != nil {
+		return err
+	}
+	if event.Type() == "" || event.ID() == "" {
+		return errors.New("event must have a valid ID and Type")
+	}
+
+	targets, err := b.route(topic)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, sub := range targets {
+		if err := sub.deliver(ctx, event.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// route selects the subscriptions receiving an event published on topic.
+func (b *MemoryEventBus) route(topic string) ([]*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, errClosed
+	}
+
+	var targets []*Subscription
+	groups := make(map[string][]*Subscription)
+	var order []string
+	for _, sub := range b.subs {
+		if !matchSubject(sub.topic, topic) {
+			continue
+		}
+		if sub.group == "" {
+			targets = append(targets, sub)
+			continue
+		}
+		if _, ok := groups[sub.group]; !ok {
+			order = append(order, sub.group)
+		}
+		groups[sub.group] = append(groups[sub.group], sub)
+	}
+
+	for _, group := range order {
+		members := groups[group]
+		targets = append(targets, members[b.next[group]%uint64(len(members))])
+		b.next[group]++
+	}
+	return targets, nil
+}
+
+// Subscribe registers a handler for the specified topic.
+// Subscriptions created here are stopped by Close or Shutdown; use SubscribeHandler
+// to obtain a Subscription handle or to report handler failures.
+func (b *MemoryEventBus) Subscribe(topic string, handler func(ctx context.Context, event cloudevents.Event), options ...messaging.SubscriptionOption) error {
+	_, err := b.SubscribeHandler(topic, func(ctx context.Context, event cloudevents.Event) error {
+		handler(ctx, event)
+		return nil
+	}, WithMessagingOptions(options...))
+	return err
+}
+
+// SubscribeHandler registers a handler that reports failures and returns a handle
+// to unsubscribe or drain the subscription.
+func (b *MemoryEventBus) SubscribeHandler(topic string, handler Handler, options ...SubscriptionOption) (*Subscription, error) {
+	if err := validateSubject(topic); err != nil {
+		return nil, err
+	}
+
+	opts := SubscriptionOptions{}
+	for _, o := range options {
+		o(&opts)
+	}
+
+	sub := newSubscription(b, topic, handler, opts)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, errClosed
+	}
+	b.subs = append(b.subs, sub)
+	if !b.cfg.Synchronous {
+		sub.start(b.cfg.BufferSize)
+	}
+	return sub, nil
+}
+
+func (b *MemoryEventBus) removeSubscription(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, s := range b.subs {
+		if s == sub {
+			b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Close stops accepting events and waits up to 10 seconds for queued events to be
+// handled. Use Shutdown to control the deadline.
+func (b *MemoryEventBus) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	return b.Shutdown(ctx)
+}
+
+// Shutdown stops accepting events and drains all subscriptions until ctx expires.
+// Handlers that did not finish are reported in a *DrainError.
+func (b *MemoryEventBus) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return errors.New("eventbus is already closed")
+	}
+	b.closed = true
+	subs := append([]*Subscription(nil), b.subs...)
+	b.mu.Unlock()
+
+	var drainErr DrainError
+	for _, sub := range subs {
+		var err *DrainError
+		if errors.As(sub.Drain(ctx), &err) {
+			drainErr.Unfinished = append(drainErr.Unfinished, err.Unfinished...)
+		}
+	}
+	if len(drainErr.Unfinished) > 0 {
+		return &drainErr
+	}
+	return nil
+}
+
+// handlerError wraps the error of a handler with the subscription it belongs to.
+func handlerError(sub *Subscription, err error) error {
+	return fmt.Errorf("handler on topic %s failed: %w", sub.topic, err)
+}
@@ -0,0 +1,324 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBus(t *testing.T, cfg MemoryConfig) *MemoryEventBus {
+	t.Helper()
+
+	bus := NewEventBus(&cfg)
+	t.Cleanup(func() { _ = bus.Close() })
+	return bus
+}
+
+func newTestEvent(id string) cloudevents.Event {
+	event := cloudevents.NewEvent()
+	event.SetID(id)
+	event.SetType("test.event")
+	event.SetSource("memory_test")
+	return event
+}
+
+// record returns a handler appending "<name>:<event id>" to log.
+func record(log *[]string, name string) func(ctx context.Context, event cloudevents.Event) {
+	return func(ctx context.Context, event cloudevents.Event) {
+		*log = append(*log, name+":"+event.ID())
+	}
+}
+
+func TestSynchronousDelivery(t *testing.T) {
+	bus := newTestBus(t, MemoryConfig{Synchronous: true})
+	ctx := context.Background()
+
+	var log []string
+	require.NoError(t, bus.Subscribe("orders.created", record(&log, "exact")))
+	require.NoError(t, bus.Subscribe("orders.*", record(&log, "star")))
+	require.NoError(t, bus.Subscribe("orders.>", record(&log, "tail")))
+	require.NoError(t, bus.Subscribe("payments.>", record(&log, "other")))
+
+	require.NoError(t, bus.Publish(ctx, "orders.created", newTestEvent("1")))
+	require.NoError(t, bus.Publish(ctx, "orders.created.eu", newTestEvent("2")))
+
+	assert.Equal(t, []string{"exact:1", "star:1", "tail:1", "tail:2"}, log)
+}
+
+func TestConsumerGroupsLoadBalance(t *testing.T) {
+	bus := newTestBus(t, MemoryConfig{Synchronous: true})
+	ctx := context.Background()
+
+	var log []string
+	require.NoError(t, bus.Subscribe("orders", record(&log, "a"), messaging.WithConsumerGroup("billing")))
+	require.NoError(t, bus.Subscribe("orders", record(&log, "b"), messaging.WithConsumerGroup("billing")))
+	require.NoError(t, bus.Subscribe("orders", record(&log, "audit")))
+
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, bus.Publish(ctx, "orders", newTestEvent(fmt.Sprint(i))))
+	}
+
+	assert.Equal(t, []string{
+		"audit:1", "a:1",
+		"audit:2", "b:2",
+		"audit:3", "a:3",
+		"audit:4", "b:4",
+	}, log)
+}
+
+func TestSynchronousPublishReturnsHandlerErrors(t *testing.T) {
+	bus := newTestBus(t, MemoryConfig{Synchronous: true})
+
+	_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		return errors.New("boom")
+	})
+	require.NoError(t, err)
+
+	err = bus.Publish(context.Background(), "orders", newTestEvent("1"))
+	assert.ErrorContains(t, err, "boom")
+}
+
+func TestAsynchronousDelivery(t *testing.T) {
+	bus := newTestBus(t, MemoryConfig{BufferSize: 1})
+
+	received := make(chan string, 10)
+	sub, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		time.Sleep(5 * time.Millisecond)
+		received <- event.ID()
+		return nil
+	})
+	require.NoError(t, err)
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent(fmt.Sprint(i))))
+	}
+	require.NoError(t, sub.Drain(context.Background()))
+
+	close(received)
+	var ids []string
+	for id := range received {
+		ids = append(ids, id)
+	}
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, ids, "events are handled in order")
+}
+
+func TestHandlerPublishingToItsFullQueueFails(t *testing.T) {
+	bus := newTestBus(t, MemoryConfig{BufferSize: 1})
+
+	errs := make(chan error, 3)
+	_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		if event.ID() != "1" {
+			return nil
+		}
+		for i := 2; i <= 4; i++ {
+			errs <- bus.Publish(ctx, "orders", newTestEvent(fmt.Sprint(i)))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+
+	var results []error
+	for len(results) < 3 {
+		select {
+		case err := <-errs:
+			results = append(results, err)
+		case <-time.After(time.Second):
+			t.Fatal("handler deadlocked publishing to its own subscription")
+		}
+	}
+	assert.NoError(t, results[0])
+	assert.ErrorIs(t, results[1], ErrQueueFull)
+	assert.ErrorIs(t, results[2], ErrQueueFull)
+}
+
+func TestHandlersPublishingToEachOtherDoNotDeadlock(t *testing.T) {
+	bus := newTestBus(t, MemoryConfig{BufferSize: 1})
+
+	done := make(chan string, 2)
+	var started sync.WaitGroup
+	started.Add(2)
+	relay := func(name, to string) Handler {
+		return func(ctx context.Context, event cloudevents.Event) error {
+			if event.ID() != "start" {
+				return nil
+			}
+			// Both handlers run before either publishes, so both queues fill up.
+			started.Done()
+			started.Wait()
+			for i := 0; i < 3; i++ {
+				_ = bus.Publish(ctx, to, newTestEvent(fmt.Sprintf("%s-%d", name, i)))
+			}
+			done <- name
+			return nil
+		}
+	}
+	_, err := bus.SubscribeHandler("a", relay("a", "b"))
+	require.NoError(t, err)
+	_, err = bus.SubscribeHandler("b", relay("b", "a"))
+	require.NoError(t, err)
+
+	require.NoError(t, bus.Publish(context.Background(), "a", newTestEvent("start")))
+	require.NoError(t, bus.Publish(context.Background(), "b", newTestEvent("start")))
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("handlers publishing to each other deadlocked")
+		}
+	}
+}
+
+func TestShutdownRespectsDeadlineWithBlockedPublisher(t *testing.T) {
+	bus := NewEventBus(&MemoryConfig{BufferSize: 1})
+
+	release := make(chan struct{})
+	defer close(release)
+	_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		<-release
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("2")))
+	// Blocks until the worker makes room.
+	go func() { _ = bus.Publish(context.Background(), "orders", newTestEvent("3")) }()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- bus.Shutdown(ctx) }()
+	select {
+	case err := <-shutdown:
+		var drainErr *DrainError
+		assert.ErrorAs(t, err, &drainErr)
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown ignored its deadline")
+	}
+}
+
+func TestHandlerPanicIsRecovered(t *testing.T) {
+	for _, cfg := range []MemoryConfig{{Synchronous: true}, {}} {
+		t.Run(fmt.Sprintf("synchronous=%v", cfg.Synchronous), func(t *testing.T) {
+			bus := newTestBus(t, cfg)
+
+			received := make(chan string, 10)
+			_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+				if event.ID() == "1" {
+					panic("boom")
+				}
+				received <- event.ID()
+				return nil
+			})
+			require.NoError(t, err)
+
+			err = bus.Publish(context.Background(), "orders", newTestEvent("1"))
+			if cfg.Synchronous {
+				assert.ErrorContains(t, err, "handler panicked: boom")
+			}
+			require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("2")))
+			select {
+			case id := <-received:
+				assert.Equal(t, "2", id)
+			case <-time.After(time.Second):
+				t.Fatal("event not delivered after the panic")
+			}
+		})
+	}
+}
+
+func TestEventsAreCopied(t *testing.T) {
+	bus := newTestBus(t, MemoryConfig{Synchronous: true})
+
+	require.NoError(t, bus.Subscribe("orders", func(ctx context.Context, event cloudevents.Event) {
+		event.SetExtension("seen", "yes")
+	}))
+	event := newTestEvent("1")
+	require.NoError(t, bus.Publish(context.Background(), "orders", event))
+	assert.NotContains(t, event.Extensions(), "seen")
+}
+
+func TestUnsubscribe(t *testing.T) {
+	bus := newTestBus(t, MemoryConfig{Synchronous: true})
+
+	var log []string
+	sub, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		record(&log, "sub")(ctx, event)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "orders", sub.Topic())
+
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	require.NoError(t, sub.Unsubscribe())
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("2")))
+	assert.Equal(t, []string{"sub:1"}, log)
+}
+
+func TestValidation(t *testing.T) {
+	bus := newTestBus(t, MemoryConfig{})
+	handler := func(ctx context.Context, event cloudevents.Event) {}
+
+	for _, topic := range []string{"", "orders.", "orders..created", "orders.>.eu", "orders.cre*"} {
+		assert.Error(t, bus.Subscribe(topic, handler), topic)
+	}
+	for _, topic := range []string{"orders.*", "orders.>"} {
+		assert.Error(t, bus.Publish(context.Background(), topic, newTestEvent("1")), topic)
+	}
+	assert.Error(t, bus.Publish(context.Background(), "orders", cloudevents.NewEvent()))
+}
+
+func TestShutdown(t *testing.T) {
+	bus := NewEventBus(&MemoryConfig{})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	require.NoError(t, bus.Subscribe("orders", func(ctx context.Context, event cloudevents.Event) {
+		close(started)
+		<-release
+	}, messaging.WithConsumerGroup("billing")))
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := bus.Shutdown(ctx)
+
+	var drainErr *DrainError
+	require.ErrorAs(t, err, &drainErr)
+	assert.Equal(t, []UnfinishedHandlers{{Topic: "orders", Group: "billing", InFlight: 1}}, drainErr.Unfinished)
+	close(release)
+
+	assert.Error(t, bus.Publish(context.Background(), "orders", newTestEvent("2")))
+	assert.Error(t, bus.Subscribe("orders", func(ctx context.Context, event cloudevents.Event) {}))
+	assert.Error(t, bus.Close())
+}
+
+func TestMatchSubject(t *testing.T) {
+	tests := []struct {
+		subject, topic string
+		match          bool
+	}{
+		{"orders", "orders", true},
+		{"orders", "orders.created", false},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders", false},
+		{"orders.*", "orders.created.eu", false},
+		{"*.created", "orders.created", true},
+		{"orders.>", "orders.created.eu", true},
+		{"orders.>", "orders", false},
+		{">", "orders", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, matchSubject(tt.subject, tt.topic), "%s ~ %s", tt.subject, tt.topic)
+	}
+}
@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"context"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+)
+
+// Handler processes an event and reports whether it succeeded.
+// In synchronous mode a handler error is returned from Publish; otherwise it is logged.
+type Handler func(ctx context.Context, event cloudevents.Event) error
+
+// SubscriptionOptions extends messaging.SubscriptionOptions with in-memory specific settings.
+type SubscriptionOptions struct {
+	messaging.SubscriptionOptions
+}
+
+// SubscriptionOption defines a function to set in-memory subscription options.
+type SubscriptionOption func(opts *SubscriptionOptions)
+
+// WithConsumerGroup specifies the consumer group.
+func WithConsumerGroup(group string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.Group = group
+	}
+}
+
+// WithConsumerName specifies the consumer name.
+func WithConsumerName(name string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.Name = name
+	}
+}
+
+// WithMessagingOptions applies the generic messaging subscription options.
+func WithMessagingOptions(options ...messaging.SubscriptionOption) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		for _, o := range options {
+			o(&opts.SubscriptionOptions)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateSubject checks a subscription subject. Tokens are separated by '.', '*'
+// matches a single token and '>' matches one or more trailing tokens, as in NATS.
+func validateSubject(subject string) error {
+	if subject == "" {
+		return fmt.Errorf("topic must not be empty")
+	}
+	tokens := strings.Split(subject, ".")
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return fmt.Errorf("invalid topic %q: empty token", subject)
+		case token == ">" && i != len(tokens)-1:
+			return fmt.Errorf("invalid topic %q: '>' must be the last token", subject)
+		case token != "*" && token != ">" && strings.ContainsAny(token, "*> \t\n"):
+			return fmt.Errorf("invalid topic %q: wildcards must be whole tokens", subject)
+		}
+	}
+	return nil
+}
+
+// validateTopic checks a publish topic, which may not contain wildcards.
+func validateTopic(topic string) error {
+	if err := validateSubject(topic); err != nil {
+		return err
+	}
+	for _, token := range strings.Split(topic, ".") {
+		if token == "*" || token == ">" {
+			return fmt.Errorf("invalid topic %q: cannot publish to a wildcard", topic)
+		}
+	}
+	return nil
+}
+
+// matchSubject reports whether a published topic matches a subscription subject.
+func matchSubject(subject, topic string) bool {
+	patterns := strings.Split(subject, ".")
+	tokens := strings.Split(topic, ".")
+	for i, pattern := range patterns {
+		switch {
+		case pattern == ">":
+			return len(tokens) > i
+		case i >= len(tokens):
+			return false
+		case pattern != "*" && pattern != tokens[i]:
+			return false
+		}
+	}
+	return len(patterns) == len(tokens)
+}
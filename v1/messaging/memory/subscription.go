@@ -0,0 +1,264 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// handlingKey marks the context of a handler with its asynchronous subscription.
+type handlingKey struct{}
+
+// delivery is an event queued for an asynchronous subscription.
+type delivery struct {
+	ctx   context.Context
+	event cloudevents.Event
+}
+
+// Subscription is a handle to an active subscription created by SubscribeHandler.
+type Subscription struct {
+	topic   string
+	group   string
+	name    string
+	handler Handler
+	bus     *MemoryEventBus
+
+	// Senders register in senders under the read lock, so once stopped is set under
+	// the write lock no further sender can start. Draining waits for the registered
+	// ones before the worker exits.
+	mu       sync.RWMutex
+	stopped  bool
+	senders  sync.WaitGroup
+	queue    chan delivery // Nil in synchronous mode
+	quit     chan struct{} // Closed to drop queued events
+	draining chan struct{} // Closed to handle queued events and exit
+	done     chan struct{} // Closed when the worker exits
+
+	handlers sync.WaitGroup // Synchronous handler invocations
+	inFlight atomic.Int64
+}
+
+func newSubscription(bus *MemoryEventBus, topic string, handler Handler, opts SubscriptionOptions) *Subscription {
+	return &Subscription{
+		topic:    topic,
+		group:    opts.Group,
+		name:     opts.Name,
+		handler:  handler,
+		bus:      bus,
+		quit:     make(chan struct{}),
+		draining: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Topic returns the subject the subscription listens on.
+func (s *Subscription) Topic() string {
+	return s.topic
+}
+
+// Group returns the consumer group of the subscription, if any.
+func (s *Subscription) Group() string {
+	return s.group
+}
+
+// InFlight returns the number of handler invocations currently running.
+func (s *Subscription) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// Unsubscribe stops delivery immediately. Queued events are discarded and handlers
+// already running are not waited for.
+func (s *Subscription) Unsubscribe() error {
+	s.bus.removeSubscription(s)
+	if s.stop() && s.queue != nil {
+		close(s.quit)
+	}
+	return nil
+}
+
+// Drain stops receiving new events, handles the queued ones and waits for in-flight
+// handlers to finish. If ctx expires first, a *DrainError describing the unfinished
+// handlers is returned.
+func (s *Subscription) Drain(ctx context.Context) error {
+	s.bus.removeSubscription(s)
+	if s.stop() && s.queue != nil {
+		close(s.draining)
+	}
+
+	done := s.done
+	if s.queue == nil {
+		done = make(chan struct{})
+		go func() {
+			s.handlers.Wait()
+			close(done)
+		}()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return &DrainError{Unfinished: []UnfinishedHandlers{s.unfinished()}}
+	}
+}
+
+// stop prevents further deliveries and reports whether the subscription was running.
+func (s *Subscription) stop() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return false
+	}
+	s.stopped = true
+	return true
+}
+
+// start runs the worker of an asynchronous subscription.
+func (s *Subscription) start(bufferSize int) {
+	s.queue = make(chan delivery, bufferSize)
+	go s.work()
+}
+
+func (s *Subscription) work() {
+	defer close(s.done)
+	for {
+		select {
+		case d := <-s.queue:
+			s.handle(d.ctx, d.event)
+		case <-s.quit:
+			return
+		case <-s.draining:
+			s.drain()
+			return
+		}
+	}
+}
+
+// drain handles queued events until no sender is left and the queue is empty.
+func (s *Subscription) drain() {
+	sent := make(chan struct{})
+	go func() {
+		s.senders.Wait()
+		close(sent)
+	}()
+	for {
+		select {
+		case d := <-s.queue:
+			s.handle(d.ctx, d.event)
+		case <-s.quit:
+			return
+		case <-sent:
+			for {
+				select {
+				case d := <-s.queue:
+					s.handle(d.ctx, d.event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver hands an event to the subscription. Synchronous subscriptions run the
+// handler and return its error; asynchronous ones queue the event.
+func (s *Subscription) deliver(ctx context.Context, event cloudevents.Event) error {
+	s.mu.RLock()
+	if s.stopped {
+		s.mu.RUnlock()
+		return nil
+	}
+
+	if s.queue == nil {
+		// The lock is released before the handler runs, so it may unsubscribe.
+		s.handlers.Add(1)
+		s.mu.RUnlock()
+		defer s.handlers.Done()
+		if err := s.invoke(ctx, event); err != nil {
+			return handlerError(s, err)
+		}
+		return nil
+	}
+
+	// The lock is released before sending, so a full queue does not block stop.
+	s.senders.Add(1)
+	s.mu.RUnlock()
+	defer s.senders.Done()
+
+	d := delivery{ctx: context.WithoutCancel(ctx), event: event}
+	if ctx.Value(handlingKey{}) != nil {
+		// Published by a handler. Waiting for room could deadlock when the handlers
+		// of two subscriptions publish to each other, or a handler to its own.
+		select {
+		case s.queue <- d:
+			return nil
+		case <-s.quit:
+			return nil
+		default:
+			return fmt.Errorf("handler published to topic %s: %w", s.topic, ErrQueueFull)
+		}
+	}
+	select {
+	case s.queue <- d:
+		return nil
+	case <-s.quit:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handle runs the handler of an asynchronous subscription and logs failures.
+func (s *Subscription) handle(ctx context.Context, event cloudevents.Event) {
+	if err := s.invoke(context.WithValue(ctx, handlingKey{}, s), event); err != nil {
+		log.Printf("Memory: %v", handlerError(s, err))
+	}
+}
+
+// invoke runs the handler as a tracked invocation, converting a panic into an error.
+func (s *Subscription) invoke(ctx context.Context, event cloudevents.Event) (err error) {
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("handler panicked: %v", rec)
+		}
+	}()
+	return s.handler(ctx, event)
+}
+
+func (s *Subscription) unfinished() UnfinishedHandlers {
+	return UnfinishedHandlers{Topic: s.topic, Group: s.group, Name: s.name, InFlight: s.InFlight(), Queued: len(s.queue)}
+}
+
+// UnfinishedHandlers describes handlers of a subscription that were still running
+// when a drain deadline expired.
+type UnfinishedHandlers struct {
+	Topic    string
+	Group    string
+	Name     string
+	InFlight int64
+	Queued   int // Events not yet handled
+}
+
+// DrainError is returned by Drain and Shutdown when handlers did not finish in time.
+type DrainError struct {
+	Unfinished []UnfinishedHandlers
+}
+
+func (e *DrainError) Error() string {
+	parts := make([]string, 0, len(e.Unfinished))
+	for _, u := range e.Unfinished {
+		if u.Group != "" {
+			parts = append(parts, fmt.Sprintf("%s (group %s): %d running, %d queued", u.Topic, u.Group, u.InFlight, u.Queued))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s: %d running, %d queued", u.Topic, u.InFlight, u.Queued))
+		}
+	}
+	return "handlers did not finish before the deadline: " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,152 @@
+package nats
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"github.com/nats-io/nats.go"
+)
+
+// Content modes of the CloudEvents NATS protocol binding.
+const (
+	ContentModeStructured = "structured" // The whole event as a JSON payload (default)
+	ContentModeBinary     = "binary"     // Attributes as "ce-" headers, data as the payload
+)
+
+const (
+	headerPrefix          = "ce-"
+	headerContentType     = "content-type"
+	structuredContentType = "application/cloudevents+json"
+)
+
+func validateContentMode(mode string) error {
+	switch mode {
+	case "", ContentModeStructured, ContentModeBinary:
+		return nil
+	}
+	return fmt.Errorf("unsupported content mode %q", mode)
+}
+
+// encodeMessage encodes an event as a NATS message in the given content mode.
+func encodeMessage(subject string, event cloudevents.Event, mode string) (*nats.Msg, error) {
+	msg := nats.NewMsg(subject)
+
+	if mode != ContentModeBinary {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		msg.Header.Set(headerContentType, structuredContentType)
+		msg.Data = data
+		return msg, nil
+	}
+
+	attrs, err := eventAttributes(event)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range attrs {
+		msg.Header.Set(headerPrefix+name, value)
+	}
+	if ct := event.DataContentType(); ct != "" {
+		msg.Header.Set(headerContentType, ct)
+	}
+	msg.Data = event.Data()
+	return msg, nil
+}
+
+// decodeMessage decodes a message in either content mode. Messages carrying a
+// "ce-specversion" header are in binary mode; anything else is a structured JSON event.
+func decodeMessage(header nats.Header, data []byte) (cloudevents.Event, error) {
+	attrs := make(map[string]string)
+	var contentType string
+	for key, values := range header {
+		if len(values) == 0 {
+			continue
+		}
+		key = strings.ToLower(key)
+		switch {
+		case key == headerContentType:
+			contentType = values[0]
+		case strings.HasPrefix(key, headerPrefix):
+			attrs[strings.TrimPrefix(key, headerPrefix)] = values[0]
+		}
+	}
+
+	if _, ok := attrs["specversion"]; !ok {
+		var event cloudevents.Event
+		err := json.Unmarshal(data, &event)
+		return event, err
+	}
+	return eventFromAttributes(attrs, contentType, data)
+}
+
+// eventAttributes returns the context attributes and extensions of an event as strings,
+// except datacontenttype which protocol bindings carry in their own content type field.
+func eventAttributes(event cloudevents.Event) (map[string]string, error) {
+	attrs := map[string]string{
+		"specversion": event.SpecVersion(),
+		"id":          event.ID(),
+		"source":      event.Source(),
+		"type":        event.Type(),
+	}
+	if v := event.Subject(); v != "" {
+		attrs["subject"] = v
+	}
+	if v := event.DataSchema(); v != "" {
+		attrs["dataschema"] = v
+	}
+	if v := event.Time(); !v.IsZero() {
+		attrs["time"] = types.FormatTime(v)
+	}
+	for name, value := range event.Extensions() {
+		s, err := types.Format(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extension %s: %w", name, err)
+		}
+		attrs[name] = s
+	}
+	return attrs, nil
+}
+
+// eventFromAttributes builds an event from string attributes as produced by eventAttributes.
+// Unknown attributes become extensions.
+func eventFromAttributes(attrs map[string]string, contentType string, data []byte) (cloudevents.Event, error) {
+	event := cloudevents.NewEvent(attrs["specversion"])
+	for name, value := range attrs {
+		switch name {
+		case "specversion":
+		case "id":
+			event.SetID(value)
+		case "source":
+			event.SetSource(value)
+		case "type":
+			event.SetType(value)
+		case "subject":
+			event.SetSubject(value)
+		case "dataschema":
+			event.SetDataSchema(value)
+		case "time":
+			t, err := types.ParseTime(value)
+			if err != nil {
+				return cloudevents.Event{}, fmt.Errorf("invalid time attribute: %w", err)
+			}
+			event.SetTime(t)
+		default:
+			event.SetExtension(name, value)
+		}
+	}
+	if contentType != "" {
+		event.SetDataContentType(contentType)
+	}
+	if len(data) > 0 {
+		event.DataEncoded = data
+	}
+	if err := event.Validate(); err != nil {
+		return cloudevents.Event{}, err
+	}
+	return event, nil
+}
@@ -0,0 +1,101 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBindingTestEvent() cloudevents.Event {
+	event := newTestEvent("1")
+	event.SetSubject("order-42")
+	event.SetTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	event.SetExtension("partitionkey", "customer-7")
+	return event
+}
+
+func TestBinaryContentMode(t *testing.T) {
+	for _, js := range []JetStreamConfig{{}, {Enabled: true, Storage: "memory"}} {
+		t.Run(fmt.Sprintf("jetstream=%v", js.Enabled), func(t *testing.T) {
+			srv := runServer(t)
+			bus, err := NewEventBus(&NatsConfig{URL: srv.ClientURL(), JetStream: js, ContentMode: ContentModeBinary})
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = bus.Close() })
+
+			raw, err := bus.nc.SubscribeSync("orders")
+			require.NoError(t, err)
+			received := make(chan cloudevents.Event, 1)
+			_, err = bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+				received <- event
+				return nil
+			})
+			require.NoError(t, err)
+			require.NoError(t, bus.nc.Flush())
+
+			sent := newBindingTestEvent()
+			require.NoError(t, bus.Publish(context.Background(), "orders", sent))
+
+			msg, err := raw.NextMsg(5 * time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, "1", msg.Header.Get("ce-id"))
+			assert.Equal(t, "1.0", msg.Header.Get("ce-specversion"))
+			assert.Equal(t, "customer-7", msg.Header.Get("ce-partitionkey"))
+			assert.Equal(t, cloudevents.ApplicationJSON, msg.Header.Get("content-type"))
+			assert.JSONEq(t, `{"id":"1"}`, string(msg.Data))
+
+			select {
+			case event := <-received:
+				assert.Equal(t, sent.Context, event.Context)
+				assert.Equal(t, sent.Data(), event.Data())
+			case <-time.After(5 * time.Second):
+				t.Fatal("event not delivered")
+			}
+		})
+	}
+}
+
+func TestDecodeMessage(t *testing.T) {
+	sent := newBindingTestEvent()
+
+	structured, err := encodeMessage("orders", sent, ContentModeStructured)
+	require.NoError(t, err)
+	assert.Equal(t, structuredContentType, structured.Header.Get("content-type"))
+
+	// Headers as written by another CloudEvents SDK.
+	binary := nats.Header{
+		"ce-specversion":  {"1.0"},
+		"Ce-Id":           {"1"},
+		"ce-source":       {"nats_test"},
+		"ce-type":         {"test.event"},
+		"ce-subject":      {"order-42"},
+		"ce-time":         {"2024-05-01T12:00:00Z"},
+		"ce-partitionkey": {"customer-7"},
+		"Content-Type":    {cloudevents.ApplicationJSON},
+	}
+
+	for name, msg := range map[string]*nats.Msg{
+		"structured": structured,
+		"binary":     {Header: binary, Data: []byte(`{"id":"1"}`)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			event, err := decodeMessage(msg.Header, msg.Data)
+			require.NoError(t, err)
+			assert.Equal(t, sent.Context, event.Context)
+			assert.JSONEq(t, string(sent.Data()), string(event.Data()))
+		})
+	}
+
+	_, err = decodeMessage(nats.Header{"ce-specversion": {"1.0"}}, nil)
+	assert.Error(t, err, "required attributes are missing")
+}
+
+func TestNewEventBusRejectsInvalidContentMode(t *testing.T) {
+	_, err := NewEventBus(&NatsConfig{URL: "nats://127.0.0.1:1", ContentMode: "xml"})
+	assert.Error(t, err)
+}
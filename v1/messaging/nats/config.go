@@ -1,5 +1,7 @@
 package nats
 
+import "time"
+
 type Config struct {
 	Messaging MessagingConfig `yaml:"messaging"`
 }
@@ -9,7 +11,75 @@ type MessagingConfig struct {
 }
 
 type NatsConfig struct {
-	URL      string `yaml:"url"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	URL       string          `yaml:"url"`
+	URLs      []string        `yaml:"urls"` // Servers of a cluster, tried in random order for failover
+	Username  string          `yaml:"username"`
+	Password  string          `yaml:"password"`
+	JetStream JetStreamConfig `yaml:"jetstream"`
+
+	// At most one of Username, Token, NKeySeedFile and CredentialsFile may be set.
+	Token           string    `yaml:"token"`
+	NKeySeedFile    string    `yaml:"nkeySeedFile"`    // File holding the user's NKey seed
+	CredentialsFile string    `yaml:"credentialsFile"` // .creds file with the user JWT and NKey seed
+	TLS             TLSConfig `yaml:"tls"`
+
+	Reconnect ReconnectConfig `yaml:"reconnect"`
+	Scheduler SchedulerConfig `yaml:"scheduler"`
+
+	// ContentMode selects how events are written: "structured" (default) or "binary".
+	// Both modes are accepted when reading.
+	ContentMode string `yaml:"contentMode"`
+}
+
+// JetStreamConfig enables the durable JetStream mode of the event bus.
+// When Enabled is false the bus uses core NATS publish/subscribe. Streams are
+// provisioned per topic on first use; a stream provisioned for a wildcard topic also
+// captures the topics it matches, so subscribe to wildcards before publishing.
+type JetStreamConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	StreamPrefix string        `yaml:"streamPrefix"` // Prefix for auto-provisioned stream names
+	Storage      string        `yaml:"storage"`      // "file" (default) or "memory"
+	Replicas     int           `yaml:"replicas"`     // Stream replicas, defaults to 1
+	MaxAge       time.Duration `yaml:"maxAge"`       // Maximum age of stored events, 0 keeps them forever
+	AckWait      time.Duration `yaml:"ackWait"`      // Time to wait for an ack before redelivery
+	MaxDeliver   int           `yaml:"maxDeliver"`   // Maximum delivery attempts, 0 means unlimited
+
+	// DeliverPolicy selects where new consumers start: "new" (default), "all",
+	// "by_start_sequence" (uses StartSequence) or "by_start_time" (uses StartTime, RFC3339).
+	DeliverPolicy string `yaml:"deliverPolicy"`
+	StartSequence uint64 `yaml:"startSequence"`
+	StartTime     string `yaml:"startTime"`
+}
+
+// TLSConfig secures the connection to the servers. TLS is also used when the
+// servers require it or their URLs use the tls:// scheme.
+type TLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"caFile"`             // PEM CA bundle used to verify the servers
+	CertFile           string `yaml:"certFile"`           // PEM client certificate for mutual TLS
+	KeyFile            string `yaml:"keyFile"`            // PEM client key
+	ServerName         string `yaml:"serverName"`         // Overrides the server name used for verification
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"` // Disables server certificate verification
+}
+
+// ReconnectConfig controls how the bus recovers from lost connections.
+type ReconnectConfig struct {
+	MaxReconnects int           `yaml:"maxReconnects"` // Attempts per server before the connection is closed, 0 uses 10, -1 retries forever
+	Wait          time.Duration `yaml:"wait"`          // Delay between attempts on the same server, 0 uses 2 seconds
+	Jitter        time.Duration `yaml:"jitter"`        // Random delay added to Wait, 0 uses the client default
+	JitterTLS     time.Duration `yaml:"jitterTLS"`     // Jitter for TLS connections, 0 uses the client default
+	BufferSize    int           `yaml:"bufferSize"`    // Bytes of publishes buffered while reconnecting, 0 uses the client default of 8MB
+
+	// PublishPolicy selects what Publish does while disconnected: "buffer" (default)
+	// keeps events in the reconnect buffer, "fail" returns ErrDisconnected at once.
+	PublishPolicy string `yaml:"publishPolicy"`
+}
+
+// SchedulerConfig enables delayed delivery with PublishAt and PublishAfter. Scheduled
+// events are kept in a JetStream key-value bucket until they are due, so the servers
+// must have JetStream enabled even when the bus uses core NATS.
+type SchedulerConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Bucket   string `yaml:"bucket"`   // Key-value bucket of scheduled events, defaults to "ebrick_scheduled"
+	Replicas int    `yaml:"replicas"` // Bucket replicas, defaults to 1
 }
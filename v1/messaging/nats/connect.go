@@ -0,0 +1,116 @@
+package nats
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// serverURLs joins the configured servers into the comma-separated list nats.Connect expects.
+func serverURLs(cfg *NatsConfig) (string, error) {
+	var urls []string
+	if cfg.URL != "" {
+		urls = append(urls, cfg.URL)
+	}
+	urls = append(urls, cfg.URLs...)
+	if len(urls) == 0 {
+		return "", errors.New("url or urls is required")
+	}
+	return strings.Join(urls, ","), nil
+}
+
+// securityOptions validates the authentication and TLS settings and returns the
+// matching connection options.
+func securityOptions(cfg *NatsConfig) ([]nats.Option, error) {
+	var methods []string
+	if cfg.Username != "" {
+		methods = append(methods, "username")
+	}
+	if cfg.Token != "" {
+		methods = append(methods, "token")
+	}
+	if cfg.NKeySeedFile != "" {
+		methods = append(methods, "nkeySeedFile")
+	}
+	if cfg.CredentialsFile != "" {
+		methods = append(methods, "credentialsFile")
+	}
+	if len(methods) > 1 {
+		return nil, fmt.Errorf("only one authentication method may be configured, got %s", strings.Join(methods, " and "))
+	}
+	if cfg.Password != "" && cfg.Username == "" {
+		return nil, errors.New("password requires username")
+	}
+
+	var opts []nats.Option
+	switch {
+	case cfg.Username != "":
+		opts = append(opts, nats.UserInfo(cfg.Username, cfg.Password))
+	case cfg.Token != "":
+		opts = append(opts, nats.Token(cfg.Token))
+	case cfg.NKeySeedFile != "":
+		opt, err := nats.NkeyOptionFromSeed(cfg.NKeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nkeySeedFile: %w", err)
+		}
+		opts = append(opts, opt)
+	case cfg.CredentialsFile != "":
+		// The credentials are read on every connect; fail early if they cannot be.
+		if _, err := os.ReadFile(cfg.CredentialsFile); err != nil {
+			return nil, fmt.Errorf("invalid credentialsFile: %w", err)
+		}
+		opts = append(opts, nats.UserCredentials(cfg.CredentialsFile))
+	}
+
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, nats.Secure(tlsConfig))
+	}
+	return opts, nil
+}
+
+// newTLSConfig builds the TLS configuration, or returns nil when TLS is not configured.
+// Certificate files imply TLS.
+func newTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled && cfg.CAFile == "" && cfg.CertFile == "" && cfg.KeyFile == "" {
+		return nil, nil
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("tls.certFile and tls.keyFile must be set together")
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,171 @@
+package nats
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nkeys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCert is a certificate with its PEM encoded files.
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// newTestCert creates a certificate signed by parent, or a self-signed CA when parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	c := &testCert{cert: cert, key: key, certFile: filepath.Join(dir, name+".crt"), keyFile: filepath.Join(dir, name+".key")}
+	require.NoError(t, os.WriteFile(c.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(c.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return c
+}
+
+// runSecureServer starts an embedded NATS server with core NATS only.
+func runSecureServer(t *testing.T, configure func(opts *server.Options)) *server.Server {
+	t.Helper()
+
+	opts := &server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true}
+	configure(opts)
+	srv, err := server.NewServer(opts)
+	require.NoError(t, err)
+
+	go srv.Start()
+	require.True(t, srv.ReadyForConnections(5*time.Second), "nats server not ready")
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func connect(t *testing.T, cfg NatsConfig) error {
+	t.Helper()
+
+	bus, err := NewEventBus(&cfg)
+	if err == nil {
+		_ = bus.Close()
+	}
+	return err
+}
+
+func TestTokenAuth(t *testing.T) {
+	srv := runSecureServer(t, func(opts *server.Options) { opts.Authorization = "s3cret" })
+
+	assert.NoError(t, connect(t, NatsConfig{URL: srv.ClientURL(), Token: "s3cret"}))
+	assert.Error(t, connect(t, NatsConfig{URL: srv.ClientURL(), Token: "wrong"}))
+}
+
+func TestNKeyAuth(t *testing.T) {
+	user, err := nkeys.CreateUser()
+	require.NoError(t, err)
+	publicKey, err := user.PublicKey()
+	require.NoError(t, err)
+	seed, err := user.Seed()
+	require.NoError(t, err)
+	seedFile := filepath.Join(t.TempDir(), "user.nk")
+	require.NoError(t, os.WriteFile(seedFile, seed, 0o600))
+
+	srv := runSecureServer(t, func(opts *server.Options) {
+		opts.Nkeys = []*server.NkeyUser{{Nkey: publicKey}}
+	})
+
+	assert.NoError(t, connect(t, NatsConfig{URL: srv.ClientURL(), NKeySeedFile: seedFile}))
+	assert.Error(t, connect(t, NatsConfig{URL: srv.ClientURL()}))
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	serverCert := newTestCert(t, "server", ca)
+	clientCert := newTestCert(t, "client", ca)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	cert, err := tls.LoadX509KeyPair(serverCert.certFile, serverCert.keyFile)
+	require.NoError(t, err)
+	srv := runSecureServer(t, func(opts *server.Options) {
+		opts.TLS = true
+		opts.TLSVerify = true
+		opts.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			MinVersion:   tls.VersionTLS12,
+		}
+	})
+	url := fmt.Sprintf("tls://localhost:%d", srv.Addr().(*net.TCPAddr).Port)
+
+	assert.NoError(t, connect(t, NatsConfig{URL: url, TLS: TLSConfig{
+		CAFile: ca.certFile, CertFile: clientCert.certFile, KeyFile: clientCert.keyFile,
+	}}))
+	assert.Error(t, connect(t, NatsConfig{URL: url, TLS: TLSConfig{CAFile: ca.certFile}}))
+}
+
+func TestClusterURLs(t *testing.T) {
+	srv := runSecureServer(t, func(opts *server.Options) {})
+
+	// The first server is down, the bus fails over to the second one.
+	assert.NoError(t, connect(t, NatsConfig{URLs: []string{"nats://127.0.0.1:1", srv.ClientURL()}}))
+}
+
+func TestSecurityValidation(t *testing.T) {
+	cases := map[string]NatsConfig{
+		"no servers":              {},
+		"several methods":         {URL: "nats://localhost:4222", Username: "u", Password: "p", Token: "t"},
+		"password without user":   {URL: "nats://localhost:4222", Password: "p"},
+		"missing credentials":     {URL: "nats://localhost:4222", CredentialsFile: filepath.Join(t.TempDir(), "missing.creds")},
+		"missing nkey seed":       {URL: "nats://localhost:4222", NKeySeedFile: filepath.Join(t.TempDir(), "missing.nk")},
+		"certificate without key": {URL: "nats://localhost:4222", TLS: TLSConfig{CertFile: "client.crt"}},
+		"missing CA file":         {URL: "nats://localhost:4222", TLS: TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.crt")}},
+	}
+	for name, cfg := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewEventBus(&cfg)
+			assert.Error(t, err)
+		})
+	}
+}
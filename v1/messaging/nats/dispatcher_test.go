@@ -0,0 +1,129 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxInFlightBoundsConcurrency(t *testing.T) {
+	for _, js := range []JetStreamConfig{{}, {Enabled: true, Storage: "memory"}} {
+		t.Run(fmt.Sprintf("jetstream=%v", js.Enabled), func(t *testing.T) {
+			srv := runServer(t)
+			bus := newTestBus(t, srv, js)
+			ctx := context.Background()
+
+			var running, peak, handled atomic.Int32
+			_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				running.Add(-1)
+				handled.Add(1)
+				return nil
+			}, WithConsumerGroup("billing"), WithMaxInFlight(2))
+			require.NoError(t, err)
+			require.NoError(t, bus.nc.Flush())
+
+			for i := 0; i < 10; i++ {
+				require.NoError(t, bus.Publish(ctx, "orders", newTestEvent(fmt.Sprint(i))))
+			}
+
+			assert.Eventually(t, func() bool {
+				return handled.Load() == 10
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.LessOrEqual(t, peak.Load(), int32(2))
+		})
+	}
+}
+
+func TestJetStreamDefaultsToSerialHandlingWithPullAhead(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{Enabled: true, Storage: "memory"})
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	var running, peak atomic.Int32
+	_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		n := running.Add(1)
+		if n > peak.Load() {
+			peak.Store(n)
+		}
+		<-release
+		running.Add(-1)
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, bus.Publish(ctx, "orders", newTestEvent(fmt.Sprint(i))))
+	}
+
+	stream, err := bus.ensureStream(ctx, "orders")
+	require.NoError(t, err)
+	js, err := bus.js.Stream(ctx, stream)
+	require.NoError(t, err)
+
+	// All messages are pulled ahead while the first one is still being handled.
+	assert.Eventually(t, func() bool {
+		consumers := js.ListConsumers(ctx)
+		for info := range consumers.Info() {
+			return info.NumAckPending == 5
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	close(release)
+	assert.Equal(t, int32(1), peak.Load())
+}
+
+func TestOrderingKeyPreservesOrder(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{})
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	seen := map[string][]int{}
+	var handled atomic.Int32
+	_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		seq, _ := strconv.Atoi(event.ID())
+
+		mu.Lock()
+		key := event.Extensions()["partitionkey"].(string)
+		seen[key] = append(seen[key], seq)
+		mu.Unlock()
+		handled.Add(1)
+		return nil
+	}, WithMaxInFlight(4), WithOrderingKey(ExtensionKey("partitionkey")))
+	require.NoError(t, err)
+	require.NoError(t, bus.nc.Flush())
+
+	for i := 0; i < 30; i++ {
+		event := newTestEvent(fmt.Sprint(i))
+		event.SetExtension("partitionkey", fmt.Sprintf("customer-%d", i%3))
+		require.NoError(t, bus.Publish(ctx, "orders", event))
+	}
+
+	require.Eventually(t, func() bool {
+		return handled.Load() == 30
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for key, seqs := range seen {
+		assert.IsIncreasing(t, seqs, key)
+	}
+}
@@ -0,0 +1,191 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PublishFunc publishes an event to a topic.
+type PublishFunc func(ctx context.Context, topic string, event cloudevents.Event) error
+
+// PublishInterceptor runs around Publish. It calls next to continue publishing and may
+// inspect or modify the event, or return an error without calling next.
+type PublishInterceptor func(ctx context.Context, topic string, event cloudevents.Event, next PublishFunc) error
+
+// ConsumeInfo describes the delivery a consume interceptor runs for.
+type ConsumeInfo struct {
+	Topic   string // Subject of the message, which may differ from a wildcard subscription's topic
+	Group   string // Consumer group (queue group or durable), if any
+	Name    string // Consumer name, if any
+	Attempt int    // Delivery attempt reported by JetStream, 1 in core NATS mode
+}
+
+// ConsumeInterceptor runs around every handler invocation. It calls next to invoke the
+// handler and returns the outcome that is used to acknowledge the message.
+type ConsumeInterceptor func(ctx context.Context, event cloudevents.Event, info ConsumeInfo, next Handler) error
+
+// Options configures a NatsEventBus.
+type Options struct {
+	PublishInterceptors []PublishInterceptor // Applied in order, the first one outermost
+	ConsumeInterceptors []ConsumeInterceptor // Applied in order, the first one outermost
+
+	TracerProvider trace.TracerProvider          // Provider of producer and consumer spans, defaults to the global one
+	Propagator     propagation.TextMapPropagator // Carries trace context in events, defaults to W3C Trace Context
+
+	Logger             logger.Logger      // Receives connection events, defaults to logger.DefaultLogger
+	ConnectionListener ConnectionListener // Notified of connection status changes
+
+	Registerer prometheus.Registerer // Receives the bus metrics, none are recorded if nil
+}
+
+// Option defines a function to set event bus options.
+type Option func(opts *Options)
+
+// WithPublishInterceptors appends interceptors that run around every Publish.
+func WithPublishInterceptors(interceptors ...PublishInterceptor) Option {
+	return func(opts *Options) {
+		opts.PublishInterceptors = append(opts.PublishInterceptors, interceptors...)
+	}
+}
+
+// WithConsumeInterceptors appends interceptors that run around every handler invocation.
+func WithConsumeInterceptors(interceptors ...ConsumeInterceptor) Option {
+	return func(opts *Options) {
+		opts.ConsumeInterceptors = append(opts.ConsumeInterceptors, interceptors...)
+	}
+}
+
+// chainPublish wraps publish with the interceptors, the first one outermost.
+func chainPublish(interceptors []PublishInterceptor, publish PublishFunc) PublishFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], publish
+		publish = func(ctx context.Context, topic string, event cloudevents.Event) error {
+			return interceptor(ctx, topic, event, next)
+		}
+	}
+	return publish
+}
+
+// chainConsume wraps handler with the interceptors for one delivery, the first one outermost.
+func chainConsume(interceptors []ConsumeInterceptor, info ConsumeInfo, handler Handler) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, event cloudevents.Event) error {
+			return interceptor(ctx, event, info, next)
+		}
+	}
+	return handler
+}
+
+// Recover converts a panicking handler into an error, so the message is negatively
+// acknowledged instead of crashing the process.
+func Recover() ConsumeInterceptor {
+	return func(ctx context.Context, event cloudevents.Event, info ConsumeInfo, next Handler) (err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = fmt.Errorf("handler panicked: %v\n%s", rec, debug.Stack())
+			}
+		}()
+		return next(ctx, event)
+	}
+}
+
+// LogConsume logs every handler invocation with its outcome and duration. Failures are
+// logged at error level, successes at debug level.
+func LogConsume(log logger.Logger) ConsumeInterceptor {
+	return func(ctx context.Context, event cloudevents.Event, info ConsumeInfo, next Handler) error {
+		start := time.Now()
+		err := next(ctx, event)
+		fields := []logger.Field{
+			logger.String("topic", info.Topic),
+			logger.String("group", info.Group),
+			logger.String("event_id", event.ID()),
+			logger.String("event_type", event.Type()),
+			logger.Int("attempt", info.Attempt),
+			logger.Any("duration", time.Since(start)),
+		}
+		if err != nil {
+			log.Error("Nats: handler failed", append(fields, logger.Error(err))...)
+		} else {
+			log.Debug("Nats: handled event", fields...)
+		}
+		return err
+	}
+}
+
+// LogPublish logs every published event. Failures are logged at error level,
+// successes at debug level.
+func LogPublish(log logger.Logger) PublishInterceptor {
+	return func(ctx context.Context, topic string, event cloudevents.Event, next PublishFunc) error {
+		err := next(ctx, topic, event)
+		fields := []logger.Field{
+			logger.String("topic", topic),
+			logger.String("event_id", event.ID()),
+			logger.String("event_type", event.Type()),
+		}
+		if err != nil {
+			log.Error("Nats: publish failed", append(fields, logger.Error(err))...)
+		} else {
+			log.Debug("Nats: published event", fields...)
+		}
+		return err
+	}
+}
+
+// RetryPolicy configures the retry interceptors.
+type RetryPolicy struct {
+	MaxAttempts    int           // Total attempts including the first, values below 2 disable retries
+	InitialBackoff time.Duration // Delay before the first retry, doubled after each retry
+	MaxBackoff     time.Duration // Upper bound of the delay, 0 means unbounded
+}
+
+// backoff returns the delay before retry n (starting at 1).
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < n && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// retry calls fn until it succeeds, the attempts are exhausted or ctx is done.
+func (p RetryPolicy) retry(ctx context.Context, fn func() error) error {
+	err := fn()
+	for attempt := 1; err != nil && attempt < p.MaxAttempts; attempt++ {
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = fn()
+	}
+	return err
+}
+
+// RetryConsume calls a failing handler again with exponential backoff before the
+// failure is reported, so transient errors do not cause a redelivery.
+func RetryConsume(policy RetryPolicy) ConsumeInterceptor {
+	return func(ctx context.Context, event cloudevents.Event, info ConsumeInfo, next Handler) error {
+		return policy.retry(ctx, func() error { return next(ctx, event) })
+	}
+}
+
+// RetryPublish publishes again with exponential backoff when publishing fails.
+func RetryPublish(policy RetryPolicy) PublishInterceptor {
+	return func(ctx context.Context, topic string, event cloudevents.Event, next PublishFunc) error {
+		return policy.retry(ctx, func() error { return next(ctx, topic, event) })
+	}
+}
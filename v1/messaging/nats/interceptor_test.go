@@ -0,0 +1,151 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingLogger records the messages logged at error level.
+type recordingLogger struct {
+	logger.Logger
+	mu     sync.Mutex
+	errors []string
+}
+
+func (l *recordingLogger) Debug(msg string, fields ...logger.Field) {}
+func (l *recordingLogger) Info(msg string, fields ...logger.Field)  {}
+func (l *recordingLogger) Warn(msg string, fields ...logger.Field)  {}
+
+func (l *recordingLogger) Error(msg string, fields ...logger.Field) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, msg)
+}
+
+func (l *recordingLogger) logged() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.errors...)
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	srv := runServer(t)
+
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, name)
+	}
+	publishInterceptor := func(name string) PublishInterceptor {
+		return func(ctx context.Context, topic string, event cloudevents.Event, next PublishFunc) error {
+			record(name)
+			return next(ctx, topic, event)
+		}
+	}
+	consumeInterceptor := func(name string) ConsumeInterceptor {
+		return func(ctx context.Context, event cloudevents.Event, info ConsumeInfo, next Handler) error {
+			record(name + ":" + info.Topic)
+			return next(ctx, event)
+		}
+	}
+
+	bus := newTestBus(t, srv, JetStreamConfig{},
+		WithPublishInterceptors(publishInterceptor("p1"), publishInterceptor("p2")),
+		WithConsumeInterceptors(consumeInterceptor("c1"), consumeInterceptor("c2")))
+
+	done := make(chan struct{})
+	_, err := bus.SubscribeHandler("orders.>", func(ctx context.Context, event cloudevents.Event) error {
+		record("handler")
+		close(done)
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, bus.Publish(context.Background(), "orders.created", newTestEvent("1")))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"p1", "p2", "c1:orders.created", "c2:orders.created", "handler"}, calls)
+}
+
+func TestPublishInterceptorCanReject(t *testing.T) {
+	srv := runServer(t)
+	rejected := errors.New("rejected")
+	bus := newTestBus(t, srv, JetStreamConfig{}, WithPublishInterceptors(
+		func(ctx context.Context, topic string, event cloudevents.Event, next PublishFunc) error {
+			return rejected
+		}))
+
+	handler, received := collect()
+	require.NoError(t, bus.Subscribe("orders", handler))
+	assert.ErrorIs(t, bus.Publish(context.Background(), "orders", newTestEvent("1")), rejected)
+
+	select {
+	case id := <-received:
+		t.Fatalf("rejected event %s was delivered", id)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestRecoverAndRetryInterceptors(t *testing.T) {
+	srv := runServer(t)
+	log := &recordingLogger{}
+	bus := newTestBus(t, srv, JetStreamConfig{}, WithConsumeInterceptors(
+		LogConsume(log),
+		RetryConsume(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		Recover(),
+	))
+
+	var attempts atomic.Int32
+	done := make(chan struct{})
+	_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		if attempts.Add(1) < 3 {
+			panic("boom")
+		}
+		close(done)
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not retried")
+	}
+	assert.Equal(t, int32(3), attempts.Load())
+	assert.Empty(t, log.logged())
+}
+
+func TestLogConsumeLogsFailures(t *testing.T) {
+	log := &recordingLogger{}
+	handler := chainConsume([]ConsumeInterceptor{LogConsume(log)}, ConsumeInfo{Topic: "orders"},
+		func(ctx context.Context, event cloudevents.Event) error { return errors.New("boom") })
+
+	assert.Error(t, handler(context.Background(), newTestEvent("1")))
+	assert.Equal(t, []string{"Nats: handler failed"}, log.logged())
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 400*time.Millisecond, policy.backoff(3))
+	assert.Equal(t, time.Second, policy.backoff(5))
+}
@@ -0,0 +1,295 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ebrickdev/ebrick/messaging"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	DeliverNew             = "new"
+	DeliverAll             = "all"
+	DeliverByStartSequence = "by_start_sequence"
+	DeliverByStartTime     = "by_start_time"
+)
+
+// jetStreamTimeout bounds the JetStream management calls (stream and consumer provisioning).
+const jetStreamTimeout = 10 * time.Second
+
+// validateJetStreamConfig checks the replay settings before the bus is created.
+func validateJetStreamConfig(cfg JetStreamConfig) error {
+	switch cfg.Storage {
+	case "", "file", "memory":
+	default:
+		return fmt.Errorf("unsupported JetStream storage %q", cfg.Storage)
+	}
+
+	switch cfg.DeliverPolicy {
+	case "", DeliverNew, DeliverAll:
+	case DeliverByStartSequence:
+		if cfg.StartSequence == 0 {
+			return errors.New("JetStream deliver policy by_start_sequence requires startSequence")
+		}
+	case DeliverByStartTime:
+		if _, err := time.Parse(time.RFC3339, cfg.StartTime); err != nil {
+			return fmt.Errorf("JetStream deliver policy by_start_time requires an RFC3339 startTime: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported JetStream deliver policy %q", cfg.DeliverPolicy)
+	}
+	return nil
+}
+
+// streamName derives the JetStream stream name for a topic.
+// Stream names may not contain '.', '*', '>' or whitespace.
+func (b *NatsEventBus) streamName(topic string) string {
+	return b.cfg.JetStream.StreamPrefix + sanitizeName(topic)
+}
+
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\n':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// ensureStream returns the stream capturing a topic, provisioning it if needed.
+// JetStream streams may not capture overlapping subjects, so a stream that already
+// captures the topic, e.g. one provisioned for a wildcard subscription such as
+// orders.*, is reused. A topic that only partially overlaps existing streams, or whose
+// stream name is taken by a stream for other subjects, is rejected.
+func (b *NatsEventBus) ensureStream(ctx context.Context, topic string) (string, error) {
+	b.streamsMu.Lock()
+	defer b.streamsMu.Unlock()
+	if name, ok := b.streams[topic]; ok {
+		return name, nil
+	}
+
+	name, err := b.provisionStream(ctx, topic)
+	if err != nil {
+		return "", err
+	}
+	b.streams[topic] = name
+	return name, nil
+}
+
+func (b *NatsEventBus) provisionStream(ctx context.Context, topic string) (string, error) {
+	names := b.js.StreamNames(ctx, jetstream.WithStreamListSubject(topic))
+	var overlapping []string
+	for name := range names.Name() {
+		stream, err := b.js.Stream(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("failed to look up stream %s: %w", name, err)
+		}
+		if subjectsCover(stream.CachedInfo().Config.Subjects, topic) {
+			return name, nil
+		}
+		overlapping = append(overlapping, name)
+	}
+	if err := names.Err(); err != nil {
+		return "", fmt.Errorf("failed to look up streams for topic %s: %w", topic, err)
+	}
+	if len(overlapping) > 0 {
+		return "", fmt.Errorf("topic %s overlaps the subjects of stream %s: subscribe to wildcard topics before publishing to the topics they match",
+			topic, strings.Join(overlapping, ", "))
+	}
+
+	name := b.streamName(topic)
+	stream, err := b.js.Stream(ctx, name)
+	if err == nil {
+		// Topics differing only in the characters replaced by sanitizeName share a name.
+		return "", fmt.Errorf("stream %s for topic %s already captures %s",
+			name, topic, strings.Join(stream.CachedInfo().Config.Subjects, ", "))
+	}
+	if !errors.Is(err, jetstream.ErrStreamNotFound) {
+		return "", fmt.Errorf("failed to provision stream %s: %w", name, err)
+	}
+
+	storage := jetstream.FileStorage
+	if b.cfg.JetStream.Storage == "memory" {
+		storage = jetstream.MemoryStorage
+	}
+	_, err = b.js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:     name,
+		Subjects: []string{topic},
+		Storage:  storage,
+		Replicas: b.cfg.JetStream.Replicas,
+		MaxAge:   b.cfg.JetStream.MaxAge,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to provision stream %s: %w", name, err)
+	}
+	log.Printf("Nats: Created JetStream stream '%s' for topic '%s'", name, topic)
+	return name, nil
+}
+
+// subjectsCover reports whether one of the subjects, which may contain wildcards,
+// captures every subject matching topic.
+func subjectsCover(subjects []string, topic string) bool {
+	for _, subject := range subjects {
+		if subjectCovers(subject, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func subjectCovers(subject, topic string) bool {
+	want := strings.Split(subject, ".")
+	have := strings.Split(topic, ".")
+	for i, token := range want {
+		switch {
+		case token == ">":
+			return len(have) > i
+		case i >= len(have):
+			return false
+		case token == "*":
+			if have[i] == ">" {
+				return false
+			}
+		case token != have[i]:
+			return false
+		}
+	}
+	return len(want) == len(have)
+}
+
+// consumerConfig builds the consumer configuration for a subscription.
+// Durable consumers are keyed on the consumer group, falling back to the consumer name,
+// and the topic, as a stream may capture several topics; without a group or name an
+// ephemeral consumer is created.
+func (b *NatsEventBus) consumerConfig(topic string, opts messaging.SubscriptionOptions) jetstream.ConsumerConfig {
+	durable := opts.Group
+	if durable == "" {
+		durable = opts.Name
+	}
+	if durable != "" {
+		durable = sanitizeName(durable + "_" + topic)
+	}
+
+	cc := jetstream.ConsumerConfig{
+		Durable:       durable,
+		FilterSubject: topic,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       b.cfg.JetStream.AckWait,
+		MaxDeliver:    b.cfg.JetStream.MaxDeliver,
+	}
+
+	switch b.cfg.JetStream.DeliverPolicy {
+	case DeliverAll:
+		cc.DeliverPolicy = jetstream.DeliverAllPolicy
+	case DeliverByStartSequence:
+		cc.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		cc.OptStartSeq = b.cfg.JetStream.StartSequence
+	case DeliverByStartTime:
+		// Already validated in NewEventBus.
+		start, _ := time.Parse(time.RFC3339, b.cfg.JetStream.StartTime)
+		cc.DeliverPolicy = jetstream.DeliverByStartTimePolicy
+		cc.OptStartTime = &start
+	default:
+		cc.DeliverPolicy = jetstream.DeliverNewPolicy
+	}
+	return cc
+}
+
+// publishJetStream publishes an encoded event and waits for the stream acknowledgement.
+func (b *NatsEventBus) publishJetStream(ctx context.Context, msg *nats.Msg) error {
+	if _, err := b.ensureStream(ctx, msg.Subject); err != nil {
+		return err
+	}
+	if id, ok := ctx.Value(msgIDKey{}).(string); ok {
+		msg.Header.Set(jetstream.MsgIDHeader, id)
+	}
+	if _, err := b.js.PublishMsg(ctx, msg); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+// subscribeJetStream creates (or binds to) a consumer on the topic's stream and
+// acknowledges each message once the handler has succeeded.
+func (b *NatsEventBus) subscribeJetStream(sub *Subscription, handler Handler, opts SubscriptionOptions) (jetstream.ConsumeContext, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), jetStreamTimeout)
+	defer cancel()
+
+	stream, err := b.ensureStream(ctx, sub.topic)
+	if err != nil {
+		return nil, err
+	}
+
+	config := b.consumerConfig(sub.topic, opts.SubscriptionOptions)
+	if config.Durable != "" {
+		// Topics such as orders.eu.* and orders.eu.> map to the same durable name;
+		// updating the consumer would move it to the other topic.
+		existing, err := b.js.Consumer(ctx, stream, config.Durable)
+		switch {
+		case err == nil && existing.CachedInfo().Config.FilterSubject != sub.topic:
+			return nil, fmt.Errorf("durable consumer %s on stream %s already consumes %s",
+				config.Durable, stream, existing.CachedInfo().Config.FilterSubject)
+		case err != nil && !errors.Is(err, jetstream.ErrConsumerNotFound):
+			return nil, fmt.Errorf("failed to look up consumer %s on stream %s: %w", config.Durable, stream, err)
+		}
+	}
+
+	cons, err := b.js.CreateOrUpdateConsumer(ctx, stream, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer on stream %s: %w", stream, err)
+	}
+
+	// Without MaxInFlight the client's default pull batch is kept.
+	var consumeOpts []jetstream.PullConsumeOpt
+	if opts.MaxInFlight > 0 {
+		consumeOpts = append(consumeOpts, jetstream.PullMaxMessages(opts.MaxInFlight))
+	}
+
+	cc, err := cons.Consume(func(msg jetstream.Msg) {
+		event, err := decodeMessage(msg.Headers(), msg.Data())
+		if err != nil {
+			log.Printf("failed to decode event: %v", err)
+			b.metrics.decodeError(msg.Subject(), sub.group)
+			// An undecodable message will never succeed, so stop redelivering it.
+			if err := msg.Term(); err != nil {
+				log.Printf("Nats: failed to terminate message: %v", err)
+			}
+			return
+		}
+
+		info := ConsumeInfo{Topic: msg.Subject(), Group: sub.group, Name: sub.name, Attempt: 1}
+		if meta, err := msg.Metadata(); err == nil {
+			info.Attempt = int(meta.NumDelivered)
+		}
+
+		// Once stopped, leave the message unacknowledged so it is redelivered.
+		sub.dispatch(event, func() {
+			if err := invokeHandler(context.Background(), b.intercept(handler, info), event); err != nil {
+				log.Printf("Nats: handler failed on topic '%s': %v", sub.topic, err)
+				if err := msg.Nak(); err != nil {
+					log.Printf("Nats: failed to negatively acknowledge message: %v", err)
+				}
+				return
+			}
+
+			if err := msg.Ack(); err != nil {
+				log.Printf("Nats: failed to acknowledge message: %v", err)
+				return
+			}
+			b.metrics.ackedEvent(info.Topic, info.Group)
+		})
+	}, consumeOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Nats: JetStream consumer '%s' on stream '%s'", cons.CachedInfo().Name, stream)
+	return cc, nil
+}
@@ -0,0 +1,242 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebrickdev/ebrick/logger"
+	"github.com/nats-io/nats.go"
+)
+
+// Publish policies applied while the connection is down.
+const (
+	PublishPolicyBuffer = "buffer"
+	PublishPolicyFail   = "fail"
+)
+
+const (
+	defaultMaxReconnects = 10
+	defaultReconnectWait = 2 * time.Second
+)
+
+// ErrDisconnected is returned by Publish while the connection is down and the
+// publish policy is "fail".
+var ErrDisconnected = errors.New("not connected to NATS")
+
+// ConnectionState is the state of the bus's connection.
+type ConnectionState string
+
+const (
+	StateConnected    ConnectionState = "connected"
+	StateReconnecting ConnectionState = "reconnecting"
+	StateClosed       ConnectionState = "closed" // Closed by Shutdown or after the reconnect attempts ran out
+)
+
+// ConnectionStatus describes the connection of the bus.
+type ConnectionStatus struct {
+	State      ConnectionState
+	URL        string    // Server of the current or last connection
+	Since      time.Time // When State was entered
+	Reconnects uint64    // Successful reconnects since the bus was created
+	LastError  error     // Last connection or asynchronous error, if any
+}
+
+// ConnectionListener is called with the new status whenever the connection state
+// changes or an asynchronous error occurs. Calls are sequential.
+type ConnectionListener func(status ConnectionStatus)
+
+// WithLogger sets the logger of connection events. By default logger.DefaultLogger
+// is used, or the standard library logger when it is not set.
+func WithLogger(l logger.Logger) Option {
+	return func(opts *Options) {
+		opts.Logger = l
+	}
+}
+
+// WithConnectionListener registers a listener of connection status changes.
+func WithConnectionListener(listener ConnectionListener) Option {
+	return func(opts *Options) {
+		opts.ConnectionListener = listener
+	}
+}
+
+// reconnectOptions validates the reconnect policy and returns the matching connection options.
+func reconnectOptions(cfg ReconnectConfig) ([]nats.Option, error) {
+	switch cfg.PublishPolicy {
+	case "", PublishPolicyBuffer, PublishPolicyFail:
+	default:
+		return nil, fmt.Errorf("invalid reconnect.publishPolicy %q: must be %q or %q", cfg.PublishPolicy, PublishPolicyBuffer, PublishPolicyFail)
+	}
+	if cfg.MaxReconnects < -1 {
+		return nil, fmt.Errorf("invalid reconnect.maxReconnects %d: must be -1 or more", cfg.MaxReconnects)
+	}
+
+	maxReconnects := cfg.MaxReconnects
+	if maxReconnects == 0 {
+		maxReconnects = defaultMaxReconnects
+	}
+	wait := cfg.Wait
+	if wait <= 0 {
+		wait = defaultReconnectWait
+	}
+	opts := []nats.Option{nats.MaxReconnects(maxReconnects), nats.ReconnectWait(wait)}
+
+	if cfg.Jitter > 0 || cfg.JitterTLS > 0 {
+		jitter, jitterTLS := cfg.Jitter, cfg.JitterTLS
+		if jitter <= 0 {
+			jitter = nats.DefaultReconnectJitter
+		}
+		if jitterTLS <= 0 {
+			jitterTLS = nats.DefaultReconnectJitterTLS
+		}
+		opts = append(opts, nats.ReconnectJitter(jitter, jitterTLS))
+	}
+
+	switch {
+	case cfg.PublishPolicy == PublishPolicyFail:
+		// Publishes during a reconnect fail instead of being buffered.
+		opts = append(opts, nats.ReconnectBufSize(-1))
+	case cfg.BufferSize > 0:
+		opts = append(opts, nats.ReconnectBufSize(cfg.BufferSize))
+	}
+	return opts, nil
+}
+
+// connection tracks the connection status and reports lifecycle events.
+type connection struct {
+	log      logger.Logger
+	listener ConnectionListener
+	closing  func() bool // Reports whether the bus is shutting down
+
+	mu     sync.Mutex
+	status ConnectionStatus
+}
+
+func newConnection(opts Options, closing func() bool) *connection {
+	l := opts.Logger
+	if l == nil {
+		l = logger.DefaultLogger
+	}
+	if l == nil {
+		l = stdLogger{}
+	}
+	return &connection{log: l, listener: opts.ConnectionListener, closing: closing}
+}
+
+// options returns the connection callbacks.
+func (c *connection) options() []nats.Option {
+	return []nats.Option{
+		nats.DisconnectErrHandler(c.disconnected),
+		nats.ReconnectHandler(c.reconnected),
+		nats.ClosedHandler(c.closed),
+		nats.ErrorHandler(c.asyncError),
+	}
+}
+
+// connected records the initial connection.
+func (c *connection) connected(nc *nats.Conn) {
+	c.update(func(s *ConnectionStatus) {
+		s.State = StateConnected
+		s.URL = nc.ConnectedUrlRedacted()
+		s.Since = time.Now()
+	})
+}
+
+func (c *connection) disconnected(nc *nats.Conn, err error) {
+	if c.closing() {
+		return
+	}
+	c.log.Warn("Nats: disconnected, reconnecting", logger.String("url", c.Status().URL), logger.Error(err))
+	c.update(func(s *ConnectionStatus) {
+		s.State = StateReconnecting
+		s.Since = time.Now()
+		if err != nil {
+			s.LastError = err
+		}
+	})
+}
+
+func (c *connection) reconnected(nc *nats.Conn) {
+	url := nc.ConnectedUrlRedacted()
+	c.log.Info("Nats: reconnected", logger.String("url", url))
+	c.update(func(s *ConnectionStatus) {
+		s.State = StateConnected
+		s.URL = url
+		s.Since = time.Now()
+		s.Reconnects++
+	})
+}
+
+func (c *connection) closed(nc *nats.Conn) {
+	if c.closing() {
+		c.log.Info("Nats: connection closed")
+	} else {
+		// The client gave up; the bus cannot publish or receive anymore.
+		c.log.Error("Nats: connection closed permanently after exhausting reconnect attempts", logger.Error(nc.LastError()))
+	}
+	c.update(func(s *ConnectionStatus) {
+		s.State = StateClosed
+		s.Since = time.Now()
+		if err := nc.LastError(); err != nil {
+			s.LastError = err
+		}
+	})
+}
+
+func (c *connection) asyncError(nc *nats.Conn, sub *nats.Subscription, err error) {
+	fields := []logger.Field{logger.Error(err)}
+	if sub != nil {
+		fields = append(fields, logger.String("subject", sub.Subject))
+	}
+	c.log.Error("Nats: asynchronous error", fields...)
+	c.update(func(s *ConnectionStatus) {
+		s.LastError = err
+	})
+}
+
+func (c *connection) update(fn func(s *ConnectionStatus)) {
+	c.mu.Lock()
+	fn(&c.status)
+	status := c.status
+	c.mu.Unlock()
+
+	if c.listener != nil {
+		c.listener(status)
+	}
+}
+
+// Status returns the current connection status.
+func (c *connection) Status() ConnectionStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// stdLogger writes through the standard library logger when no logger.Logger is available.
+type stdLogger struct{}
+
+func (stdLogger) Debug(msg string, fields ...logger.Field)  {}
+func (stdLogger) Info(msg string, fields ...logger.Field)   { stdPrint(msg, fields) }
+func (stdLogger) Warn(msg string, fields ...logger.Field)   { stdPrint(msg, fields) }
+func (stdLogger) Error(msg string, fields ...logger.Field)  { stdPrint(msg, fields) }
+func (stdLogger) DPanic(msg string, fields ...logger.Field) { stdPrint(msg, fields) }
+func (stdLogger) Panic(msg string, fields ...logger.Field)  { log.Panic(format(msg, fields)) }
+func (stdLogger) Fatal(msg string, fields ...logger.Field)  { log.Fatal(format(msg, fields)) }
+func (stdLogger) Sync() error                               { return nil }
+
+func stdPrint(msg string, fields []logger.Field) {
+	log.Print(format(msg, fields))
+}
+
+func format(msg string, fields []logger.Field) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, f := range fields {
+		fmt.Fprintf(&b, " %s=%v", f.Key, f.Value)
+	}
+	return b.String()
+}
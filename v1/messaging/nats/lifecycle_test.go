@@ -0,0 +1,81 @@
+package nats
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForState waits until the listener reports state.
+func waitForState(t *testing.T, statuses chan ConnectionStatus, state ConnectionState) ConnectionStatus {
+	t.Helper()
+
+	for {
+		select {
+		case status := <-statuses:
+			if status.State == state {
+				return status
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("connection did not become %s", state)
+		}
+	}
+}
+
+func TestReconnectOptionsValidation(t *testing.T) {
+	_, err := reconnectOptions(ReconnectConfig{PublishPolicy: "drop"})
+	assert.Error(t, err)
+	_, err = reconnectOptions(ReconnectConfig{MaxReconnects: -2})
+	assert.Error(t, err)
+	_, err = reconnectOptions(ReconnectConfig{MaxReconnects: -1, Jitter: time.Second, BufferSize: 1024})
+	assert.NoError(t, err)
+}
+
+func TestReconnectLifecycle(t *testing.T) {
+	srv := runSecureServer(t, func(opts *server.Options) {})
+	port := srv.Addr().(*net.TCPAddr).Port
+
+	statuses := make(chan ConnectionStatus, 100)
+	bus, err := NewEventBus(&NatsConfig{
+		URL:       srv.ClientURL(),
+		Reconnect: ReconnectConfig{MaxReconnects: -1, Wait: 20 * time.Millisecond, PublishPolicy: PublishPolicyFail},
+	}, WithLogger(&recordingLogger{}), WithConnectionListener(func(status ConnectionStatus) {
+		statuses <- status
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bus.Close() })
+	assert.Equal(t, StateConnected, bus.Status().State)
+
+	srv.Shutdown()
+	waitForState(t, statuses, StateReconnecting)
+	assert.ErrorIs(t, bus.Publish(context.Background(), "orders", newTestEvent("1")), ErrDisconnected)
+
+	runSecureServer(t, func(opts *server.Options) { opts.Port = port })
+	status := waitForState(t, statuses, StateConnected)
+	assert.Equal(t, uint64(1), status.Reconnects)
+	assert.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("2")))
+}
+
+func TestConnectionClosedAfterReconnectsExhausted(t *testing.T) {
+	srv := runSecureServer(t, func(opts *server.Options) {})
+	log := &recordingLogger{}
+	statuses := make(chan ConnectionStatus, 100)
+	bus, err := NewEventBus(&NatsConfig{
+		URL:       srv.ClientURL(),
+		Reconnect: ReconnectConfig{MaxReconnects: 1, Wait: 10 * time.Millisecond},
+	}, WithLogger(log), WithConnectionListener(func(status ConnectionStatus) {
+		statuses <- status
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bus.Close() })
+
+	srv.Shutdown()
+	waitForState(t, statuses, StateClosed)
+	assert.Equal(t, StateClosed, bus.Status().State)
+	assert.Contains(t, log.logged(), "Nats: connection closed permanently after exhausting reconnect attempts")
+}
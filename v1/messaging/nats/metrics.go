@@ -0,0 +1,155 @@
+package nats
+
+import (
+	"context"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "ebrick"
+	metricsSubsystem = "nats"
+)
+
+// WithMetrics registers Prometheus metrics of the bus with registerer: published,
+// delivered, acknowledged, failed and undecodable events per topic and group, publish
+// and handler latency, and the connection status. Topics are the subjects of the
+// messages, so wildcard subscriptions report one series per subject. To register
+// several buses with the same registry, wrap it for each of them with
+// prometheus.WrapRegistererWith and a distinguishing label.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(opts *Options) {
+		opts.Registerer = registerer
+	}
+}
+
+// metrics holds the collectors of a bus. A nil *metrics records nothing.
+type metrics struct {
+	published       *prometheus.CounterVec
+	publishFailed   *prometheus.CounterVec
+	publishDuration *prometheus.HistogramVec
+	delivered       *prometheus.CounterVec
+	acked           *prometheus.CounterVec
+	failed          *prometheus.CounterVec
+	decodeErrors    *prometheus.CounterVec
+	handlerDuration *prometheus.HistogramVec
+
+	connectionState *prometheus.Desc
+	reconnects      *prometheus.Desc
+	status          func() ConnectionStatus
+}
+
+func newMetrics(status func() ConnectionStatus) *metrics {
+	counter := func(name, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace, Subsystem: metricsSubsystem, Name: name, Help: help,
+		}, labels)
+	}
+	histogram := func(name, help string, labels ...string) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace, Subsystem: metricsSubsystem, Name: name, Help: help,
+			Buckets: prometheus.DefBuckets,
+		}, labels)
+	}
+
+	return &metrics{
+		published:       counter("events_published_total", "Events published.", "topic"),
+		publishFailed:   counter("publish_failures_total", "Events that failed to publish.", "topic"),
+		publishDuration: histogram("publish_duration_seconds", "Time to publish an event.", "topic"),
+		delivered:       counter("events_delivered_total", "Events delivered to handlers.", "topic", "group"),
+		acked:           counter("events_acked_total", "Events acknowledged after their handler succeeded, JetStream mode only.", "topic", "group"),
+		failed:          counter("events_failed_total", "Events whose handler failed or panicked.", "topic", "group"),
+		decodeErrors:    counter("decode_errors_total", "Messages that could not be decoded into events.", "topic", "group"),
+		handlerDuration: histogram("handler_duration_seconds", "Time spent in handlers.", "topic", "group"),
+
+		connectionState: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, metricsSubsystem, "connection_state"),
+			"Connection state, 1 for the current state.", []string{"state"}, nil),
+		reconnects: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, metricsSubsystem, "reconnects_total"),
+			"Successful reconnects.", nil, nil),
+		status: status,
+	}
+}
+
+// register adds the collectors to registerer, undoing the registrations on failure.
+func (m *metrics) register(registerer prometheus.Registerer) error {
+	var registered []prometheus.Collector
+	for _, c := range []prometheus.Collector{
+		m.published, m.publishFailed, m.publishDuration, m.delivered, m.acked,
+		m.failed, m.decodeErrors, m.handlerDuration, connectionCollector{m},
+	} {
+		if err := registerer.Register(c); err != nil {
+			for _, r := range registered {
+				registerer.Unregister(r)
+			}
+			return err
+		}
+		registered = append(registered, c)
+	}
+	return nil
+}
+
+// publish is the publish interceptor recording published events and their latency.
+func (m *metrics) publish(ctx context.Context, topic string, event cloudevents.Event, next PublishFunc) error {
+	start := time.Now()
+	err := next(ctx, topic, event)
+	m.publishDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.publishFailed.WithLabelValues(topic).Inc()
+		return err
+	}
+	m.published.WithLabelValues(topic).Inc()
+	return nil
+}
+
+// consume is the consume interceptor recording deliveries, failures and handler latency.
+func (m *metrics) consume(ctx context.Context, event cloudevents.Event, info ConsumeInfo, next Handler) (err error) {
+	m.delivered.WithLabelValues(info.Topic, info.Group).Inc()
+	start := time.Now()
+	failed := true // Stays set when the handler panics
+	defer func() {
+		m.handlerDuration.WithLabelValues(info.Topic, info.Group).Observe(time.Since(start).Seconds())
+		if failed {
+			m.failed.WithLabelValues(info.Topic, info.Group).Inc()
+		}
+	}()
+
+	err = next(ctx, event)
+	failed = err != nil
+	return err
+}
+
+func (m *metrics) ackedEvent(topic, group string) {
+	if m != nil {
+		m.acked.WithLabelValues(topic, group).Inc()
+	}
+}
+
+func (m *metrics) decodeError(topic, group string) {
+	if m != nil {
+		m.decodeErrors.WithLabelValues(topic, group).Inc()
+	}
+}
+
+// connectionCollector reports the connection status when scraped.
+type connectionCollector struct {
+	m *metrics
+}
+
+func (c connectionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.m.connectionState
+	ch <- c.m.reconnects
+}
+
+func (c connectionCollector) Collect(ch chan<- prometheus.Metric) {
+	status := c.m.status()
+	for _, state := range []ConnectionState{StateConnected, StateReconnecting, StateClosed} {
+		var value float64
+		if status.State == state {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.m.connectionState, prometheus.GaugeValue, value, string(state))
+	}
+	ch <- prometheus.MustNewConstMetric(c.m.reconnects, prometheus.CounterValue, float64(status.Reconnects))
+}
@@ -0,0 +1,66 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	srv := runServer(t)
+	registry := prometheus.NewRegistry()
+	bus := newTestBus(t, srv, JetStreamConfig{Enabled: true, MaxDeliver: 2}, WithMetrics(registry))
+
+	_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		if event.ID() == "bad" {
+			return errors.New("boom")
+		}
+		return nil
+	}, WithConsumerGroup("workers"))
+	require.NoError(t, err)
+
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("bad")))
+	require.NoError(t, bus.nc.Publish("orders", []byte("not an event")))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(bus.metrics.published.WithLabelValues("orders")))
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(bus.metrics.failed.WithLabelValues("orders", "workers")) == 2 &&
+			testutil.ToFloat64(bus.metrics.decodeErrors.WithLabelValues("orders", "workers")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 3.0, testutil.ToFloat64(bus.metrics.delivered.WithLabelValues("orders", "workers")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(bus.metrics.acked.WithLabelValues("orders", "workers")))
+	assert.Equal(t, 1, testutil.CollectAndCount(bus.metrics.handlerDuration))
+
+	expected := `
+# HELP ebrick_nats_connection_state Connection state, 1 for the current state.
+# TYPE ebrick_nats_connection_state gauge
+ebrick_nats_connection_state{state="closed"} 0
+ebrick_nats_connection_state{state="connected"} 1
+ebrick_nats_connection_state{state="reconnecting"} 0
+# HELP ebrick_nats_reconnects_total Successful reconnects.
+# TYPE ebrick_nats_reconnects_total counter
+ebrick_nats_reconnects_total 0
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"ebrick_nats_connection_state", "ebrick_nats_reconnects_total"))
+}
+
+func TestMetricsRegistration(t *testing.T) {
+	srv := runServer(t)
+	registry := prometheus.NewRegistry()
+	newTestBus(t, srv, JetStreamConfig{}, WithMetrics(prometheus.WrapRegistererWith(prometheus.Labels{"bus": "first"}, registry)))
+
+	_, err := NewEventBus(&NatsConfig{URL: srv.ClientURL()}, WithMetrics(prometheus.WrapRegistererWith(prometheus.Labels{"bus": "first"}, registry)))
+	assert.Error(t, err)
+
+	newTestBus(t, srv, JetStreamConfig{}, WithMetrics(prometheus.WrapRegistererWith(prometheus.Labels{"bus": "second"}, registry)))
+}
@@ -2,7 +2,6 @@ package nats
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -13,8 +12,12 @@ import (
 	"github.com/ebrickdev/ebrick/config"
 	"github.com/ebrickdev/ebrick/messaging"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
+// defaultShutdownTimeout is how long Close waits for in-flight handlers.
+const defaultShutdownTimeout = 10 * time.Second
+
 func Init() messaging.EventBus {
 	// Get the database configuration from the config package
 	var cfg Config
@@ -23,8 +26,9 @@ func Init() messaging.EventBus {
 		log.Fatalf("Nats: error loading config %v", err)
 	}
 	// Initialize NATS connection
-	log.Printf("Nats: Connecting to nats on %s \n", cfg.Messaging.Nats.URL)
-	eventBus, err := NewEventBus(cfg.Messaging.Nats.URL, cfg.Messaging.Nats.Username, cfg.Messaging.Nats.Password)
+	servers, _ := serverURLs(&cfg.Messaging.Nats)
+	log.Printf("Nats: Connecting to nats on %s \n", servers)
+	eventBus, err := NewEventBus(&cfg.Messaging.Nats)
 	if err != nil {
 		log.Fatalf("Nats: error initializing event bus. %v", err)
 	}
@@ -34,28 +38,128 @@ func Init() messaging.EventBus {
 
 type NatsEventBus struct {
 	nc     *nats.Conn
-	mu     sync.RWMutex // Protects the closed flag
+	js     jetstream.JetStream // Set only when JetStream mode is enabled
+	cfg    NatsConfig
+	mu     sync.RWMutex // Protects the closed flag and subs
 	closed bool
+	subs   map[*Subscription]struct{}
+
+	streamsMu sync.Mutex
+	streams   map[string]string // Stream capturing each topic, once provisioned
+
+	publish      PublishFunc          // Publishing wrapped in the publish interceptors
+	publishChain []PublishInterceptor // Wrapped around every Publish and Request
+	interceptors []ConsumeInterceptor // Wrapped around every handler invocation
+	conn         *connection          // Connection status and lifecycle events
+	sched        *scheduler           // Set only when the scheduler is enabled
+	metrics      *metrics             // Set only when metrics are enabled
 }
 
-// NewNatsEventBus creates a new NatsEventBus with automatic reconnection.
-func NewEventBus(natsURL, username, password string) (*NatsEventBus, error) {
-	nc, err := nats.Connect(natsURL,
-		nats.UserInfo(username, password),
-		nats.MaxReconnects(10),
-		nats.ReconnectWait(2*time.Second),
-		nats.ErrorHandler(func(nc *nats.Conn, sub *nats.Subscription, err error) {
-			fmt.Printf("Error in subscription: %v\n", err)
-		}),
-	)
+// NewEventBus creates a new NatsEventBus with automatic reconnection.
+// The authentication, TLS and reconnect settings are validated before connecting.
+// Connection events are logged and reported to the ConnectionListener; after the
+// reconnect attempts run out the connection is closed and Status reports StateClosed.
+// If cfg.JetStream.Enabled is set, events are persisted in JetStream streams.
+// If cfg.Scheduler.Enabled is set, PublishAt and PublishAfter delay events.
+// Options add publish and consume interceptors and configure tracing and metrics.
+// Producer and consumer spans are always emitted, around all interceptors.
+func NewEventBus(cfg *NatsConfig, options ...Option) (*NatsEventBus, error) {
+	if err := validateContentMode(cfg.ContentMode); err != nil {
+		return nil, err
+	}
+	if cfg.JetStream.Enabled {
+		if err := validateJetStreamConfig(cfg.JetStream); err != nil {
+			return nil, err
+		}
+	}
+
+	servers, err := serverURLs(cfg)
+	if err != nil {
+		return nil, err
+	}
+	connectOptions, err := securityOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	reconnect, err := reconnectOptions(cfg.Reconnect)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts Options
+	for _, o := range options {
+		o(&opts)
+	}
+
+	bus := &NatsEventBus{
+		cfg:     *cfg,
+		subs:    make(map[*Subscription]struct{}),
+		streams: make(map[string]string),
+	}
+	bus.conn = newConnection(opts, bus.isClosed)
+	connectOptions = append(connectOptions, reconnect...)
+	connectOptions = append(connectOptions, bus.conn.options()...)
+
+	nc, err := nats.Connect(servers, connectOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to NATS server. %v", err)
 	}
+	bus.nc = nc
+	bus.conn.connected(nc)
+
+	tracing := newTracing(opts)
+	publishInterceptors := []PublishInterceptor{tracing.publish}
+	consumeInterceptors := []ConsumeInterceptor{tracing.consume}
+	if opts.Registerer != nil {
+		bus.metrics = newMetrics(bus.Status)
+		publishInterceptors = append(publishInterceptors, bus.metrics.publish)
+		consumeInterceptors = append(consumeInterceptors, bus.metrics.consume)
+	}
+	bus.interceptors = append(consumeInterceptors, opts.ConsumeInterceptors...)
+	bus.publishChain = append(publishInterceptors, opts.PublishInterceptors...)
+	bus.publish = chainPublish(bus.publishChain, bus.send)
+	if cfg.JetStream.Enabled {
+		bus.js, err = jetstream.New(nc)
+		if err != nil {
+			bus.closed = true
+			nc.Close()
+			return nil, fmt.Errorf("failed to initialize JetStream. %v", err)
+		}
+	}
+	if cfg.Scheduler.Enabled {
+		bus.sched, err = newScheduler(nc, cfg.Scheduler, bus.send)
+		if err != nil {
+			bus.closed = true
+			nc.Close()
+			return nil, err
+		}
+	}
+	if bus.metrics != nil {
+		if err := bus.metrics.register(opts.Registerer); err != nil {
+			bus.closed = true
+			if bus.sched != nil {
+				bus.sched.close()
+			}
+			nc.Close()
+			return nil, fmt.Errorf("failed to register metrics: %w", err)
+		}
+	}
+
+	return bus, nil
+}
 
-	return &NatsEventBus{nc: nc}, nil
+// NewEventBusFromURL creates a NatsEventBus from the arguments NewEventBus took before
+// it accepted a NatsConfig.
+//
+// Deprecated: Use NewEventBus with a NatsConfig holding URL, Username and Password.
+func NewEventBusFromURL(natsURL, username, password string) (*NatsEventBus, error) {
+	return NewEventBus(&NatsConfig{URL: natsURL, Username: username, Password: password})
 }
 
 // Publish sends an event to all subscribers of the specified event type.
+// The publish interceptors run around the encoding and sending of the event.
+// While reconnecting, events are buffered or rejected with ErrDisconnected
+// according to the reconnect publish policy.
 func (b *NatsEventBus) Publish(ctx context.Context, topic string, event cloudevents.Event) error {
 	if b.isClosed() {
 		return errors.New("eventbus is closed")
@@ -66,17 +170,34 @@ func (b *NatsEventBus) Publish(ctx context.Context, topic string, event cloudeve
 		return errors.New("topic must not be empty")
 	}
 
+	return b.publish(ctx, topic, event)
+}
+
+// send encodes an event and publishes it on the connection.
+func (b *NatsEventBus) send(ctx context.Context, topic string, event cloudevents.Event) error {
+	if b.cfg.Reconnect.PublishPolicy == PublishPolicyFail && !b.nc.IsConnected() {
+		return ErrDisconnected
+	}
+
 	// Validate the event before publishing
 	if event.Type() == "" || event.ID() == "" {
 		return errors.New("event must have a valid ID and Type")
 	}
 
-	data, err := encodeEvent(event)
+	if at, ok := ctx.Value(deliverAtKey{}).(time.Time); ok {
+		return b.sched.schedule(ctx, topic, event, at)
+	}
+
+	msg, err := encodeMessage(topic, event, b.cfg.ContentMode)
 	if err != nil {
 		return fmt.Errorf("failed to encode event: %w", err)
 	}
 
-	err = b.nc.Publish(topic, data)
+	if b.js != nil {
+		return b.publishJetStream(ctx, msg)
+	}
+
+	err = b.nc.PublishMsg(msg)
 	if err != nil {
 		return fmt.Errorf("failed to publish event: %w", err)
 	}
@@ -84,19 +205,64 @@ func (b *NatsEventBus) Publish(ctx context.Context, topic string, event cloudeve
 	return nil
 }
 
-// Subscribe registers a handler for the specified event type and returns an unsubscribe function.
+// Subscribe registers a handler for the specified event type.
+// Subscriptions created here are stopped by Close or Shutdown; use SubscribeHandler
+// to obtain a Subscription handle or to report handler failures.
 func (b *NatsEventBus) Subscribe(topic string, handler func(ctx context.Context, event cloudevents.Event), options ...messaging.SubscriptionOption) error {
+	_, err := b.SubscribeHandler(topic, func(ctx context.Context, event cloudevents.Event) error {
+		handler(ctx, event)
+		return nil
+	}, WithMessagingOptions(options...))
+	return err
+}
+
+// SubscribeHandler registers a handler that reports failures and returns a handle
+// to unsubscribe or drain the subscription.
+func (b *NatsEventBus) SubscribeHandler(topic string, handler Handler, options ...SubscriptionOption) (*Subscription, error) {
+	sub, opts, err := b.newSubscription(topic, options, b.js != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b.js != nil:
+		// In JetStream mode the group (or name) selects a durable consumer instead of a queue group.
+		sub.consumer, err = b.subscribeJetStream(sub, handler, opts)
+	case opts.Group != "":
+		// If a consumer group is specified, use QueueSubscribe to load balance the messages.
+		log.Printf("Nats: Joining consumer group '%s' on topic '%s'", opts.Group, topic)
+		sub.sub, err = b.nc.QueueSubscribe(topic, opts.Group, b.coreMsgHandler(sub, handler))
+	default:
+		// Otherwise, use normal Subscribe.
+		sub.sub, err = b.nc.Subscribe(topic, b.coreMsgHandler(sub, handler))
+	}
+	if err != nil {
+		sub.cancel()
+		return nil, fmt.Errorf("failed to subscribe to event: %w", err)
+	}
+
+	if err := b.addSubscription(sub); err != nil {
+		_ = sub.Unsubscribe()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// newSubscription validates a subscription request and prepares its handle.
+// JetStream subscriptions handle messages serially unless a limit is configured, so a
+// consumer never pulls more messages than it can acknowledge in time.
+func (b *NatsEventBus) newSubscription(topic string, options []SubscriptionOption, jetStream bool) (*Subscription, SubscriptionOptions, error) {
 	if b.isClosed() {
-		return errors.New("eventbus is closed")
+		return nil, SubscriptionOptions{}, errors.New("eventbus is closed")
 	}
 
 	// Validate topic before subscribing
 	if topic == "" {
-		return errors.New("topic must not be empty")
+		return nil, SubscriptionOptions{}, errors.New("topic must not be empty")
 	}
 
 	// Process subscription options for consumer group and name.
-	opts := messaging.SubscriptionOptions{}
+	opts := SubscriptionOptions{}
 	for _, o := range options {
 		o(&opts)
 	}
@@ -106,65 +272,129 @@ func (b *NatsEventBus) Subscribe(topic string, handler func(ctx context.Context,
 		log.Printf("Nats: Subscriber '%s'", opts.Name)
 	}
 
-	// If a consumer group is specified, use QueueSubscribe to load balance the messages.
-	if opts.Group != "" {
-		log.Printf("Nats: Joining consumer group '%s' on topic '%s'", opts.Group, topic)
-		_, err := b.nc.QueueSubscribe(topic, opts.Group, func(msg *nats.Msg) {
-			event, err := decodeEvent(msg.Data)
-			if err != nil {
-				log.Printf("failed to decode event: %v", err)
-				return
-			}
-			go handler(context.Background(), event)
-		})
-		if err != nil {
-			return fmt.Errorf("failed to subscribe to event: %w", err)
-		}
-		return nil
+	// JetStream handles messages serially unless a limit is configured, as it did
+	// before MaxInFlight existed.
+	limit := opts.MaxInFlight
+	if jetStream && limit <= 0 {
+		limit = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &Subscription{
+		topic:  topic,
+		group:  opts.Group,
+		name:   opts.Name,
+		bus:    b,
+		pool:   newDispatcher(ctx, limit, opts.OrderingKey),
+		ctx:    ctx,
+		cancel: cancel,
 	}
+	return sub, opts, nil
+}
 
-	// Otherwise, use normal Subscribe.
-	_, err := b.nc.Subscribe(topic, func(msg *nats.Msg) {
-		event, err := decodeEvent(msg.Data)
+// coreMsgHandler decodes core NATS messages and hands them to the subscription's
+// dispatcher. While the dispatcher is saturated the callback blocks, so further messages
+// queue up in the client's pending buffer (and are dropped once its limits are exceeded).
+func (b *NatsEventBus) coreMsgHandler(sub *Subscription, handler Handler) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		event, err := decodeMessage(msg.Header, msg.Data)
 		if err != nil {
 			log.Printf("failed to decode event: %v", err)
+			b.metrics.decodeError(msg.Subject, sub.group)
 			return
 		}
-		go handler(context.Background(), event)
-	})
-	if err != nil {
-		return fmt.Errorf("failed to subscribe to event: %w", err)
+		info := ConsumeInfo{Topic: msg.Subject, Group: sub.group, Name: sub.name, Attempt: 1}
+		sub.dispatch(event, func() {
+			if err := invokeHandler(context.Background(), b.intercept(handler, info), event); err != nil {
+				log.Printf("Nats: handler failed on topic '%s': %v", sub.topic, err)
+			}
+		})
 	}
+}
 
-	return nil
+// intercept wraps a handler in the consume interceptors for one delivery.
+func (b *NatsEventBus) intercept(handler Handler, info ConsumeInfo) Handler {
+	return chainConsume(b.interceptors, info, handler)
+}
+
+// invokeHandler calls the handler, converting a panic into an error.
+func invokeHandler(ctx context.Context, handler Handler, event cloudevents.Event) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("handler panicked: %v", rec)
+		}
+	}()
+	return handler(ctx, event)
 }
 
 // Close shuts down the event bus and ensures no new events are processed.
+// It waits up to 10 seconds for in-flight handlers; use Shutdown to control the deadline.
 func (b *NatsEventBus) Close() error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	return b.Shutdown(ctx)
+}
 
+// Shutdown drains all subscriptions, waits for in-flight handlers until ctx expires
+// and closes the connection. Handlers that did not finish are reported in a *DrainError.
+func (b *NatsEventBus) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
 	if b.closed {
+		b.mu.Unlock()
 		return errors.New("eventbus is already closed")
 	}
+	b.closed = true
+	subs := make([]*Subscription, 0, len(b.subs))
+	for sub := range b.subs {
+		subs = append(subs, sub)
+	}
+	b.mu.Unlock()
 
+	var drainErr DrainError
+	for _, sub := range subs {
+		err := sub.Drain(ctx)
+		var de *DrainError
+		if errors.As(err, &de) {
+			drainErr.Unfinished = append(drainErr.Unfinished, de.Unfinished...)
+		} else if err != nil {
+			log.Printf("Nats: failed to drain subscription on topic '%s': %v", sub.topic, err)
+		}
+	}
+
+	if b.sched != nil {
+		b.sched.close()
+	}
 	b.nc.Close()
-	b.closed = true
+	if len(drainErr.Unfinished) > 0 {
+		return &drainErr
+	}
 	return nil
 }
 
-func (b *NatsEventBus) isClosed() bool {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-	return b.closed
+// addSubscription registers a subscription unless the bus is closed.
+func (b *NatsEventBus) addSubscription(sub *Subscription) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errors.New("eventbus is closed")
+	}
+	b.subs[sub] = struct{}{}
+	return nil
 }
 
-func encodeEvent(event cloudevents.Event) ([]byte, error) {
-	return json.Marshal(event)
+func (b *NatsEventBus) removeSubscription(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, sub)
 }
 
-func decodeEvent(data []byte) (cloudevents.Event, error) {
-	var evt cloudevents.Event
-	err := json.Unmarshal(data, &evt)
-	return evt, err
+// Status returns the status of the connection to the NATS servers.
+func (b *NatsEventBus) Status() ConnectionStatus {
+	return b.conn.Status()
+}
+
+func (b *NatsEventBus) isClosed() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.closed
 }
@@ -0,0 +1,420 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runServer starts an embedded NATS server with JetStream enabled.
+func runServer(t *testing.T) *server.Server {
+	t.Helper()
+
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+
+	go srv.Start()
+	require.True(t, srv.ReadyForConnections(5*time.Second), "nats server not ready")
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func newTestBus(t *testing.T, srv *server.Server, js JetStreamConfig, options ...Option) *NatsEventBus {
+	t.Helper()
+
+	bus, err := NewEventBus(&NatsConfig{URL: srv.ClientURL(), JetStream: js}, options...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bus.Close() })
+	return bus
+}
+
+func newTestEvent(id string) cloudevents.Event {
+	event := cloudevents.NewEvent()
+	event.SetID(id)
+	event.SetType("test.event")
+	event.SetSource("nats_test")
+	_ = event.SetData(cloudevents.ApplicationJSON, map[string]string{"id": id})
+	return event
+}
+
+// collect returns a handler that forwards event IDs to the returned channel.
+func collect() (func(ctx context.Context, event cloudevents.Event), chan string) {
+	ch := make(chan string, 100)
+	return func(ctx context.Context, event cloudevents.Event) {
+		ch <- event.ID()
+	}, ch
+}
+
+func receive(t *testing.T, ch chan string, n int) []string {
+	t.Helper()
+
+	var ids []string
+	for len(ids) < n {
+		select {
+		case id := <-ch:
+			ids = append(ids, id)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after receiving %d of %d events", len(ids), n)
+		}
+	}
+	return ids
+}
+
+func assertNoMore(t *testing.T, ch chan string) {
+	t.Helper()
+
+	select {
+	case id := <-ch:
+		t.Fatalf("unexpected event %s", id)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{})
+
+	handler, ch := collect()
+	require.NoError(t, bus.Subscribe("orders.created", handler))
+	require.NoError(t, bus.nc.Flush())
+
+	require.NoError(t, bus.Publish(context.Background(), "orders.created", newTestEvent("1")))
+	assert.Equal(t, []string{"1"}, receive(t, ch, 1))
+}
+
+func TestPublishValidation(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{})
+
+	assert.Error(t, bus.Publish(context.Background(), "", newTestEvent("1")))
+	assert.Error(t, bus.Publish(context.Background(), "orders", cloudevents.NewEvent()))
+
+	require.NoError(t, bus.Close())
+	assert.Error(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+}
+
+func TestNewEventBusFromURL(t *testing.T) {
+	srv := runServer(t)
+	bus, err := NewEventBusFromURL(srv.ClientURL(), "", "")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bus.Close() })
+
+	handler, ch := collect()
+	require.NoError(t, bus.Subscribe("orders", handler))
+	require.NoError(t, bus.nc.Flush())
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	assert.Equal(t, []string{"1"}, receive(t, ch, 1))
+}
+
+func TestNewEventBusRejectsInvalidJetStreamConfig(t *testing.T) {
+	tests := []JetStreamConfig{
+		{Enabled: true, DeliverPolicy: "sometimes"},
+		{Enabled: true, DeliverPolicy: DeliverByStartSequence},
+		{Enabled: true, DeliverPolicy: DeliverByStartTime, StartTime: "yesterday"},
+		{Enabled: true, Storage: "tape"},
+	}
+	for _, js := range tests {
+		_, err := NewEventBus(&NatsConfig{URL: "nats://127.0.0.1:1", JetStream: js})
+		assert.Error(t, err, "%+v", js)
+	}
+}
+
+func TestJetStreamDurableConsumerResumes(t *testing.T) {
+	srv := runServer(t)
+	js := JetStreamConfig{Enabled: true, Storage: "memory"}
+	ctx := context.Background()
+
+	bus := newTestBus(t, srv, js)
+	handler, ch := collect()
+	require.NoError(t, bus.Subscribe("orders.created", handler, messaging.WithConsumerGroup("billing")))
+
+	require.NoError(t, bus.Publish(ctx, "orders.created", newTestEvent("1")))
+	assert.Equal(t, []string{"1"}, receive(t, ch, 1))
+	// Give the ack time to reach the server before the consumer goes away.
+	require.NoError(t, bus.nc.Flush())
+	require.NoError(t, bus.Close())
+
+	// Events published while the consumer is down must not be lost.
+	publisher := newTestBus(t, srv, js)
+	require.NoError(t, publisher.Publish(ctx, "orders.created", newTestEvent("2")))
+	require.NoError(t, publisher.Publish(ctx, "orders.created", newTestEvent("3")))
+
+	restarted := newTestBus(t, srv, js)
+	handler, ch = collect()
+	require.NoError(t, restarted.Subscribe("orders.created", handler, messaging.WithConsumerGroup("billing")))
+	assert.Equal(t, []string{"2", "3"}, receive(t, ch, 2))
+	assertNoMore(t, ch)
+}
+
+func TestJetStreamWildcardStreamIsShared(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{Enabled: true, Storage: "memory"})
+	ctx := context.Background()
+
+	all, allCh := collect()
+	require.NoError(t, bus.Subscribe("orders.*", all, messaging.WithConsumerGroup("audit")))
+	created, createdCh := collect()
+	require.NoError(t, bus.Subscribe("orders.created", created, messaging.WithConsumerGroup("billing")))
+
+	// Both topics are captured by the stream provisioned for the wildcard.
+	require.NoError(t, bus.Publish(ctx, "orders.created", newTestEvent("1")))
+	require.NoError(t, bus.Publish(ctx, "orders.paid", newTestEvent("2")))
+	assert.Equal(t, []string{"1", "2"}, receive(t, allCh, 2))
+	assert.Equal(t, []string{"1"}, receive(t, createdCh, 1))
+	assertNoMore(t, createdCh)
+}
+
+func TestJetStreamGroupOnTopicsOfOneStream(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{Enabled: true, Storage: "memory"})
+	ctx := context.Background()
+
+	all, _ := collect()
+	require.NoError(t, bus.Subscribe("orders.>", all, messaging.WithConsumerGroup("audit")))
+	created, createdCh := collect()
+	require.NoError(t, bus.Subscribe("orders.created", created, messaging.WithConsumerGroup("billing")))
+	shipped, shippedCh := collect()
+	require.NoError(t, bus.Subscribe("orders.shipped", shipped, messaging.WithConsumerGroup("billing")))
+
+	require.NoError(t, bus.Publish(ctx, "orders.created", newTestEvent("1")))
+	require.NoError(t, bus.Publish(ctx, "orders.shipped", newTestEvent("2")))
+	assert.Equal(t, []string{"1"}, receive(t, createdCh, 1))
+	assert.Equal(t, []string{"2"}, receive(t, shippedCh, 1))
+	assertNoMore(t, createdCh)
+	assertNoMore(t, shippedCh)
+
+	// Both topics map to the durable name billing_orders_eu__.
+	require.NoError(t, bus.Subscribe("orders.eu.*", created, messaging.WithConsumerGroup("billing")))
+	err := bus.Subscribe("orders.eu.>", shipped, messaging.WithConsumerGroup("billing"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already consumes orders.eu.*")
+}
+
+func TestJetStreamRejectsConflictingStreams(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{Enabled: true, Storage: "memory"})
+	ctx := context.Background()
+	require.NoError(t, bus.Publish(ctx, "orders.created", newTestEvent("1")))
+
+	// The stream of orders.created would overlap a stream for orders.*.
+	handler, _ := collect()
+	err := bus.Subscribe("orders.*", handler)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "overlaps")
+
+	// orders_created maps to the stream name of orders.created.
+	err = bus.Publish(ctx, "orders_created", newTestEvent("2"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already captures orders.created")
+
+	// A new bus does not rely on the streams it provisioned itself.
+	other := newTestBus(t, srv, JetStreamConfig{Enabled: true, Storage: "memory"})
+	assert.Error(t, other.Publish(ctx, "orders_created", newTestEvent("3")))
+	assert.NoError(t, other.Publish(ctx, "orders.created", newTestEvent("4")))
+}
+
+func TestSubjectCovers(t *testing.T) {
+	cases := []struct {
+		subject, topic string
+		covers         bool
+	}{
+		{"orders.created", "orders.created", true},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders.*", true},
+		{"orders.>", "orders.created.eu", true},
+		{"orders.>", "orders.*", true},
+		{"orders.*", "orders.>", false},
+		{"orders.created", "orders.*", false},
+		{"orders.*", "orders", false},
+		{"orders.>", "orders", false},
+		{"orders.*", "orders.created.eu", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.covers, subjectCovers(c.subject, c.topic), "%s covers %s", c.subject, c.topic)
+	}
+}
+
+func TestJetStreamDeliverPolicies(t *testing.T) {
+	srv := runServer(t)
+	ctx := context.Background()
+
+	publisher := newTestBus(t, srv, JetStreamConfig{Enabled: true, Storage: "memory"})
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, publisher.Publish(ctx, "payments", newTestEvent(fmt.Sprint(i))))
+	}
+
+	tests := []struct {
+		name     string
+		js       JetStreamConfig
+		expected []string
+	}{
+		{"all", JetStreamConfig{DeliverPolicy: DeliverAll}, []string{"1", "2", "3"}},
+		{"by sequence", JetStreamConfig{DeliverPolicy: DeliverByStartSequence, StartSequence: 2}, []string{"2", "3"}},
+		{"by time", JetStreamConfig{DeliverPolicy: DeliverByStartTime, StartTime: time.Now().Add(-time.Hour).Format(time.RFC3339)}, []string{"1", "2", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.js.Enabled = true
+			tt.js.Storage = "memory"
+			bus := newTestBus(t, srv, tt.js)
+
+			handler, ch := collect()
+			require.NoError(t, bus.Subscribe("payments", handler))
+			assert.Equal(t, tt.expected, receive(t, ch, len(tt.expected)))
+			assertNoMore(t, ch)
+		})
+	}
+
+	t.Run("new", func(t *testing.T) {
+		bus := newTestBus(t, srv, JetStreamConfig{Enabled: true, Storage: "memory"})
+
+		handler, ch := collect()
+		require.NoError(t, bus.Subscribe("payments", handler))
+		assertNoMore(t, ch)
+
+		require.NoError(t, bus.Publish(ctx, "payments", newTestEvent("4")))
+		assert.Equal(t, []string{"4"}, receive(t, ch, 1))
+	})
+}
+
+func TestUnsubscribe(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{})
+
+	handler, ch := collect()
+	sub, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		handler(ctx, event)
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	assert.Equal(t, "orders", sub.Topic())
+	assert.Equal(t, "billing", sub.Group())
+
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	assert.Equal(t, []string{"1"}, receive(t, ch, 1))
+
+	require.NoError(t, sub.Unsubscribe())
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("2")))
+	assertNoMore(t, ch)
+}
+
+func TestShutdownWaitsForHandlers(t *testing.T) {
+	for _, js := range []JetStreamConfig{{}, {Enabled: true, Storage: "memory"}} {
+		t.Run(fmt.Sprintf("jetstream=%v", js.Enabled), func(t *testing.T) {
+			srv := runServer(t)
+			bus := newTestBus(t, srv, js)
+
+			started := make(chan struct{})
+			finished := make(chan struct{})
+			require.NoError(t, bus.Subscribe("orders", func(ctx context.Context, event cloudevents.Event) {
+				close(started)
+				time.Sleep(100 * time.Millisecond)
+				close(finished)
+			}))
+			require.NoError(t, bus.nc.Flush())
+			require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+			<-started
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			require.NoError(t, bus.Shutdown(ctx))
+			select {
+			case <-finished:
+			default:
+				t.Fatal("shutdown returned before the handler finished")
+			}
+			assert.Error(t, bus.Close())
+		})
+	}
+}
+
+func TestShutdownReportsUnfinishedHandlers(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{})
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	require.NoError(t, bus.Subscribe("orders", func(ctx context.Context, event cloudevents.Event) {
+		close(started)
+		<-release
+	}, messaging.WithConsumerGroup("billing")))
+	require.NoError(t, bus.nc.Flush())
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := bus.Shutdown(ctx)
+
+	var drainErr *DrainError
+	require.ErrorAs(t, err, &drainErr)
+	assert.Equal(t, []UnfinishedHandlers{{Topic: "orders", Group: "billing", InFlight: 1}}, drainErr.Unfinished)
+}
+
+func TestHandlerPanicIsRecovered(t *testing.T) {
+	for name, js := range map[string]JetStreamConfig{
+		"core":      {},
+		"jetstream": {Enabled: true, Storage: "memory"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := runServer(t)
+			bus := newTestBus(t, srv, js)
+
+			handled := make(chan string, 10)
+			_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+				if event.ID() == "1" {
+					panic("boom")
+				}
+				handled <- event.ID()
+				return nil
+			}, WithConsumerGroup("billing"))
+			require.NoError(t, err)
+
+			require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+			require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("2")))
+			assert.Equal(t, []string{"2"}, receive(t, handled, 1))
+		})
+	}
+}
+
+func TestJetStreamFailedHandlerIsRedelivered(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{Enabled: true, Storage: "memory"})
+
+	attempts := make(chan int, 10)
+	count := 0
+	_, err := bus.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		count++
+		attempts <- count
+		if count == 1 {
+			return fmt.Errorf("temporary failure")
+		}
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	require.NoError(t, bus.Publish(context.Background(), "orders", newTestEvent("1")))
+
+	for want := 1; want <= 2; want++ {
+		select {
+		case got := <-attempts:
+			assert.Equal(t, want, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("attempt %d not delivered", want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package nats
+
+import (
+	"context"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+)
+
+// Handler processes an event and reports whether it succeeded.
+// In JetStream mode a message is acknowledged only when the handler returns nil;
+// otherwise it is negatively acknowledged and redelivered.
+type Handler func(ctx context.Context, event cloudevents.Event) error
+
+// SubscriptionOptions extends messaging.SubscriptionOptions with NATS specific settings.
+type SubscriptionOptions struct {
+	messaging.SubscriptionOptions
+	MaxInFlight int     // Maximum concurrent handler invocations, 0 means unlimited (1 in JetStream mode)
+	OrderingKey KeyFunc // Handle events with the same key serially, in delivery order
+}
+
+// SubscriptionOption defines a function to set NATS subscription options.
+type SubscriptionOption func(opts *SubscriptionOptions)
+
+// WithConsumerGroup specifies the consumer group.
+func WithConsumerGroup(group string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.Group = group
+	}
+}
+
+// WithConsumerName specifies the consumer name.
+func WithConsumerName(name string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.Name = name
+	}
+}
+
+// WithMaxInFlight bounds the number of concurrent handler invocations. When all
+// handlers are busy the subscription stops taking messages until one finishes; in
+// JetStream mode it also limits how many messages are pulled ahead. Without it,
+// JetStream subscriptions handle messages serially and pull the client's default
+// batch ahead.
+func WithMaxInFlight(n int) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.MaxInFlight = n
+	}
+}
+
+// WithOrderingKey preserves the order of events sharing a key, e.g. SubjectKey or
+// ExtensionKey("partitionkey"). Events with different keys are handled in parallel by
+// up to MaxInFlight workers; without MaxInFlight all events are handled serially.
+func WithOrderingKey(key KeyFunc) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.OrderingKey = key
+	}
+}
+
+// WithMessagingOptions applies the generic messaging subscription options.
+func WithMessagingOptions(options ...messaging.SubscriptionOption) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		for _, o := range options {
+			o(&opts.SubscriptionOptions)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+)
+
+// ErrorEventType is the type of the event sent back when a responder fails.
+// Its data is a JSON object with a "message" field.
+const ErrorEventType = "dev.ebrick.messaging.error"
+
+// ResponderHandler handles a request event and returns the reply event.
+// A returned error is sent back to the requester as an ErrorEventType event.
+type ResponderHandler func(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, error)
+
+// ReplyError is returned by Request when the responder replied with an error event.
+type ReplyError struct {
+	Message string
+	Event   cloudevents.Event // The error event sent by the responder
+}
+
+func (e *ReplyError) Error() string {
+	return "responder failed: " + e.Message
+}
+
+// errorEventData is the payload of ErrorEventType events.
+type errorEventData struct {
+	Message string `json:"message"`
+}
+
+// Request publishes an event on topic and waits for a single reply on a NATS inbox.
+// The ctx deadline bounds the wait. Like Publish, the request runs through the publish
+// interceptors, so it carries the trace context to the responder. Requests always use
+// core NATS, so topic should not be captured by a JetStream stream. If no responder is
+// subscribed the returned error wraps nats.ErrNoResponders; an error reply is returned
+// as a *ReplyError.
+func (b *NatsEventBus) Request(ctx context.Context, topic string, event cloudevents.Event) (cloudevents.Event, error) {
+	if b.isClosed() {
+		return cloudevents.Event{}, errors.New("eventbus is closed")
+	}
+	if topic == "" {
+		return cloudevents.Event{}, errors.New("topic must not be empty")
+	}
+	if event.Type() == "" || event.ID() == "" {
+		return cloudevents.Event{}, errors.New("event must have a valid ID and Type")
+	}
+
+	var reply cloudevents.Event
+	request := func(ctx context.Context, topic string, event cloudevents.Event) error {
+		msg, err := encodeMessage(topic, event, b.cfg.ContentMode)
+		if err != nil {
+			return fmt.Errorf("failed to encode event: %w", err)
+		}
+		resp, err := b.nc.RequestMsgWithContext(ctx, msg)
+		if err != nil {
+			return fmt.Errorf("request on topic %s failed: %w", topic, err)
+		}
+		reply, err = decodeMessage(resp.Header, resp.Data)
+		if err != nil {
+			return fmt.Errorf("failed to decode reply: %w", err)
+		}
+		return nil
+	}
+	if err := chainPublish(b.publishChain, request)(ctx, topic, event); err != nil {
+		return cloudevents.Event{}, err
+	}
+
+	if reply.Type() == ErrorEventType {
+		var data errorEventData
+		if err := reply.DataAs(&data); err != nil {
+			data.Message = err.Error()
+		}
+		return cloudevents.Event{}, &ReplyError{Message: data.Message, Event: reply}
+	}
+	return reply, nil
+}
+
+// Respond registers a handler that answers requests sent with Request. Like core
+// subscriptions, a consumer group load balances the requests between responders, and
+// the handler runs in the consume interceptors. A panicking handler is answered with
+// an error reply.
+// Responders always use core NATS, also when JetStream is enabled.
+func (b *NatsEventBus) Respond(topic string, handler ResponderHandler, options ...SubscriptionOption) (*Subscription, error) {
+	sub, opts, err := b.newSubscription(topic, options, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Group != "" {
+		log.Printf("Nats: Joining responder group '%s' on topic '%s'", opts.Group, topic)
+		sub.sub, err = b.nc.QueueSubscribe(topic, opts.Group, b.respondMsgHandler(sub, handler))
+	} else {
+		sub.sub, err = b.nc.Subscribe(topic, b.respondMsgHandler(sub, handler))
+	}
+	if err != nil {
+		sub.cancel()
+		return nil, fmt.Errorf("failed to subscribe to requests: %w", err)
+	}
+
+	if err := b.addSubscription(sub); err != nil {
+		_ = sub.Unsubscribe()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// respondMsgHandler decodes requests, runs the handler on the subscription's dispatcher
+// and publishes the reply to the request's inbox.
+func (b *NatsEventBus) respondMsgHandler(sub *Subscription, handler ResponderHandler) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		if msg.Reply == "" {
+			log.Printf("Nats: ignoring message without reply subject on topic '%s'", sub.topic)
+			return
+		}
+
+		request, err := decodeMessage(msg.Header, msg.Data)
+		if err != nil {
+			log.Printf("failed to decode event: %v", err)
+			b.metrics.decodeError(msg.Subject, sub.group)
+			b.reply(msg.Reply, errorEvent(sub.topic, err))
+			return
+		}
+
+		info := ConsumeInfo{Topic: msg.Subject, Group: sub.group, Name: sub.name, Attempt: 1}
+		sub.dispatch(request, func() {
+			var reply *cloudevents.Event
+			respond := func(ctx context.Context, event cloudevents.Event) (err error) {
+				reply, err = handler(ctx, event)
+				if err == nil && reply == nil {
+					err = errors.New("handler returned no reply")
+				}
+				if err == nil {
+					err = reply.Validate()
+				}
+				return err
+			}
+			if err := invokeHandler(context.Background(), b.intercept(respond, info), request); err != nil {
+				log.Printf("Nats: responder failed on topic '%s': %v", sub.topic, err)
+				b.reply(msg.Reply, errorEvent(sub.topic, err))
+				return
+			}
+			b.reply(msg.Reply, *reply)
+		})
+	}
+}
+
+// reply publishes a reply event to an inbox.
+func (b *NatsEventBus) reply(inbox string, event cloudevents.Event) {
+	msg, err := encodeMessage(inbox, event, b.cfg.ContentMode)
+	if err == nil {
+		err = b.nc.PublishMsg(msg)
+	}
+	if err != nil {
+		log.Printf("Nats: failed to send reply: %v", err)
+	}
+}
+
+// errorEvent builds the ErrorEventType event reporting err.
+func errorEvent(topic string, err error) cloudevents.Event {
+	event := cloudevents.NewEvent()
+	event.SetID(uuid.NewString())
+	event.SetType(ErrorEventType)
+	event.SetSource(topic)
+	_ = event.SetData(cloudevents.ApplicationJSON, errorEventData{Message: err.Error()})
+	return event
+}
@@ -0,0 +1,132 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestRespond(t *testing.T) {
+	for _, mode := range []string{ContentModeStructured, ContentModeBinary} {
+		t.Run(mode, func(t *testing.T) {
+			srv := runServer(t)
+			bus, err := NewEventBus(&NatsConfig{URL: srv.ClientURL(), ContentMode: mode})
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = bus.Close() })
+
+			_, err = bus.Respond("orders.get", func(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, error) {
+				if event.ID() == "missing" {
+					return nil, errors.New("order not found")
+				}
+				reply := newTestEvent("reply-" + event.ID())
+				return &reply, nil
+			}, WithConsumerGroup("orders"))
+			require.NoError(t, err)
+			require.NoError(t, bus.nc.Flush())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			reply, err := bus.Request(ctx, "orders.get", newTestEvent("1"))
+			require.NoError(t, err)
+			assert.Equal(t, "reply-1", reply.ID())
+
+			_, err = bus.Request(ctx, "orders.get", newTestEvent("missing"))
+			var replyErr *ReplyError
+			require.ErrorAs(t, err, &replyErr)
+			assert.Equal(t, "order not found", replyErr.Message)
+			assert.Equal(t, ErrorEventType, replyErr.Event.Type())
+		})
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := bus.Request(ctx, "nobody.listens", newTestEvent("1"))
+	assert.ErrorIs(t, err, nats.ErrNoResponders)
+
+	release := make(chan struct{})
+	defer close(release)
+	_, err = bus.Respond("slow", func(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, error) {
+		<-release
+		return &event, nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, bus.nc.Flush())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = bus.Request(ctx, "slow", newTestEvent("1"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = bus.Request(context.Background(), "", newTestEvent("1"))
+	assert.Error(t, err)
+}
+
+func TestRequestRunsInterceptors(t *testing.T) {
+	srv := runServer(t)
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	published := make(chan string, 1)
+	consumed := make(chan ConsumeInfo, 1)
+	bus := newTestBus(t, srv, JetStreamConfig{},
+		WithTracerProvider(provider),
+		WithPublishInterceptors(func(ctx context.Context, topic string, event cloudevents.Event, next PublishFunc) error {
+			published <- topic
+			return next(ctx, topic, event)
+		}),
+		WithConsumeInterceptors(Recover(), func(ctx context.Context, event cloudevents.Event, info ConsumeInfo, next Handler) error {
+			consumed <- info
+			return next(ctx, event)
+		}))
+
+	responded := make(chan trace.SpanContext, 1)
+	_, err := bus.Respond("orders.get", func(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, error) {
+		responded <- trace.SpanContextFromContext(ctx)
+		return &event, nil
+	}, WithConsumerGroup("orders"))
+	require.NoError(t, err)
+	require.NoError(t, bus.nc.Flush())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	ctx, parent := provider.Tracer("test").Start(ctx, "caller")
+	_, err = bus.Request(ctx, "orders.get", newTestEvent("1"))
+	parent.End()
+	require.NoError(t, err)
+
+	assert.Equal(t, "orders.get", <-published)
+	assert.Equal(t, ConsumeInfo{Topic: "orders.get", Group: "orders", Attempt: 1}, <-consumed)
+	assert.Equal(t, parent.SpanContext().TraceID(), (<-responded).TraceID())
+}
+
+func TestResponderPanicIsReported(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{})
+
+	_, err := bus.Respond("orders.get", func(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, error) {
+		panic("boom")
+	})
+	require.NoError(t, err)
+	require.NoError(t, bus.nc.Flush())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = bus.Request(ctx, "orders.get", newTestEvent("1"))
+	var replyErr *ReplyError
+	require.ErrorAs(t, err, &replyErr)
+	assert.Contains(t, replyErr.Message, "handler panicked: boom")
+}
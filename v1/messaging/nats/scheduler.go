@@ -0,0 +1,314 @@
+package nats
+
+import (
+	"container/heap"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	defaultSchedulerBucket = "ebrick_scheduled"
+	// scheduleRetryDelay is how long a scheduled event waits after a failed delivery.
+	scheduleRetryDelay = 5 * time.Second
+	// claimTimeout is how long a claimed event waits before it is delivered again,
+	// in case the bus that claimed it failed before removing it.
+	claimTimeout = 2 * jetStreamTimeout
+)
+
+// ErrNotScheduled is returned by CancelScheduled when no event with the ID is waiting for delivery.
+var ErrNotScheduled = errors.New("event is not scheduled")
+
+var errSchedulerDisabled = errors.New("scheduled delivery requires scheduler.enabled")
+
+// deliverAtKey carries the delivery time of PublishAt through the publish interceptors.
+type deliverAtKey struct{}
+
+// msgIDKey carries the JetStream message ID of a scheduled event to its publish.
+type msgIDKey struct{}
+
+// PublishAt publishes an event to topic at the given time. The event is stored in the
+// scheduler bucket until then and survives restarts of the bus. The publish interceptors
+// run when the event is scheduled, not when it is delivered. Scheduling an event ID that
+// is already scheduled replaces it; times in the past are delivered at once.
+func (b *NatsEventBus) PublishAt(ctx context.Context, topic string, event cloudevents.Event, at time.Time) error {
+	if b.sched == nil {
+		return errSchedulerDisabled
+	}
+	return b.Publish(context.WithValue(ctx, deliverAtKey{}, at), topic, event)
+}
+
+// PublishAfter publishes an event to topic once delay has passed. See PublishAt.
+func (b *NatsEventBus) PublishAfter(ctx context.Context, topic string, event cloudevents.Event, delay time.Duration) error {
+	return b.PublishAt(ctx, topic, event, time.Now().Add(delay))
+}
+
+// CancelScheduled removes a scheduled event before it is delivered. It returns
+// ErrNotScheduled if the event is unknown or was already delivered.
+func (b *NatsEventBus) CancelScheduled(ctx context.Context, id string) error {
+	if b.sched == nil {
+		return errSchedulerDisabled
+	}
+	if b.isClosed() {
+		return errors.New("eventbus is closed")
+	}
+	return b.sched.cancel(ctx, id)
+}
+
+// scheduledEvent is the value stored in the scheduler bucket.
+type scheduledEvent struct {
+	Topic     string            `json:"topic"`
+	DeliverAt time.Time         `json:"deliverAt"`
+	Event     cloudevents.Event `json:"event"`
+	MsgID     string            `json:"msgId,omitempty"` // Set when the event is first claimed
+}
+
+// scheduleKey derives the bucket key of an event ID. Keys are restricted to a few
+// characters, so the ID is base64 encoded.
+func scheduleKey(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+// scheduler keeps scheduled events in a key-value bucket and publishes them when due.
+// Every bus with the scheduler enabled watches the bucket; an event is claimed by
+// updating it at its current revision, so only one of them delivers it. The entry is
+// removed once the event is published, so delivery is at least once; in JetStream mode
+// the stream drops redeliveries within its duplicate window by their message ID.
+type scheduler struct {
+	kv      jetstream.KeyValue
+	deliver PublishFunc
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// newScheduler provisions the bucket and starts watching it.
+func newScheduler(nc *nats.Conn, cfg SchedulerConfig, deliver PublishFunc) (*scheduler, error) {
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize JetStream. %v", err)
+	}
+	bucket := cfg.Bucket
+	if bucket == "" {
+		bucket = defaultSchedulerBucket
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jetStreamTimeout)
+	defer cancel()
+	kv, err := js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{Bucket: bucket, Replicas: cfg.Replicas, History: 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to provision scheduler bucket %s: %w", bucket, err)
+	}
+
+	runCtx, stop := context.WithCancel(context.Background())
+	watcher, err := kv.WatchAll(runCtx)
+	if err != nil {
+		stop()
+		return nil, fmt.Errorf("failed to watch scheduler bucket %s: %w", bucket, err)
+	}
+
+	s := &scheduler{kv: kv, deliver: deliver, stop: stop, done: make(chan struct{})}
+	go s.run(runCtx, watcher)
+	return s, nil
+}
+
+// close stops delivering scheduled events. They stay in the bucket.
+func (s *scheduler) close() {
+	s.stop()
+	<-s.done
+}
+
+func (s *scheduler) schedule(ctx context.Context, topic string, event cloudevents.Event, at time.Time) error {
+	value, err := json.Marshal(scheduledEvent{Topic: topic, DeliverAt: at, Event: event})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if _, err := s.kv.Put(ctx, scheduleKey(event.ID()), value); err != nil {
+		return fmt.Errorf("failed to schedule event: %w", err)
+	}
+	return nil
+}
+
+func (s *scheduler) cancel(ctx context.Context, id string) error {
+	key := scheduleKey(id)
+	entry, err := s.kv.Get(ctx, key)
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return ErrNotScheduled
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up scheduled event: %w", err)
+	}
+
+	err = s.kv.Delete(ctx, key, jetstream.LastRevision(entry.Revision()))
+	if isWrongRevision(err) {
+		// Delivered or replaced since the lookup.
+		return ErrNotScheduled
+	}
+	if err != nil {
+		return fmt.Errorf("failed to cancel scheduled event: %w", err)
+	}
+	return nil
+}
+
+// run tracks the bucket contents and delivers the events as they become due.
+func (s *scheduler) run(ctx context.Context, watcher jetstream.KeyWatcher) {
+	defer close(s.done)
+	defer func() { _ = watcher.Stop() }()
+
+	var queue dueQueue
+	entries := make(map[string]jetstream.KeyValueEntry) // Latest entry of every scheduled key
+
+	for {
+		var wake <-chan time.Time
+		var timer *time.Timer
+		if queue.Len() > 0 {
+			timer = time.NewTimer(time.Until(queue[0].at))
+			wake = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case entry, ok := <-watcher.Updates():
+			if !ok {
+				return
+			}
+			// A nil entry marks the end of the initial values.
+			if entry != nil {
+				if at, ok := s.track(entries, entry); ok {
+					heap.Push(&queue, dueItem{at: at, key: entry.Key(), revision: entry.Revision()})
+				}
+			}
+		case <-wake:
+			now := time.Now()
+			for queue.Len() > 0 && !queue[0].at.After(now) {
+				item := heap.Pop(&queue).(dueItem)
+				// Items of replaced or removed entries are stale.
+				if entry, ok := entries[item.key]; ok && entry.Revision() == item.revision {
+					delete(entries, item.key)
+					s.deliverEntry(ctx, entry)
+				}
+			}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// track records a bucket update and returns the delivery time of a new scheduled event.
+func (s *scheduler) track(entries map[string]jetstream.KeyValueEntry, entry jetstream.KeyValueEntry) (time.Time, bool) {
+	if entry.Operation() != jetstream.KeyValuePut {
+		delete(entries, entry.Key())
+		return time.Time{}, false
+	}
+
+	var scheduled scheduledEvent
+	if err := json.Unmarshal(entry.Value(), &scheduled); err != nil {
+		log.Printf("Nats: ignoring undecodable scheduled event %s: %v", entry.Key(), err)
+		delete(entries, entry.Key())
+		return time.Time{}, false
+	}
+	entries[entry.Key()] = entry
+	return scheduled.DeliverAt, true
+}
+
+// deliverEntry claims a due event, publishes it and then removes it from the bucket.
+// The claim pushes the entry's delivery time out by claimTimeout, so the event is
+// delivered again if this bus fails before removing it. Events that fail to publish
+// are scheduled again after scheduleRetryDelay.
+func (s *scheduler) deliverEntry(ctx context.Context, entry jetstream.KeyValueEntry) {
+	var scheduled scheduledEvent
+	if err := json.Unmarshal(entry.Value(), &scheduled); err != nil {
+		log.Printf("Nats: dropping undecodable scheduled event %s: %v", entry.Key(), err)
+		s.remove(entry.Key(), entry.Revision())
+		return
+	}
+	if scheduled.MsgID == "" {
+		// Kept by later claims, so JetStream drops the copies of redeliveries.
+		scheduled.MsgID = fmt.Sprintf("%s-%d", entry.Key(), entry.Revision())
+	}
+
+	claimCtx, cancel := context.WithTimeout(ctx, jetStreamTimeout)
+	defer cancel()
+	revision, err := s.update(claimCtx, entry.Key(), entry.Revision(), scheduled, time.Now().Add(claimTimeout))
+	if isWrongRevision(err) {
+		// Cancelled, replaced or claimed by another bus.
+		return
+	}
+	if err != nil {
+		log.Printf("Nats: failed to claim scheduled event %s: %v", entry.Key(), err)
+		return
+	}
+
+	err = s.deliver(context.WithValue(claimCtx, msgIDKey{}, scheduled.MsgID), scheduled.Topic, scheduled.Event)
+
+	// The claim context may have expired while publishing.
+	ctx, cancel = context.WithTimeout(context.Background(), jetStreamTimeout)
+	defer cancel()
+	if err != nil {
+		log.Printf("Nats: failed to deliver scheduled event %s to topic '%s', retrying in %s: %v",
+			scheduled.Event.ID(), scheduled.Topic, scheduleRetryDelay, err)
+		// Fails if the event was scheduled again in the meantime; that schedule wins.
+		if _, err := s.update(ctx, entry.Key(), revision, scheduled, time.Now().Add(scheduleRetryDelay)); err != nil && !isWrongRevision(err) {
+			log.Printf("Nats: failed to reschedule event %s, retrying in %s: %v", scheduled.Event.ID(), claimTimeout, err)
+		}
+		return
+	}
+	s.remove(entry.Key(), revision)
+}
+
+// update stores a scheduled event with a new delivery time if its key is still at revision.
+func (s *scheduler) update(ctx context.Context, key string, revision uint64, scheduled scheduledEvent, at time.Time) (uint64, error) {
+	scheduled.DeliverAt = at
+	value, err := json.Marshal(scheduled)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode event: %w", err)
+	}
+	return s.kv.Update(ctx, key, value, revision)
+}
+
+// remove deletes a delivered event from the bucket unless it was scheduled again.
+func (s *scheduler) remove(key string, revision uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), jetStreamTimeout)
+	defer cancel()
+	if err := s.kv.Delete(ctx, key, jetstream.LastRevision(revision)); err != nil && !isWrongRevision(err) {
+		log.Printf("Nats: failed to remove scheduled event %s: %v", key, err)
+	}
+}
+
+// isWrongRevision reports whether a conditional delete failed because the key changed.
+func isWrongRevision(err error) bool {
+	var apiErr *jetstream.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode == jetstream.JSErrCodeStreamWrongLastSequence
+}
+
+// dueItem is a scheduled event revision waiting in the dueQueue.
+type dueItem struct {
+	at       time.Time
+	key      string
+	revision uint64
+}
+
+// dueQueue orders scheduled events by delivery time.
+type dueQueue []dueItem
+
+func (q dueQueue) Len() int           { return len(q) }
+func (q dueQueue) Less(i, j int) bool { return q[i].at.Before(q[j].at) }
+func (q dueQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *dueQueue) Push(x any)        { *q = append(*q, x.(dueItem)) }
+
+func (q *dueQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
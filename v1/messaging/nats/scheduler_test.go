@@ -0,0 +1,130 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSchedulingBus(t *testing.T, srv *server.Server, js JetStreamConfig) *NatsEventBus {
+	t.Helper()
+
+	bus, err := NewEventBus(&NatsConfig{URL: srv.ClientURL(), JetStream: js, Scheduler: SchedulerConfig{Enabled: true}})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bus.Close() })
+	return bus
+}
+
+func TestPublishAfter(t *testing.T) {
+	for name, js := range map[string]JetStreamConfig{"core": {}, "jetstream": {Enabled: true}} {
+		t.Run(name, func(t *testing.T) {
+			srv := runServer(t)
+			bus := newSchedulingBus(t, srv, js)
+
+			handler, received := collect()
+			require.NoError(t, bus.Subscribe("reminders", handler))
+
+			start := time.Now()
+			require.NoError(t, bus.PublishAfter(context.Background(), "reminders", newTestEvent("1"), 300*time.Millisecond))
+			require.NoError(t, bus.PublishAt(context.Background(), "reminders", newTestEvent("2"), time.Now().Add(-time.Second)))
+
+			assert.Equal(t, []string{"2", "1"}, receive(t, received, 2))
+			assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+			assertNoMore(t, received)
+		})
+	}
+}
+
+func TestCancelScheduled(t *testing.T) {
+	srv := runServer(t)
+	bus := newSchedulingBus(t, srv, JetStreamConfig{})
+
+	handler, received := collect()
+	require.NoError(t, bus.Subscribe("reminders", handler))
+
+	require.NoError(t, bus.PublishAfter(context.Background(), "reminders", newTestEvent("1"), 300*time.Millisecond))
+	require.NoError(t, bus.PublishAfter(context.Background(), "reminders", newTestEvent("2"), 300*time.Millisecond))
+	require.NoError(t, bus.CancelScheduled(context.Background(), "1"))
+	assert.ErrorIs(t, bus.CancelScheduled(context.Background(), "1"), ErrNotScheduled)
+
+	assert.Equal(t, []string{"2"}, receive(t, received, 1))
+	assertNoMore(t, received)
+	assert.ErrorIs(t, bus.CancelScheduled(context.Background(), "2"), ErrNotScheduled)
+}
+
+func TestScheduledEventsSurviveRestartAndAreDeliveredOnce(t *testing.T) {
+	srv := runServer(t)
+	producer := newSchedulingBus(t, srv, JetStreamConfig{})
+	require.NoError(t, producer.PublishAfter(context.Background(), "reminders", newTestEvent("1"), 300*time.Millisecond))
+	require.NoError(t, producer.Close())
+
+	// Both schedulers see the event; only one of them delivers it.
+	bus := newSchedulingBus(t, srv, JetStreamConfig{})
+	newSchedulingBus(t, srv, JetStreamConfig{})
+
+	handler, received := collect()
+	require.NoError(t, bus.Subscribe("reminders", handler))
+	assert.Equal(t, []string{"1"}, receive(t, received, 1))
+	assertNoMore(t, received)
+}
+
+func TestPublishAtRequiresScheduler(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{})
+
+	assert.Error(t, bus.PublishAfter(context.Background(), "reminders", newTestEvent("1"), time.Second))
+	assert.Error(t, bus.CancelScheduled(context.Background(), "1"))
+}
+
+func TestFailedScheduledDeliveryStaysInBucket(t *testing.T) {
+	srv := runServer(t)
+	nc, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+
+	attempts := make(chan string, 10)
+	sched, err := newScheduler(nc, SchedulerConfig{}, func(ctx context.Context, topic string, event cloudevents.Event) error {
+		attempts <- ctx.Value(msgIDKey{}).(string)
+		return errors.New("publish failed")
+	})
+	require.NoError(t, err)
+	t.Cleanup(sched.close)
+
+	start := time.Now()
+	require.NoError(t, sched.schedule(context.Background(), "reminders", newTestEvent("1"), start))
+	msgID := receive(t, attempts, 1)[0]
+
+	// The event waits for its retry instead of being lost.
+	assert.Eventually(t, func() bool {
+		entry, err := sched.kv.Get(context.Background(), scheduleKey("1"))
+		if err != nil {
+			return false
+		}
+		var scheduled scheduledEvent
+		require.NoError(t, json.Unmarshal(entry.Value(), &scheduled))
+		return scheduled.MsgID == msgID && !scheduled.DeliverAt.Before(start.Add(scheduleRetryDelay))
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestScheduledRedeliveryIsDeduplicated(t *testing.T) {
+	srv := runServer(t)
+	bus := newTestBus(t, srv, JetStreamConfig{Enabled: true})
+
+	handler, received := collect()
+	require.NoError(t, bus.Subscribe("reminders", handler))
+
+	ctx := context.WithValue(context.Background(), msgIDKey{}, "key-1")
+	require.NoError(t, bus.send(ctx, "reminders", newTestEvent("1")))
+	require.NoError(t, bus.send(ctx, "reminders", newTestEvent("1")))
+
+	assert.Equal(t, []string{"1"}, receive(t, received, 1))
+	assertNoMore(t, received)
+}
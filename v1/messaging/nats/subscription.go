@@ -0,0 +1,185 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Subscription is a handle to an active subscription created by SubscribeHandler.
+type Subscription struct {
+	topic string
+	group string
+	name  string
+
+	bus      *NatsEventBus
+	sub      *nats.Subscription       // Core NATS subscription
+	consumer jetstream.ConsumeContext // JetStream consumer
+	pool     *dispatcher
+	ctx      context.Context // Cancelled when the subscription stops
+	cancel   context.CancelFunc
+
+	mu       sync.Mutex // Protects stopped against concurrent handler starts
+	stopped  bool
+	handlers sync.WaitGroup // In-flight handler invocations
+	inFlight atomic.Int64
+}
+
+// Topic returns the subject the subscription listens on.
+func (s *Subscription) Topic() string {
+	return s.topic
+}
+
+// Group returns the consumer group of the subscription, if any.
+func (s *Subscription) Group() string {
+	return s.group
+}
+
+// InFlight returns the number of handler invocations currently running.
+func (s *Subscription) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// Unsubscribe stops delivery immediately. Buffered messages are discarded and
+// handlers already running are not waited for.
+func (s *Subscription) Unsubscribe() error {
+	s.stop()
+	s.bus.removeSubscription(s)
+
+	if s.consumer != nil {
+		s.consumer.Stop()
+		return nil
+	}
+	if err := s.sub.Unsubscribe(); err != nil && err != nats.ErrBadSubscription {
+		return fmt.Errorf("failed to unsubscribe: %w", err)
+	}
+	return nil
+}
+
+// Drain stops receiving new messages, lets already buffered messages be handled and
+// waits for in-flight handlers to finish. If ctx expires first, a *DrainError describing
+// the unfinished handlers is returned.
+func (s *Subscription) Drain(ctx context.Context) error {
+	s.bus.removeSubscription(s)
+
+	var closed <-chan struct{}
+	if s.consumer != nil {
+		s.consumer.Drain()
+		closed = s.consumer.Closed()
+	} else {
+		// The status channel is closed once the drained subscription is removed.
+		statuses := s.sub.StatusChanged(nats.SubscriptionClosed)
+		done := make(chan struct{})
+		if err := s.sub.Drain(); err != nil {
+			if err != nats.ErrBadSubscription && err != nats.ErrConnectionClosed {
+				return fmt.Errorf("failed to drain subscription: %w", err)
+			}
+			close(done)
+		} else {
+			go func() {
+				for range statuses {
+				}
+				close(done)
+			}()
+		}
+		closed = done
+	}
+
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		s.stop()
+		return &DrainError{Unfinished: []UnfinishedHandlers{s.unfinished()}}
+	}
+
+	s.stop()
+	if !wait(ctx, &s.handlers) {
+		return &DrainError{Unfinished: []UnfinishedHandlers{s.unfinished()}}
+	}
+	return nil
+}
+
+// dispatch runs fn as a tracked handler invocation for event. It blocks while the
+// subscription's handler pool is saturated and reports false once the subscription
+// has stopped.
+func (s *Subscription) dispatch(event cloudevents.Event, fn func()) bool {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return false
+	}
+	s.handlers.Add(1)
+	s.inFlight.Add(1)
+	s.mu.Unlock()
+
+	scheduled := s.pool.run(event, func() {
+		defer s.handlers.Done()
+		defer s.inFlight.Add(-1)
+		fn()
+	})
+	if !scheduled {
+		s.inFlight.Add(-1)
+		s.handlers.Done()
+	}
+	return scheduled
+}
+
+// stop prevents new handler invocations and releases callbacks blocked on the pool.
+func (s *Subscription) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	s.cancel()
+}
+
+func (s *Subscription) unfinished() UnfinishedHandlers {
+	return UnfinishedHandlers{Topic: s.topic, Group: s.group, Name: s.name, InFlight: s.InFlight()}
+}
+
+// UnfinishedHandlers describes handlers of a subscription that were still running
+// when a drain deadline expired.
+type UnfinishedHandlers struct {
+	Topic    string
+	Group    string
+	Name     string
+	InFlight int64
+}
+
+// DrainError is returned by Drain and Shutdown when handlers did not finish in time.
+type DrainError struct {
+	Unfinished []UnfinishedHandlers
+}
+
+func (e *DrainError) Error() string {
+	parts := make([]string, 0, len(e.Unfinished))
+	for _, u := range e.Unfinished {
+		if u.Group != "" {
+			parts = append(parts, fmt.Sprintf("%s (group %s): %d", u.Topic, u.Group, u.InFlight))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s: %d", u.Topic, u.InFlight))
+		}
+	}
+	return "handlers did not finish before the deadline: " + strings.Join(parts, ", ")
+}
+
+// wait blocks until wg is done or ctx expires and reports whether wg finished.
+func wait(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
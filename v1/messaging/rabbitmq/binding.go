@@ -0,0 +1,149 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Content modes of the CloudEvents AMQP protocol binding.
+const (
+	ContentModeStructured = "structured" // The whole event as a JSON body (default)
+	ContentModeBinary     = "binary"     // Attributes as "cloudEvents_" headers, data as the body
+)
+
+const (
+	headerPrefix          = "cloudEvents_"
+	structuredContentType = "application/cloudevents+json"
+)
+
+func validateContentMode(mode string) error {
+	switch mode {
+	case "", ContentModeStructured, ContentModeBinary:
+		return nil
+	}
+	return fmt.Errorf("unsupported content mode %q", mode)
+}
+
+// encodePublishing encodes an event as a persistent AMQP message in the given content mode.
+// The message ID and type properties mirror the event so that brokers and tooling can
+// inspect them without decoding the event.
+func encodePublishing(event cloudevents.Event, mode string) (amqp.Publishing, error) {
+	msg := amqp.Publishing{
+		DeliveryMode: amqp.Persistent,
+		MessageId:    event.ID(),
+		Type:         event.Type(),
+		Timestamp:    event.Time(),
+	}
+
+	if mode != ContentModeBinary {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return amqp.Publishing{}, err
+		}
+		msg.ContentType = structuredContentType
+		msg.Body = data
+		return msg, nil
+	}
+
+	attrs, err := eventAttributes(event)
+	if err != nil {
+		return amqp.Publishing{}, err
+	}
+	msg.Headers = make(amqp.Table, len(attrs))
+	for name, value := range attrs {
+		msg.Headers[headerPrefix+name] = value
+	}
+	msg.ContentType = event.DataContentType()
+	msg.Body = event.Data()
+	return msg, nil
+}
+
+// decodeDelivery decodes a delivery in either content mode. Deliveries carrying a
+// "cloudEvents_specversion" header are in binary mode; anything else is a structured JSON event.
+func decodeDelivery(d amqp.Delivery) (cloudevents.Event, error) {
+	attrs := make(map[string]string)
+	for key, value := range d.Headers {
+		if strings.HasPrefix(key, headerPrefix) {
+			attrs[strings.TrimPrefix(key, headerPrefix)] = fmt.Sprint(value)
+		}
+	}
+
+	if _, ok := attrs["specversion"]; !ok {
+		var event cloudevents.Event
+		err := json.Unmarshal(d.Body, &event)
+		return event, err
+	}
+	return eventFromAttributes(attrs, d.ContentType, d.Body)
+}
+
+// eventAttributes returns the context attributes and extensions of an event as strings,
+// except datacontenttype which protocol bindings carry in their own content type field.
+func eventAttributes(event cloudevents.Event) (map[string]string, error) {
+	attrs := map[string]string{
+		"specversion": event.SpecVersion(),
+		"id":          event.ID(),
+		"source":      event.Source(),
+		"type":        event.Type(),
+	}
+	if v := event.Subject(); v != "" {
+		attrs["subject"] = v
+	}
+	if v := event.DataSchema(); v != "" {
+		attrs["dataschema"] = v
+	}
+	if v := event.Time(); !v.IsZero() {
+		attrs["time"] = types.FormatTime(v)
+	}
+	for name, value := range event.Extensions() {
+		s, err := types.Format(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extension %s: %w", name, err)
+		}
+		attrs[name] = s
+	}
+	return attrs, nil
+}
+
+// eventFromAttributes builds an event from string attributes as produced by eventAttributes.
+// Unknown attributes become extensions.
+func eventFromAttributes(attrs map[string]string, contentType string, data []byte) (cloudevents.Event, error) {
+	event := cloudevents.NewEvent(attrs["specversion"])
+	for name, value := range attrs {
+		switch name {
+		case "specversion":
+		case "id":
+			event.SetID(value)
+		case "source":
+			event.SetSource(value)
+		case "type":
+			event.SetType(value)
+		case "subject":
+			event.SetSubject(value)
+		case "dataschema":
+			event.SetDataSchema(value)
+		case "time":
+			t, err := types.ParseTime(value)
+			if err != nil {
+				return cloudevents.Event{}, fmt.Errorf("invalid time attribute: %w", err)
+			}
+			event.SetTime(t)
+		default:
+			event.SetExtension(name, value)
+		}
+	}
+	if contentType != "" {
+		event.SetDataContentType(contentType)
+	}
+	if len(data) > 0 {
+		event.DataEncoded = data
+	}
+	if err := event.Validate(); err != nil {
+		return cloudevents.Event{}, err
+	}
+	return event, nil
+}
@@ -0,0 +1,82 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBindingEvent() cloudevents.Event {
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetType("order.created")
+	event.SetSource("rabbitmq_test")
+	event.SetSubject("orders/1")
+	event.SetTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	event.SetExtension("tenant", "acme")
+	_ = event.SetData(cloudevents.ApplicationJSON, map[string]string{"id": "1"})
+	return event
+}
+
+// delivery turns a publishing into the delivery a consumer would receive.
+func delivery(msg amqp.Publishing) amqp.Delivery {
+	return amqp.Delivery{Headers: msg.Headers, ContentType: msg.ContentType, Body: msg.Body}
+}
+
+func TestEncodePublishingStructured(t *testing.T) {
+	event := newBindingEvent()
+
+	msg, err := encodePublishing(event, ContentModeStructured)
+	require.NoError(t, err)
+	assert.Equal(t, structuredContentType, msg.ContentType)
+	assert.Equal(t, amqp.Persistent, msg.DeliveryMode)
+	assert.Equal(t, "1", msg.MessageId)
+	assert.Equal(t, "order.created", msg.Type)
+	assert.Empty(t, msg.Headers)
+
+	decoded, err := decodeDelivery(delivery(msg))
+	require.NoError(t, err)
+	assert.Equal(t, event.String(), decoded.String())
+}
+
+func TestEncodePublishingBinary(t *testing.T) {
+	event := newBindingEvent()
+
+	msg, err := encodePublishing(event, ContentModeBinary)
+	require.NoError(t, err)
+	assert.Equal(t, cloudevents.ApplicationJSON, msg.ContentType)
+	assert.Equal(t, "1.0", msg.Headers["cloudEvents_specversion"])
+	assert.Equal(t, "order.created", msg.Headers["cloudEvents_type"])
+	assert.Equal(t, "acme", msg.Headers["cloudEvents_tenant"])
+	assert.JSONEq(t, `{"id":"1"}`, string(msg.Body))
+
+	decoded, err := decodeDelivery(delivery(msg))
+	require.NoError(t, err)
+	assert.Equal(t, event.String(), decoded.String())
+}
+
+func TestDecodeDeliveryRejectsInvalidEvents(t *testing.T) {
+	_, err := decodeDelivery(amqp.Delivery{Body: []byte("not json")})
+	assert.Error(t, err)
+
+	_, err = decodeDelivery(amqp.Delivery{Headers: amqp.Table{"cloudEvents_specversion": "1.0"}})
+	assert.Error(t, err)
+}
+
+func TestBindingKey(t *testing.T) {
+	tests := map[string]string{
+		"orders.created": "orders.created",
+		"orders.*":       "orders.*",
+		"orders.#":       "orders.#",
+		"orders.>":       "orders.#",
+		">":              "#",
+		"orders>":        "orders>",
+	}
+	for topic, expected := range tests {
+		assert.Equal(t, expected, bindingKey(topic), topic)
+	}
+}
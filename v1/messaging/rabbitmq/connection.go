@@ -0,0 +1,105 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var errConnectionLost = errors.New("connection to RabbitMQ lost")
+
+// connect dials the broker, declares the exchanges and opens the publishing channel
+// in confirm mode. A watcher reconnects once the new connection is lost.
+func (b *RabbitMQEventBus) connect() error {
+	conn, err := amqp.Dial(b.cfg.URL)
+	if err != nil {
+		return fmt.Errorf("unable to connect to RabbitMQ: %w", err)
+	}
+
+	pub, err := conn.Channel()
+	if err == nil {
+		err = b.declareExchanges(pub)
+	}
+	if err == nil {
+		err = pub.Confirm(false)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("unable to prepare RabbitMQ channel: %w", err)
+	}
+
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		_ = conn.Close()
+		return errClosed
+	}
+	b.conn = conn
+	b.pub = pub
+	b.mu.Unlock()
+
+	go b.watch(closed)
+	return nil
+}
+
+func (b *RabbitMQEventBus) declareExchanges(ch *amqp.Channel) error {
+	if err := ch.ExchangeDeclare(b.cfg.Exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		return err
+	}
+	if b.cfg.DeadLetterExchange != "" {
+		return ch.ExchangeDeclare(b.cfg.DeadLetterExchange, amqp.ExchangeDirect, true, false, false, false, nil)
+	}
+	return nil
+}
+
+// watch waits for the connection to close and reconnects every ReconnectWait, up to
+// MaxReconnects attempts. A connection closed by Close or Shutdown is not recovered.
+// Subscriptions reopen their channels on their own once the connection is back.
+func (b *RabbitMQEventBus) watch(closed <-chan *amqp.Error) {
+	reason, ok := <-closed
+	if !ok || reason == nil {
+		return
+	}
+	log.Printf("RabbitMQ: connection lost: %v", reason)
+
+	for attempt := 1; b.cfg.MaxReconnects < 0 || attempt <= b.cfg.MaxReconnects; attempt++ {
+		select {
+		case <-time.After(b.cfg.ReconnectWait):
+		case <-b.done:
+			return
+		}
+
+		err := b.connect()
+		if err == nil {
+			log.Printf("RabbitMQ: reconnected after %d attempt(s)", attempt)
+			return
+		}
+		if errors.Is(err, errClosed) {
+			return
+		}
+		log.Printf("RabbitMQ: reconnect attempt %d failed: %v", attempt, err)
+	}
+
+	log.Printf("RabbitMQ: giving up after %d reconnect attempts", b.cfg.MaxReconnects)
+	b.mu.Lock()
+	b.lost = true
+	b.mu.Unlock()
+}
+
+// channel opens a new channel on the current connection.
+func (b *RabbitMQEventBus) channel() (*amqp.Channel, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	switch {
+	case b.closed:
+		return nil, errClosed
+	case b.lost:
+		return nil, errConnectionLost
+	}
+	return b.conn.Channel()
+}
@@ -0,0 +1,52 @@
+package rabbitmq
+
+import (
+	"context"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+)
+
+// Handler processes an event and reports whether it succeeded.
+// A delivery is acknowledged only when the handler returns nil; otherwise it is
+// requeued, or dead-lettered when a dead-letter exchange is configured.
+type Handler func(ctx context.Context, event cloudevents.Event) error
+
+// SubscriptionOptions extends messaging.SubscriptionOptions with RabbitMQ specific settings.
+type SubscriptionOptions struct {
+	messaging.SubscriptionOptions
+	PrefetchCount int // Overrides RabbitMQConfig.PrefetchCount for a single subscription
+}
+
+// SubscriptionOption defines a function to set RabbitMQ subscription options.
+type SubscriptionOption func(opts *SubscriptionOptions)
+
+// WithConsumerGroup specifies the consumer group.
+func WithConsumerGroup(group string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.Group = group
+	}
+}
+
+// WithConsumerName specifies the consumer name, used as the consumer tag.
+func WithConsumerName(name string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.Name = name
+	}
+}
+
+// WithPrefetchCount bounds the deliveries the broker sends ahead of acknowledgements.
+func WithPrefetchCount(n int) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.PrefetchCount = n
+	}
+}
+
+// WithMessagingOptions applies the generic messaging subscription options.
+func WithMessagingOptions(options ...messaging.SubscriptionOption) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		for _, o := range options {
+			o(&opts.SubscriptionOptions)
+		}
+	}
+}
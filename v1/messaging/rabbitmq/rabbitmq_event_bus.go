@@ -0,0 +1,285 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/config"
+	"github.com/ebrickdev/ebrick/messaging"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	defaultExchange      = "events"
+	defaultPrefetchCount = 10
+	defaultRetryBackoff  = time.Second
+	defaultMaxReconnects = 10
+	defaultReconnectWait = 2 * time.Second
+	// defaultShutdownTimeout is how long Close waits for in-flight handlers.
+	defaultShutdownTimeout = 10 * time.Second
+)
+
+var errClosed = errors.New("eventbus is closed")
+
+// Init loads configuration and sets up the default event bus.
+func Init() *RabbitMQEventBus {
+	var cfg RabbitMQConfig
+	err := config.LoadConfigByKey("application", "messaging.rabbitmq", []string{"."}, &cfg, map[string]any{})
+	if err != nil {
+		log.Fatalf("RabbitMQ: unable to load RabbitMQ config: %v", err)
+	}
+	bus, err := NewEventBus(&cfg)
+	if err != nil {
+		log.Fatalf("RabbitMQ: %v", err)
+	}
+	return bus
+}
+
+// RabbitMQEventBus publishes events to a topic exchange and consumes them from queues
+// bound to it. The connection is recovered automatically when it is lost.
+type RabbitMQEventBus struct {
+	cfg  RabbitMQConfig
+	done chan struct{} // Closed on shutdown to stop reconnecting
+
+	mu     sync.RWMutex // Protects conn, pub, closed, lost and subs
+	conn   *amqp.Connection
+	pub    *amqp.Channel // Publishing channel in confirm mode
+	closed bool
+	lost   bool // Set once reconnecting was given up
+	subs   map[*Subscription]struct{}
+}
+
+// NewEventBus creates a new RabbitMQEventBus, connects to the broker and declares the exchanges.
+func NewEventBus(cfg *RabbitMQConfig) (*RabbitMQEventBus, error) {
+	if err := validateContentMode(cfg.ContentMode); err != nil {
+		return nil, err
+	}
+
+	b := &RabbitMQEventBus{
+		cfg:  *cfg,
+		done: make(chan struct{}),
+		subs: make(map[*Subscription]struct{}),
+	}
+	if b.cfg.Exchange == "" {
+		b.cfg.Exchange = defaultExchange
+	}
+	if b.cfg.PrefetchCount <= 0 {
+		b.cfg.PrefetchCount = defaultPrefetchCount
+	}
+	if b.cfg.RetryBackoff <= 0 {
+		b.cfg.RetryBackoff = defaultRetryBackoff
+	}
+	if b.cfg.MaxReconnects == 0 {
+		b.cfg.MaxReconnects = defaultMaxReconnects
+	}
+	if b.cfg.ReconnectWait <= 0 {
+		b.cfg.ReconnectWait = defaultReconnectWait
+	}
+
+	if err := b.connect(); err != nil {
+		return nil, err
+	}
+	log.Println("RabbitMQ: RabbitMQ event bus initialized successfully")
+	return b, nil
+}
+
+// Publish encodes the event in the configured content mode, publishes it to the exchange
+// with the topic as routing key and waits for the broker to confirm it.
+func (b *RabbitMQEventBus) Publish(ctx context.Context, topic string, event cloudevents.Event) error {
+	if topic == "" {
+		return errors.New("topic must not be empty")
+	}
+	if event.Type() == "" || event.ID() == "" {
+		return errors.New("event must have a valid ID and Type")
+	}
+
+	msg, err := encodePublishing(event, b.cfg.ContentMode)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	b.mu.RLock()
+	pub, closed, lost := b.pub, b.closed, b.lost
+	b.mu.RUnlock()
+	switch {
+	case closed:
+		return errClosed
+	case lost:
+		return errConnectionLost
+	}
+
+	confirm, err := pub.PublishWithDeferredConfirmWithContext(ctx, b.cfg.Exchange, topic, false, false, msg)
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	if !acked {
+		return errors.New("failed to publish event: not confirmed by the broker")
+	}
+	return nil
+}
+
+// Subscribe registers a handler for the specified topic. Deliveries are acknowledged
+// once the handler returns; use SubscribeHandler to report failures and have deliveries
+// requeued or dead-lettered, or to obtain a Subscription handle.
+// Subscriptions created here are stopped by Close or Shutdown.
+func (b *RabbitMQEventBus) Subscribe(topic string, handler func(ctx context.Context, event cloudevents.Event), options ...messaging.SubscriptionOption) error {
+	_, err := b.SubscribeHandler(topic, func(ctx context.Context, event cloudevents.Event) error {
+		handler(ctx, event)
+		return nil
+	}, WithMessagingOptions(options...))
+	return err
+}
+
+// SubscribeHandler subscribes to a topic with a handler that reports failures.
+//   - With a consumer group (SubscriptionOptions.Group) the members share the durable
+//     queue "<group>.<topic>", so each event is handled once per group and events
+//     published while no member is running are kept.
+//   - Without a group the subscription gets an exclusive queue of its own that only
+//     exists while it is connected.
+//
+// A delivery is acknowledged after its handler succeeded. Failed deliveries are requeued
+// after RetryBackoff, or rejected to the dead-letter exchange when one is configured.
+// The topic may use the "*" and "#" (or ">") wildcards of topic exchanges.
+func (b *RabbitMQEventBus) SubscribeHandler(topic string, handler Handler, options ...SubscriptionOption) (*Subscription, error) {
+	if topic == "" {
+		return nil, errors.New("topic must not be empty")
+	}
+
+	opts := SubscriptionOptions{PrefetchCount: b.cfg.PrefetchCount}
+	for _, o := range options {
+		o(&opts)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &Subscription{
+		topic:      topic,
+		group:      opts.Group,
+		name:       opts.Name,
+		prefetch:   opts.PrefetchCount,
+		deadLetter: opts.Group != "" && b.cfg.DeadLetterExchange != "",
+		handler:    handler,
+		bus:        b,
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+
+	ch, deliveries, err := b.openConsumer(sub)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to subscribe to event: %w", err)
+	}
+	if err := b.addSubscription(sub); err != nil {
+		cancel()
+		_ = ch.Close()
+		return nil, err
+	}
+
+	go sub.consume(ch, deliveries)
+	if opts.Group != "" {
+		log.Printf("RabbitMQ: Joining consumer group '%s' on topic '%s'", opts.Group, topic)
+	}
+	if opts.Name != "" {
+		log.Printf("RabbitMQ: Subscriber '%s'", opts.Name)
+	}
+	return sub, nil
+}
+
+// openConsumer opens a channel for a subscription, declares its queue and starts consuming.
+func (b *RabbitMQEventBus) openConsumer(sub *Subscription) (*amqp.Channel, <-chan amqp.Delivery, error) {
+	ch, err := b.channel()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deliveries, err := func() (<-chan amqp.Delivery, error) {
+		if err := ch.Qos(sub.prefetch, 0, false); err != nil {
+			return nil, err
+		}
+		queue, err := b.declareQueue(ch, sub.topic, sub.group)
+		if err != nil {
+			return nil, err
+		}
+		return ch.Consume(queue, sub.name, false, false, false, false, nil)
+	}()
+	if err != nil {
+		_ = ch.Close()
+		return nil, nil, err
+	}
+	return ch, deliveries, nil
+}
+
+// Close stops all subscriptions, waits up to 10 seconds for in-flight handlers
+// and closes the connection. Use Shutdown to control the deadline.
+func (b *RabbitMQEventBus) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	return b.Shutdown(ctx)
+}
+
+// Shutdown stops consuming on all subscriptions, waits for in-flight handlers until ctx
+// expires and closes the connection. Handlers that did not finish are reported in a
+// *DrainError; their deliveries are returned to the queue by the broker.
+func (b *RabbitMQEventBus) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return errors.New("eventbus is already closed")
+	}
+	b.closed = true
+	close(b.done)
+	subs := make([]*Subscription, 0, len(b.subs))
+	for sub := range b.subs {
+		subs = append(subs, sub)
+	}
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.cancel()
+	}
+
+	var drainErr DrainError
+	for _, sub := range subs {
+		var err *DrainError
+		if errors.As(sub.Drain(ctx), &err) {
+			drainErr.Unfinished = append(drainErr.Unfinished, err.Unfinished...)
+		}
+	}
+
+	b.mu.RLock()
+	conn := b.conn
+	b.mu.RUnlock()
+	if err := conn.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		return err
+	}
+	if len(drainErr.Unfinished) > 0 {
+		return &drainErr
+	}
+	return nil
+}
+
+// addSubscription registers a subscription unless the bus is closed.
+func (b *RabbitMQEventBus) addSubscription(sub *Subscription) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errClosed
+	}
+	b.subs[sub] = struct{}{}
+	return nil
+}
+
+func (b *RabbitMQEventBus) removeSubscription(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, sub)
+}
@@ -0,0 +1,198 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Subscription is a handle to an active subscription created by SubscribeHandler.
+type Subscription struct {
+	topic      string
+	group      string
+	name       string
+	prefetch   int
+	deadLetter bool // Reject failed deliveries to the dead-letter exchange instead of requeuing them
+	handler    Handler
+
+	bus      *RabbitMQEventBus
+	ctx      context.Context // Cancelled when the subscription stops consuming
+	cancel   context.CancelFunc
+	done     chan struct{} // Closed once the consume loop has returned
+	inFlight atomic.Int64
+}
+
+// Topic returns the topic the subscription is bound to.
+func (s *Subscription) Topic() string {
+	return s.topic
+}
+
+// Group returns the consumer group of the subscription, if any.
+func (s *Subscription) Group() string {
+	return s.group
+}
+
+// InFlight returns the number of handler invocations currently running.
+func (s *Subscription) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// Unsubscribe stops consuming. A handler already running is not waited for; its
+// delivery is still acknowledged when it succeeds.
+func (s *Subscription) Unsubscribe() error {
+	s.cancel()
+	s.bus.removeSubscription(s)
+	return nil
+}
+
+// Drain stops consuming and waits for the in-flight handler to finish and its delivery
+// to be settled. Prefetched deliveries that were not handled are returned to the queue.
+// If ctx expires first, a *DrainError describing the unfinished handler is returned.
+func (s *Subscription) Drain(ctx context.Context) error {
+	_ = s.Unsubscribe()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return &DrainError{Unfinished: []UnfinishedHandlers{s.unfinished()}}
+	}
+}
+
+// consume handles deliveries until the subscription stops. When the channel or the
+// connection is lost, the queue is declared again on a new channel every ReconnectWait
+// until the bus has reconnected or given up.
+func (s *Subscription) consume(ch *amqp.Channel, deliveries <-chan amqp.Delivery) {
+	defer close(s.done)
+
+	for {
+		s.handleDeliveries(deliveries)
+		// Closing the channel returns unacknowledged deliveries to the queue.
+		_ = ch.Close()
+		if s.ctx.Err() != nil {
+			return
+		}
+		log.Printf("RabbitMQ: consumer on topic '%s' interrupted, resubscribing", s.topic)
+
+		for {
+			if !s.sleep(s.bus.cfg.ReconnectWait) {
+				return
+			}
+			var err error
+			ch, deliveries, err = s.bus.openConsumer(s)
+			if err == nil {
+				break
+			}
+			if errors.Is(err, errClosed) || errors.Is(err, errConnectionLost) {
+				log.Printf("RabbitMQ: consumer on topic '%s' stopped: %v", s.topic, err)
+				return
+			}
+			log.Printf("RabbitMQ: failed to resubscribe to topic '%s': %v", s.topic, err)
+		}
+	}
+}
+
+// handleDeliveries runs the handler for each delivery, one at a time, until the
+// subscription stops or the deliveries channel is closed.
+func (s *Subscription) handleDeliveries(deliveries <-chan amqp.Delivery) {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case d, ok := <-deliveries:
+			if !ok {
+				return
+			}
+			s.handle(d)
+		}
+	}
+}
+
+func (s *Subscription) handle(d amqp.Delivery) {
+	event, err := decodeDelivery(d)
+	if err != nil {
+		// An undecodable delivery will never succeed, so do not requeue it.
+		log.Printf("RabbitMQ: failed to decode event on topic '%s': %v", s.topic, err)
+		s.settle(d.Nack(false, false))
+		return
+	}
+
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+
+	if err := invokeHandler(context.Background(), s.handler, event); err != nil {
+		log.Printf("RabbitMQ: handler failed on topic '%s': %v", s.topic, err)
+		if s.deadLetter {
+			s.settle(d.Nack(false, false))
+			return
+		}
+		// Requeued deliveries are redelivered right away, so back off first.
+		s.sleep(s.bus.cfg.RetryBackoff)
+		s.settle(d.Nack(false, true))
+		return
+	}
+	s.settle(d.Ack(false))
+}
+
+// invokeHandler calls the handler, converting a panic into an error.
+func invokeHandler(ctx context.Context, handler Handler, event cloudevents.Event) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("handler panicked: %v", rec)
+		}
+	}()
+	return handler(ctx, event)
+}
+
+func (s *Subscription) settle(err error) {
+	if err != nil {
+		log.Printf("RabbitMQ: failed to settle delivery on topic '%s': %v", s.topic, err)
+	}
+}
+
+// sleep waits for d and reports false if the subscription stopped in the meantime.
+func (s *Subscription) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+func (s *Subscription) unfinished() UnfinishedHandlers {
+	return UnfinishedHandlers{Topic: s.topic, Group: s.group, Name: s.name, InFlight: s.InFlight()}
+}
+
+// UnfinishedHandlers describes handlers of a subscription that were still running
+// when a drain deadline expired.
+type UnfinishedHandlers struct {
+	Topic    string
+	Group    string
+	Name     string
+	InFlight int64
+}
+
+// DrainError is returned by Drain and Shutdown when handlers did not finish in time.
+type DrainError struct {
+	Unfinished []UnfinishedHandlers
+}
+
+func (e *DrainError) Error() string {
+	parts := make([]string, 0, len(e.Unfinished))
+	for _, u := range e.Unfinished {
+		if u.Group != "" {
+			parts = append(parts, fmt.Sprintf("%s (group %s): %d", u.Topic, u.Group, u.InFlight))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s: %d", u.Topic, u.InFlight))
+		}
+	}
+	return "handlers did not finish before the deadline: " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,68 @@
+package rabbitmq
+
+import (
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// bindingKey translates a topic into a binding key of the topic exchange. Topics use
+// the same dot-separated tokens as routing keys; the NATS style ">" wildcard is accepted
+// as an alias of "#" so that topics can be shared with the other event buses.
+func bindingKey(topic string) string {
+	if topic == ">" {
+		return "#"
+	}
+	if strings.HasSuffix(topic, ".>") {
+		return strings.TrimSuffix(topic, ">") + "#"
+	}
+	return topic
+}
+
+// queueName returns the durable queue shared by the members of a consumer group.
+func queueName(group, topic string) string {
+	return group + "." + topic
+}
+
+// deadLetterQueueName returns the queue collecting the dead-lettered deliveries of queue.
+func deadLetterQueueName(queue string) string {
+	return queue + ".dlq"
+}
+
+// declareQueue declares the queue of a subscription and binds it to the exchange.
+//   - Consumer groups share a durable queue that survives restarts. With a dead-letter
+//     exchange, rejected deliveries are routed to "<queue>.dlq".
+//   - Other subscriptions get an exclusive, server-named queue that is deleted when the
+//     subscription's channel closes.
+//
+// A queue's arguments cannot change once declared, so enabling dead-lettering for an
+// existing group queue requires deleting the queue first.
+func (b *RabbitMQEventBus) declareQueue(ch *amqp.Channel, topic, group string) (string, error) {
+	if group == "" {
+		q, err := ch.QueueDeclare("", false, true, true, false, nil)
+		if err != nil {
+			return "", err
+		}
+		return q.Name, ch.QueueBind(q.Name, bindingKey(topic), b.cfg.Exchange, false, nil)
+	}
+
+	name := queueName(group, topic)
+	var args amqp.Table
+	if dlx := b.cfg.DeadLetterExchange; dlx != "" {
+		dlq := deadLetterQueueName(name)
+		if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+			return "", err
+		}
+		if err := ch.QueueBind(dlq, name, dlx, false, nil); err != nil {
+			return "", err
+		}
+		args = amqp.Table{
+			"x-dead-letter-exchange":    dlx,
+			"x-dead-letter-routing-key": name,
+		}
+	}
+	if _, err := ch.QueueDeclare(name, true, false, false, false, args); err != nil {
+		return "", err
+	}
+	return name, ch.QueueBind(name, bindingKey(topic), b.cfg.Exchange, false, nil)
+}
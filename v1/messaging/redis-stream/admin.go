@@ -0,0 +1,324 @@
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultPendingCount = 100
+	lagBatchSize        = 1000
+	// maxLagCount bounds the entries read to count the lag Redis cannot report.
+	maxLagCount = 10 * lagBatchSize
+)
+
+var (
+	// ErrStreamNotFound is returned by the admin API when a stream does not exist.
+	ErrStreamNotFound = errors.New("stream not found")
+	// ErrGroupNotFound is returned by the admin API when a consumer group does not exist.
+	ErrGroupNotFound = errors.New("consumer group not found")
+)
+
+// StreamInfo describes a stream.
+type StreamInfo struct {
+	Name            string
+	Length          int64
+	Groups          int64
+	FirstEntryID    string
+	LastEntryID     string
+	LastGeneratedID string // ID of the last entry ever added, even if deleted since
+}
+
+// GroupInfo describes a consumer group of a stream.
+type GroupInfo struct {
+	Name            string
+	Consumers       int64
+	Pending         int64  // Entries delivered but not acknowledged
+	LastDeliveredID string // Last entry delivered to the group
+	Lag             int64  // Entries not delivered to the group yet
+	LagApproximate  bool   // Lag is a lower bound, counting stopped after maxLagCount entries
+}
+
+// ConsumerInfo describes a consumer of a group.
+type ConsumerInfo struct {
+	Name     string
+	Pending  int64
+	Idle     time.Duration // Since the consumer last read or claimed entries
+	Inactive time.Duration // Since the consumer last read entries successfully, Redis 7.2 and later
+}
+
+// PendingEntry is an entry delivered to a consumer group but not acknowledged.
+type PendingEntry struct {
+	ID         string
+	Consumer   string
+	Idle       time.Duration
+	Deliveries int64
+}
+
+// PendingQuery selects the pending entries returned by Pending.
+type PendingQuery struct {
+	Consumer string        // Only entries of this consumer, if set
+	After    string        // Only entries after this ID, to page through the list
+	Count    int64         // Maximum number of entries, 0 uses 100
+	MinIdle  time.Duration // Only entries idle for at least this long
+}
+
+// Streams lists the streams matching a topic pattern, or all streams including the
+// dead-letter streams when pattern is empty, in name order.
+func (r *RedisStream) Streams(ctx context.Context, pattern string) ([]StreamInfo, error) {
+	var (
+		names []string
+		err   error
+	)
+	if pattern == "" {
+		names, err = r.scanStreams(ctx, "*", func(string) bool { return true })
+	} else {
+		if err := validatePattern(pattern); err != nil {
+			return nil, err
+		}
+		names, err = r.matchingStreams(ctx, pattern)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list streams: %w", err)
+	}
+
+	streams := make([]StreamInfo, 0, len(names))
+	for _, name := range names {
+		info, err := r.Stream(ctx, name)
+		if errors.Is(err, ErrStreamNotFound) {
+			// Deleted since the scan.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		streams = append(streams, *info)
+	}
+	return streams, nil
+}
+
+// Stream describes a single stream.
+func (r *RedisStream) Stream(ctx context.Context, topic string) (*StreamInfo, error) {
+	info, err := r.client.XInfoStream(ctx, topic).Result()
+	if err != nil {
+		return nil, adminError(err, "failed to describe stream %s", topic)
+	}
+	return &StreamInfo{
+		Name:            topic,
+		Length:          info.Length,
+		Groups:          info.Groups,
+		FirstEntryID:    info.FirstEntry.ID,
+		LastEntryID:     info.LastEntry.ID,
+		LastGeneratedID: info.LastGeneratedID,
+	}, nil
+}
+
+// Groups lists the consumer groups of a stream with their lag.
+func (r *RedisStream) Groups(ctx context.Context, topic string) ([]GroupInfo, error) {
+	groups, err := r.client.XInfoGroups(ctx, topic).Result()
+	if err != nil {
+		return nil, adminError(err, "failed to list consumer groups of %s", topic)
+	}
+	if len(groups) == 0 {
+		return []GroupInfo{}, nil
+	}
+	stream, err := r.Stream(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]GroupInfo, 0, len(groups))
+	for _, group := range groups {
+		lag, exact, err := r.groupLag(ctx, topic, group, stream.LastGeneratedID)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, GroupInfo{
+			Name:            group.Name,
+			Consumers:       group.Consumers,
+			Pending:         group.Pending,
+			LastDeliveredID: group.LastDeliveredID,
+			Lag:             lag,
+			LagApproximate:  !exact,
+		})
+	}
+	return infos, nil
+}
+
+// Group describes a single consumer group of a stream.
+func (r *RedisStream) Group(ctx context.Context, topic, group string) (*GroupInfo, error) {
+	groups, err := r.Groups(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		if g.Name == group {
+			return &g, nil
+		}
+	}
+	return nil, fmt.Errorf("consumer group %s of stream %s: %w", group, topic, ErrGroupNotFound)
+}
+
+// Lag returns the number of entries of a stream not yet delivered to a consumer group.
+// When Redis cannot report it, the lag is counted up to maxLagCount entries; use Group
+// to tell whether the count stopped there.
+func (r *RedisStream) Lag(ctx context.Context, topic, group string) (int64, error) {
+	info, err := r.Group(ctx, topic, group)
+	if err != nil {
+		return 0, err
+	}
+	return info.Lag, nil
+}
+
+// groupLag uses the lag Redis reports and counts the undelivered entries when Redis
+// cannot tell, e.g. before Redis 7 or after the group was reset or entries were deleted.
+// Counting stops after maxLagCount entries; the lag is then a lower bound and exact
+// is false.
+func (r *RedisStream) groupLag(ctx context.Context, topic string, group redis.XInfoGroup, lastGeneratedID string) (lag int64, exact bool, err error) {
+	if group.Lag > 0 {
+		return group.Lag, true, nil
+	}
+	if compareIDs(group.LastDeliveredID, lastGeneratedID) >= 0 {
+		return 0, true, nil
+	}
+
+	start := "(" + group.LastDeliveredID
+	for lag < maxLagCount {
+		messages, err := r.client.XRangeN(ctx, topic, start, "+", lagBatchSize).Result()
+		if err != nil {
+			return 0, false, adminError(err, "failed to compute lag of group %s", group.Name)
+		}
+		lag += int64(len(messages))
+		if len(messages) < lagBatchSize {
+			return lag, true, nil
+		}
+		start = "(" + messages[len(messages)-1].ID
+	}
+	return lag, false, nil
+}
+
+// Consumers lists the consumers of a group.
+func (r *RedisStream) Consumers(ctx context.Context, topic, group string) ([]ConsumerInfo, error) {
+	consumers, err := r.client.XInfoConsumers(ctx, topic, group).Result()
+	if err != nil {
+		return nil, adminError(err, "failed to list consumers of group %s", group)
+	}
+
+	infos := make([]ConsumerInfo, 0, len(consumers))
+	for _, c := range consumers {
+		infos = append(infos, ConsumerInfo{Name: c.Name, Pending: c.Pending, Idle: c.Idle, Inactive: c.Inactive})
+	}
+	return infos, nil
+}
+
+// Pending lists the pending entries of a group, oldest first.
+func (r *RedisStream) Pending(ctx context.Context, topic, group string, query PendingQuery) ([]PendingEntry, error) {
+	args := &redis.XPendingExtArgs{
+		Stream:   topic,
+		Group:    group,
+		Idle:     query.MinIdle,
+		Start:    "-",
+		End:      "+",
+		Count:    query.Count,
+		Consumer: query.Consumer,
+	}
+	if query.After != "" {
+		args.Start = "(" + query.After
+	}
+	if args.Count <= 0 {
+		args.Count = defaultPendingCount
+	}
+
+	pending, err := r.client.XPendingExt(ctx, args).Result()
+	if err != nil && err != redis.Nil {
+		return nil, adminError(err, "failed to list pending entries of group %s", group)
+	}
+
+	entries := make([]PendingEntry, 0, len(pending))
+	for _, p := range pending {
+		entries = append(entries, PendingEntry{ID: p.ID, Consumer: p.Consumer, Idle: p.Idle, Deliveries: p.RetryCount})
+	}
+	return entries, nil
+}
+
+// ResetGroup sets the last delivered ID of a consumer group, so that it continues
+// with the entries after id. Use StartBeginning to reprocess the whole stream or "$"
+// to skip to its end. Pending entries are not affected.
+func (r *RedisStream) ResetGroup(ctx context.Context, topic, group, id string) error {
+	if err := r.client.XGroupSetID(ctx, topic, group, id).Err(); err != nil {
+		return adminError(err, "failed to reset group %s", group)
+	}
+	return nil
+}
+
+// ResetGroupToTime makes a consumer group continue with the entries added at or after t.
+func (r *RedisStream) ResetGroupToTime(ctx context.Context, topic, group string, t time.Time) error {
+	return r.ResetGroup(ctx, topic, group, startIDFromTime(t))
+}
+
+// DeleteConsumer removes a consumer from a group and returns the number of its pending
+// entries, which are dropped from the pending entries list without acknowledgement.
+func (r *RedisStream) DeleteConsumer(ctx context.Context, topic, group, consumer string) (int64, error) {
+	n, err := r.client.XGroupDelConsumer(ctx, topic, group, consumer).Result()
+	if err != nil {
+		return 0, adminError(err, "failed to delete consumer %s", consumer)
+	}
+	return n, nil
+}
+
+// DeleteGroup destroys a consumer group with its consumers and pending entries.
+// Subscriptions still using the group fail on their next read.
+func (r *RedisStream) DeleteGroup(ctx context.Context, topic, group string) error {
+	n, err := r.client.XGroupDestroy(ctx, topic, group).Result()
+	if err != nil {
+		return adminError(err, "failed to delete group %s", group)
+	}
+	if n == 0 {
+		return fmt.Errorf("consumer group %s of stream %s: %w", group, topic, ErrGroupNotFound)
+	}
+	return nil
+}
+
+// adminError wraps an error of the admin API, mapping the replies Redis sends for
+// missing streams and groups to ErrStreamNotFound and ErrGroupNotFound.
+func adminError(err error, format string, args ...any) error {
+	msg := err.Error()
+	switch {
+	case isNoSuchKey(err), strings.Contains(msg, "requires the key to exist"):
+		err = ErrStreamNotFound
+	case strings.HasPrefix(msg, "NOGROUP"):
+		err = ErrGroupNotFound
+	}
+	return fmt.Errorf(format+": %w", append(args, err)...)
+}
+
+// compareIDs orders two stream entry IDs.
+func compareIDs(a, b string) int {
+	aMs, aSeq := splitID(a)
+	bMs, bSeq := splitID(b)
+	switch {
+	case aMs != bMs:
+		if aMs < bMs {
+			return -1
+		}
+		return 1
+	case aSeq < bSeq:
+		return -1
+	case aSeq > bSeq:
+		return 1
+	}
+	return 0
+}
+
+func splitID(id string) (ms, seq uint64) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	ms, _ = strconv.ParseUint(msPart, 10, 64)
+	seq, _ = strconv.ParseUint(seqPart, 10, 64)
+	return ms, seq
+}
@@ -0,0 +1,269 @@
+package redisstream
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/ebrickdev/ebrick/transport/http"
+	"github.com/ebrickdev/extensions/v1/transport/http/problem"
+)
+
+// entryIDPattern matches the IDs a group can be reset to: an entry ID, a millisecond
+// timestamp or "$" for the end of the stream.
+var entryIDPattern = regexp.MustCompile(`^(\$|\d+(-\d+)?)$`)
+
+// AdminHandler serves the admin API of a RedisStream over HTTP. It implements the
+// Routable interface of ebrick's transport/http package, so it can be mounted on any
+// router group, e.g. handler.RegisterRoutes(*engine.Group("/admin/redis")):
+//
+//	GET    /streams?pattern=orders.>
+//	GET    /streams/:topic
+//	GET    /streams/:topic/groups
+//	GET    /streams/:topic/groups/:group
+//	DELETE /streams/:topic/groups/:group
+//	POST   /streams/:topic/groups/:group/reset                {"id": "0"} or {"time": "2024-01-02T15:04:05Z"}
+//	GET    /streams/:topic/groups/:group/consumers
+//	DELETE /streams/:topic/groups/:group/consumers/:consumer
+//	GET    /streams/:topic/groups/:group/pending?consumer=&after=&count=&minIdle=30s
+//
+// The endpoints can reset and delete consumer groups, so mount them behind
+// authentication. Topics containing '/' cannot be addressed.
+type AdminHandler struct {
+	rs *RedisStream
+}
+
+// NewAdminHandler creates the HTTP handlers of the admin API.
+func NewAdminHandler(rs *RedisStream) *AdminHandler {
+	return &AdminHandler{rs: rs}
+}
+
+// RegisterRoutes registers the admin endpoints on router.
+func (h *AdminHandler) RegisterRoutes(router http.RouterGroup) {
+	router.GET("/streams", h.listStreams)
+	router.GET("/streams/:topic", h.getStream)
+	router.GET("/streams/:topic/groups", h.listGroups)
+	router.GET("/streams/:topic/groups/:group", h.getGroup)
+	router.DELETE("/streams/:topic/groups/:group", h.deleteGroup)
+	router.POST("/streams/:topic/groups/:group/reset", h.resetGroup)
+	router.GET("/streams/:topic/groups/:group/consumers", h.listConsumers)
+	router.DELETE("/streams/:topic/groups/:group/consumers/:consumer", h.deleteConsumer)
+	router.GET("/streams/:topic/groups/:group/pending", h.listPending)
+}
+
+type streamResponse struct {
+	Name            string `json:"name"`
+	Length          int64  `json:"length"`
+	Groups          int64  `json:"groups"`
+	FirstEntryID    string `json:"firstEntryId,omitempty"`
+	LastEntryID     string `json:"lastEntryId,omitempty"`
+	LastGeneratedID string `json:"lastGeneratedId"`
+}
+
+type groupResponse struct {
+	Name            string `json:"name"`
+	Consumers       int64  `json:"consumers"`
+	Pending         int64  `json:"pending"`
+	LastDeliveredID string `json:"lastDeliveredId"`
+	Lag             int64  `json:"lag"`
+	LagApproximate  bool   `json:"lagApproximate,omitempty"`
+}
+
+type consumerResponse struct {
+	Name       string `json:"name"`
+	Pending    int64  `json:"pending"`
+	IdleMs     int64  `json:"idleMs"`
+	InactiveMs int64  `json:"inactiveMs"`
+}
+
+type pendingResponse struct {
+	ID         string `json:"id"`
+	Consumer   string `json:"consumer"`
+	IdleMs     int64  `json:"idleMs"`
+	Deliveries int64  `json:"deliveries"`
+}
+
+type resetRequest struct {
+	ID   string     `json:"id"`
+	Time *time.Time `json:"time"`
+}
+
+func newStreamResponse(s StreamInfo) streamResponse {
+	return streamResponse{
+		Name: s.Name, Length: s.Length, Groups: s.Groups,
+		FirstEntryID: s.FirstEntryID, LastEntryID: s.LastEntryID, LastGeneratedID: s.LastGeneratedID,
+	}
+}
+
+func newGroupResponse(g GroupInfo) groupResponse {
+	return groupResponse{
+		Name: g.Name, Consumers: g.Consumers, Pending: g.Pending,
+		LastDeliveredID: g.LastDeliveredID, Lag: g.Lag, LagApproximate: g.LagApproximate,
+	}
+}
+
+func (h *AdminHandler) listStreams(c *http.Context) {
+	streams, err := h.rs.Streams(c.Request.Context(), c.Query("pattern"))
+	if err != nil {
+		// Listing only fails on Redis errors or an invalid pattern, which is reported before any command.
+		status := http.StatusInternalServerError
+		if pattern := c.Query("pattern"); pattern != "" && validatePattern(pattern) != nil {
+			status = http.StatusBadRequest
+		}
+		abortWithProblem(c, status, err.Error())
+		return
+	}
+	response := make([]streamResponse, 0, len(streams))
+	for _, s := range streams {
+		response = append(response, newStreamResponse(s))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AdminHandler) getStream(c *http.Context) {
+	stream, err := h.rs.Stream(c.Request.Context(), c.Param("topic"))
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newStreamResponse(*stream))
+}
+
+func (h *AdminHandler) listGroups(c *http.Context) {
+	groups, err := h.rs.Groups(c.Request.Context(), c.Param("topic"))
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	response := make([]groupResponse, 0, len(groups))
+	for _, g := range groups {
+		response = append(response, newGroupResponse(g))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AdminHandler) getGroup(c *http.Context) {
+	group, err := h.rs.Group(c.Request.Context(), c.Param("topic"), c.Param("group"))
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newGroupResponse(*group))
+}
+
+func (h *AdminHandler) deleteGroup(c *http.Context) {
+	if err := h.rs.DeleteGroup(c.Request.Context(), c.Param("topic"), c.Param("group")); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AdminHandler) resetGroup(c *http.Context) {
+	var req resetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, topic, group := c.Request.Context(), c.Param("topic"), c.Param("group")
+	var err error
+	switch {
+	case req.ID != "" && req.Time != nil:
+		abortWithProblem(c, http.StatusBadRequest, "only one of id and time may be set")
+		return
+	case req.Time != nil:
+		err = h.rs.ResetGroupToTime(ctx, topic, group, *req.Time)
+	case entryIDPattern.MatchString(req.ID):
+		err = h.rs.ResetGroup(ctx, topic, group, req.ID)
+	default:
+		abortWithProblem(c, http.StatusBadRequest, "id must be an entry ID, a millisecond timestamp or $, or time must be set")
+		return
+	}
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	info, err := h.rs.Group(ctx, topic, group)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newGroupResponse(*info))
+}
+
+func (h *AdminHandler) listConsumers(c *http.Context) {
+	consumers, err := h.rs.Consumers(c.Request.Context(), c.Param("topic"), c.Param("group"))
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	response := make([]consumerResponse, 0, len(consumers))
+	for _, consumer := range consumers {
+		response = append(response, consumerResponse{
+			Name: consumer.Name, Pending: consumer.Pending,
+			IdleMs: consumer.Idle.Milliseconds(), InactiveMs: consumer.Inactive.Milliseconds(),
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AdminHandler) deleteConsumer(c *http.Context) {
+	pending, err := h.rs.DeleteConsumer(c.Request.Context(), c.Param("topic"), c.Param("group"), c.Param("consumer"))
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, http.H{"pending": pending})
+}
+
+func (h *AdminHandler) listPending(c *http.Context) {
+	query := PendingQuery{Consumer: c.Query("consumer"), After: c.Query("after")}
+	if count := c.Query("count"); count != "" {
+		n, err := strconv.ParseInt(count, 10, 64)
+		if err != nil || n <= 0 {
+			abortWithProblem(c, http.StatusBadRequest, "count must be a positive integer")
+			return
+		}
+		query.Count = n
+	}
+	if minIdle := c.Query("minIdle"); minIdle != "" {
+		d, err := time.ParseDuration(minIdle)
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, "minIdle must be a duration such as 30s")
+			return
+		}
+		query.MinIdle = d
+	}
+	if query.After != "" && !entryIDPattern.MatchString(query.After) {
+		abortWithProblem(c, http.StatusBadRequest, "after must be an entry ID")
+		return
+	}
+
+	entries, err := h.rs.Pending(c.Request.Context(), c.Param("topic"), c.Param("group"), query)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	response := make([]pendingResponse, 0, len(entries))
+	for _, e := range entries {
+		response = append(response, pendingResponse{ID: e.ID, Consumer: e.Consumer, IdleMs: e.Idle.Milliseconds(), Deliveries: e.Deliveries})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// writeAdminError reports missing streams and groups as 404 and other errors as 500.
+func writeAdminError(c *http.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrStreamNotFound) || errors.Is(err, ErrGroupNotFound) {
+		status = http.StatusNotFound
+	}
+	abortWithProblem(c, status, err.Error())
+}
+
+// abortWithProblem responds with an RFC 7807 problem titled after the status.
+func abortWithProblem(c *http.Context, status int, detail string) {
+	problem.AbortWithProblem(c, status, http.StatusText(status), detail)
+}
@@ -0,0 +1,93 @@
+package redisstream
+
+import (
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveAdmin(t *testing.T, rs *RedisStream, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	NewAdminHandler(rs).RegisterRoutes(*engine.Group("/admin"))
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return recorder
+}
+
+func TestAdminHandler(t *testing.T) {
+	rs := newAdminFixture(t)
+
+	res := serveAdmin(t, rs, nethttp.MethodGet, "/admin/streams?pattern=orders.*", "")
+	require.Equal(t, nethttp.StatusOK, res.Code)
+	var streams []streamResponse
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &streams))
+	require.Len(t, streams, 1)
+	assert.Equal(t, "orders.created", streams[0].Name)
+
+	res = serveAdmin(t, rs, nethttp.MethodGet, "/admin/streams/orders.created/groups/billing", "")
+	require.Equal(t, nethttp.StatusOK, res.Code)
+	var group groupResponse
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &group))
+	assert.Equal(t, "2-0", group.LastDeliveredID)
+	assert.Equal(t, int64(2), group.Pending)
+
+	res = serveAdmin(t, rs, nethttp.MethodGet, "/admin/streams/orders.created/groups/billing/pending?consumer=c1&count=1", "")
+	require.Equal(t, nethttp.StatusOK, res.Code)
+	var pending []pendingResponse
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &pending))
+	require.Len(t, pending, 1)
+	assert.Equal(t, "1-0", pending[0].ID)
+
+	res = serveAdmin(t, rs, nethttp.MethodDelete, "/admin/streams/orders.created/groups/billing/consumers/c1", "")
+	require.Equal(t, nethttp.StatusOK, res.Code)
+	assert.JSONEq(t, `{"pending": 2}`, res.Body.String())
+
+	res = serveAdmin(t, rs, nethttp.MethodDelete, "/admin/streams/orders.created/groups/billing", "")
+	assert.Equal(t, nethttp.StatusNoContent, res.Code)
+}
+
+func TestAdminHandlerErrors(t *testing.T) {
+	rs := newAdminFixture(t)
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{nethttp.MethodGet, "/admin/streams?pattern=orders.>.eu", "", nethttp.StatusBadRequest},
+		{nethttp.MethodGet, "/admin/streams/missing", "", nethttp.StatusNotFound},
+		{nethttp.MethodGet, "/admin/streams/orders.created/groups/missing", "", nethttp.StatusNotFound},
+		{nethttp.MethodDelete, "/admin/streams/orders.created/groups/missing", "", nethttp.StatusNotFound},
+		{nethttp.MethodGet, "/admin/streams/orders.created/groups/billing/pending?count=0", "", nethttp.StatusBadRequest},
+		{nethttp.MethodGet, "/admin/streams/orders.created/groups/billing/pending?minIdle=soon", "", nethttp.StatusBadRequest},
+		{nethttp.MethodPost, "/admin/streams/orders.created/groups/billing/reset", `{}`, nethttp.StatusBadRequest},
+		{nethttp.MethodPost, "/admin/streams/orders.created/groups/billing/reset", `{"id": "latest"}`, nethttp.StatusBadRequest},
+		{nethttp.MethodPost, "/admin/streams/orders.created/groups/billing/reset", `{"id": "0", "time": "2024-01-02T15:04:05Z"}`, nethttp.StatusBadRequest},
+	} {
+		res := serveAdmin(t, rs, c.method, c.path, c.body)
+		assert.Equal(t, c.status, res.Code, "%s %s %s", c.method, c.path, c.body)
+		var prob map[string]any
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &prob))
+		assert.Equal(t, float64(c.status), prob["status"])
+		assert.NotEmpty(t, prob["detail"])
+	}
+}
+
+func TestAdminHandlerRedisFailure(t *testing.T) {
+	rs, mr := newTestStream(t, RedisStreamConfig{})
+	mr.SetError("READONLY unavailable")
+
+	// Without a pattern a failed listing is a server error, not a bad request.
+	res := serveAdmin(t, rs, nethttp.MethodGet, "/admin/streams", "")
+	assert.Equal(t, nethttp.StatusInternalServerError, res.Code)
+	assert.Contains(t, res.Body.String(), "READONLY unavailable")
+}
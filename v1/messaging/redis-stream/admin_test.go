@@ -0,0 +1,160 @@
+package redisstream
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAdminFixture creates orders.created with entries 1-0 to 5-0 and a billing group
+// whose consumer c1 has read the first two without acknowledging them.
+func newAdminFixture(t *testing.T) *RedisStream {
+	t.Helper()
+
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, rs.client.XAdd(ctx, &redis.XAddArgs{
+			Stream: "orders.created", ID: fmt.Sprintf("%d-0", i), Values: map[string]interface{}{"n": i},
+		}).Err())
+	}
+	require.NoError(t, rs.client.XAdd(ctx, &redis.XAddArgs{Stream: "audit", Values: map[string]interface{}{"n": 1}}).Err())
+	require.NoError(t, rs.client.XGroupCreate(ctx, "orders.created", "billing", "0").Err())
+	require.NoError(t, rs.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "billing", Consumer: "c1", Streams: []string{"orders.created", ">"}, Count: 2, Block: -1,
+	}).Err())
+	return rs
+}
+
+func TestAdminListing(t *testing.T) {
+	rs := newAdminFixture(t)
+	ctx := context.Background()
+
+	streams, err := rs.Streams(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, streams, 2)
+	assert.Equal(t, "audit", streams[0].Name)
+	// miniredis only reports the length of a stream.
+	assert.Equal(t, "orders.created", streams[1].Name)
+	assert.Equal(t, int64(5), streams[1].Length)
+
+	streams, err = rs.Streams(ctx, "orders.*")
+	require.NoError(t, err)
+	require.Len(t, streams, 1)
+	assert.Equal(t, "orders.created", streams[0].Name)
+	_, err = rs.Streams(ctx, "orders.>.eu")
+	assert.Error(t, err)
+
+	group, err := rs.Group(ctx, "orders.created", "billing")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), group.Consumers)
+	assert.Equal(t, int64(2), group.Pending)
+	assert.Equal(t, "2-0", group.LastDeliveredID)
+
+	consumers, err := rs.Consumers(ctx, "orders.created", "billing")
+	require.NoError(t, err)
+	require.Len(t, consumers, 1)
+	assert.Equal(t, "c1", consumers[0].Name)
+	assert.Equal(t, int64(2), consumers[0].Pending)
+
+	pending, err := rs.Pending(ctx, "orders.created", "billing", PendingQuery{})
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "1-0", pending[0].ID)
+	assert.Equal(t, "c1", pending[0].Consumer)
+	assert.Equal(t, int64(1), pending[0].Deliveries)
+
+	pending, err = rs.Pending(ctx, "orders.created", "billing", PendingQuery{Consumer: "c2"})
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestAdminNotFound(t *testing.T) {
+	rs := newAdminFixture(t)
+	ctx := context.Background()
+
+	_, err := rs.Stream(ctx, "missing")
+	assert.ErrorIs(t, err, ErrStreamNotFound)
+	_, err = rs.Groups(ctx, "missing")
+	assert.ErrorIs(t, err, ErrStreamNotFound)
+	_, err = rs.Lag(ctx, "orders.created", "missing")
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+	_, err = rs.Pending(ctx, "orders.created", "missing", PendingQuery{})
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+	assert.ErrorIs(t, rs.DeleteGroup(ctx, "orders.created", "missing"), ErrGroupNotFound)
+}
+
+// miniredis reports the stream length as lag and does not support XGROUP SETID, so
+// the lag is computed from a group as Redis reports it after a reset.
+func TestGroupLag(t *testing.T) {
+	rs := newAdminFixture(t)
+	ctx := context.Background()
+
+	for _, c := range []struct {
+		lastDelivered string
+		lag           int64
+	}{
+		{StartBeginning, 5},
+		{"2-0", 3},
+		{startIDFromTime(time.UnixMilli(4)), 2},
+		{"5-0", 0},
+	} {
+		lag, exact, err := rs.groupLag(ctx, "orders.created", redis.XInfoGroup{Name: "billing", LastDeliveredID: c.lastDelivered}, "5-0")
+		require.NoError(t, err)
+		assert.Equal(t, c.lag, lag, c.lastDelivered)
+		assert.True(t, exact, c.lastDelivered)
+	}
+
+	lag, exact, err := rs.groupLag(ctx, "orders.created", redis.XInfoGroup{Name: "billing", LastDeliveredID: "5-0", Lag: 7}, "5-0")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), lag, "the lag Redis reports is used")
+	assert.True(t, exact)
+}
+
+func TestGroupLagStopsCounting(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+	ctx := context.Background()
+
+	_, err := rs.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := 1; i <= maxLagCount+1; i++ {
+			pipe.XAdd(ctx, &redis.XAddArgs{Stream: "orders", ID: fmt.Sprintf("%d-0", i), Values: map[string]interface{}{"n": i}})
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	lag, exact, err := rs.groupLag(ctx, "orders", redis.XInfoGroup{Name: "billing", LastDeliveredID: StartBeginning},
+		fmt.Sprintf("%d-0", maxLagCount+1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(maxLagCount), lag)
+	assert.False(t, exact)
+}
+
+func TestAdminDelete(t *testing.T) {
+	rs := newAdminFixture(t)
+	ctx := context.Background()
+
+	n, err := rs.DeleteConsumer(ctx, "orders.created", "billing", "c1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	consumers, err := rs.Consumers(ctx, "orders.created", "billing")
+	require.NoError(t, err)
+	assert.Empty(t, consumers)
+
+	require.NoError(t, rs.DeleteGroup(ctx, "orders.created", "billing"))
+	groups, err := rs.Groups(ctx, "orders.created")
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+}
+
+func TestCompareIDs(t *testing.T) {
+	assert.Equal(t, 0, compareIDs("5-0", "5-0"))
+	assert.Equal(t, -1, compareIDs("5-1", "10-0"))
+	assert.Equal(t, 1, compareIDs("5-2", "5-1"))
+	assert.Equal(t, -1, compareIDs("0-0", "5-0"))
+}
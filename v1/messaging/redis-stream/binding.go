@@ -0,0 +1,157 @@
+package redisstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// Content modes of stream entries.
+const (
+	ContentModeStructured = "structured" // The whole event as JSON in the "event" field (default)
+	ContentModeBinary     = "binary"     // One "ce_<attribute>" field per attribute, data in the "data" field
+)
+
+// Fields of stream entries.
+const (
+	fieldEvent       = "event"
+	fieldData        = "data"
+	fieldPrefix      = "ce_"
+	fieldContentType = fieldPrefix + "datacontenttype"
+)
+
+func validateContentMode(mode string) error {
+	switch mode {
+	case "", ContentModeStructured, ContentModeBinary:
+		return nil
+	}
+	return fmt.Errorf("unsupported content mode %q", mode)
+}
+
+// encodeValues encodes an event as the values of a stream entry in the given content mode.
+func encodeValues(event cloudevents.Event, mode string) (map[string]interface{}, error) {
+	if mode != ContentModeBinary {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{fieldEvent: string(data)}, nil
+	}
+
+	attrs, err := eventAttributes(event)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{}, len(attrs)+2)
+	for name, value := range attrs {
+		values[fieldPrefix+name] = value
+	}
+	if ct := event.DataContentType(); ct != "" {
+		values[fieldContentType] = ct
+	}
+	if data := event.Data(); len(data) > 0 {
+		values[fieldData] = string(data)
+	}
+	return values, nil
+}
+
+// parseMessage decodes the event of a stream entry in either content mode.
+func parseMessage(message redis.XMessage) (cloudevents.Event, error) {
+	if eventData, ok := message.Values[fieldEvent].(string); ok {
+		var event cloudevents.Event
+		if err := json.Unmarshal([]byte(eventData), &event); err != nil {
+			return cloudevents.Event{}, fmt.Errorf("failed to parse event data: %v", err)
+		}
+		return event, nil
+	}
+
+	if _, ok := message.Values[fieldPrefix+"specversion"]; !ok {
+		return cloudevents.Event{}, fmt.Errorf("invalid message format: missing 'event' or '%sspecversion' field", fieldPrefix)
+	}
+
+	attrs := make(map[string]string)
+	var contentType, data string
+	for key, value := range message.Values {
+		s, _ := value.(string)
+		switch {
+		case key == fieldContentType:
+			contentType = s
+		case key == fieldData:
+			data = s
+		case strings.HasPrefix(key, fieldPrefix):
+			attrs[strings.TrimPrefix(key, fieldPrefix)] = s
+		}
+	}
+	return eventFromAttributes(attrs, contentType, []byte(data))
+}
+
+// eventAttributes returns the context attributes and extensions of an event as strings,
+// except datacontenttype which is written next to the data.
+func eventAttributes(event cloudevents.Event) (map[string]string, error) {
+	attrs := map[string]string{
+		"specversion": event.SpecVersion(),
+		"id":          event.ID(),
+		"source":      event.Source(),
+		"type":        event.Type(),
+	}
+	if v := event.Subject(); v != "" {
+		attrs["subject"] = v
+	}
+	if v := event.DataSchema(); v != "" {
+		attrs["dataschema"] = v
+	}
+	if v := event.Time(); !v.IsZero() {
+		attrs["time"] = types.FormatTime(v)
+	}
+	for name, value := range event.Extensions() {
+		s, err := types.Format(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extension %s: %w", name, err)
+		}
+		attrs[name] = s
+	}
+	return attrs, nil
+}
+
+// eventFromAttributes builds an event from string attributes as produced by eventAttributes.
+// Unknown attributes become extensions.
+func eventFromAttributes(attrs map[string]string, contentType string, data []byte) (cloudevents.Event, error) {
+	event := cloudevents.NewEvent(attrs["specversion"])
+	for name, value := range attrs {
+		switch name {
+		case "specversion":
+		case "id":
+			event.SetID(value)
+		case "source":
+			event.SetSource(value)
+		case "type":
+			event.SetType(value)
+		case "subject":
+			event.SetSubject(value)
+		case "dataschema":
+			event.SetDataSchema(value)
+		case "time":
+			t, err := types.ParseTime(value)
+			if err != nil {
+				return cloudevents.Event{}, fmt.Errorf("invalid time attribute: %w", err)
+			}
+			event.SetTime(t)
+		default:
+			event.SetExtension(name, value)
+		}
+	}
+	if contentType != "" {
+		event.SetDataContentType(contentType)
+	}
+	if len(data) > 0 {
+		event.DataEncoded = data
+	}
+	if err := event.Validate(); err != nil {
+		return cloudevents.Event{}, err
+	}
+	return event, nil
+}
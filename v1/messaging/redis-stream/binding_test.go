@@ -0,0 +1,86 @@
+package redisstream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBindingTestEvent() cloudevents.Event {
+	event := newTestEvent("1")
+	event.SetSubject("order-42")
+	event.SetTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	event.SetExtension("partitionkey", "customer-7")
+	return event
+}
+
+func TestBinaryContentMode(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{ContentMode: ContentModeBinary})
+	ctx := context.Background()
+
+	received := make(chan cloudevents.Event, 1)
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		received <- event
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+
+	sent := newBindingTestEvent()
+	require.NoError(t, rs.Publish(ctx, "orders", sent))
+
+	entries, err := rs.client.XRange(ctx, "orders", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	values := entries[0].Values
+	assert.Equal(t, "1", values["ce_id"])
+	assert.Equal(t, "customer-7", values["ce_partitionkey"])
+	assert.Equal(t, cloudevents.ApplicationJSON, values["ce_datacontenttype"])
+	assert.JSONEq(t, `{"id":"1"}`, values["data"].(string))
+	assert.NotContains(t, values, "event")
+
+	select {
+	case event := <-received:
+		assert.Equal(t, sent.Context, event.Context)
+		assert.Equal(t, sent.Data(), event.Data())
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not delivered")
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	sent := newBindingTestEvent()
+
+	structured, err := encodeValues(sent, ContentModeStructured)
+	require.NoError(t, err)
+	binary, err := encodeValues(sent, ContentModeBinary)
+	require.NoError(t, err)
+
+	for name, values := range map[string]map[string]interface{}{"structured": structured, "binary": binary} {
+		t.Run(name, func(t *testing.T) {
+			// Values read back from Redis are strings.
+			read := make(map[string]interface{}, len(values))
+			for k, v := range values {
+				read[k] = v.(string)
+			}
+			event, err := parseMessage(redis.XMessage{ID: "1-0", Values: read})
+			require.NoError(t, err)
+			assert.Equal(t, sent.Context, event.Context)
+			assert.JSONEq(t, string(sent.Data()), string(event.Data()))
+		})
+	}
+
+	_, err = parseMessage(redis.XMessage{ID: "1-0", Values: map[string]interface{}{"ce_specversion": "1.0"}})
+	assert.Error(t, err, "required attributes are missing")
+	_, err = parseMessage(redis.XMessage{ID: "1-0", Values: map[string]interface{}{"other": "value"}})
+	assert.Error(t, err)
+}
+
+func TestNewRedisStreamRejectsInvalidContentMode(t *testing.T) {
+	_, err := NewRedisStream(&RedisStreamConfig{URL: "127.0.0.1:1", ContentMode: "xml"})
+	assert.Error(t, err)
+}
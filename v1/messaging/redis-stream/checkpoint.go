@@ -0,0 +1,114 @@
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Special start IDs of XREAD subscriptions.
+const (
+	StartNew       = "$"   // Only entries added after the subscription started
+	StartBeginning = "0-0" // Every entry still in the stream
+)
+
+// checkpoint persists the position of an XREAD subscription under a Redis key.
+// Entries are handled concurrently, so the position only advances past an entry once
+// it and every entry read before it have been handled.
+type checkpoint struct {
+	client redis.UniversalClient
+	key    string
+
+	mu      sync.Mutex
+	pending []string        // Dispatched entry IDs in stream order
+	done    map[string]bool // Handled entries that are not yet at the front of pending
+}
+
+func newCheckpoint(client redis.UniversalClient, key string) *checkpoint {
+	return &checkpoint{client: client, key: key, done: make(map[string]bool)}
+}
+
+// load returns the persisted position, or "" if none was stored yet.
+func (c *checkpoint) load(ctx context.Context) (string, error) {
+	id, err := c.client.Get(ctx, c.key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load checkpoint %s: %w", c.key, err)
+	}
+	return id, nil
+}
+
+// track records that an entry is about to be handed to the handler.
+func (c *checkpoint) track(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = append(c.pending, id)
+}
+
+// complete marks an entry as handled and persists the new position if it advanced.
+func (c *checkpoint) complete(id string) {
+	c.mu.Lock()
+	c.done[id] = true
+	var last string
+	for len(c.pending) > 0 && c.done[c.pending[0]] {
+		last = c.pending[0]
+		delete(c.done, last)
+		c.pending = c.pending[1:]
+	}
+	if last == "" {
+		c.mu.Unlock()
+		return
+	}
+	// Saving under the lock keeps the writes in stream order.
+	defer c.mu.Unlock()
+
+	if err := c.client.Set(context.Background(), c.key, last, 0).Err(); err != nil {
+		log.Printf("XREAD subscription: failed to save checkpoint %s: %v", c.key, err)
+	}
+}
+
+// startIDFromTime returns the start ID that delivers the entries added at or after t.
+// Start IDs are exclusive, so it points at the last possible ID of the previous millisecond.
+func startIDFromTime(t time.Time) string {
+	ms := t.UnixMilli()
+	if ms <= 0 {
+		return StartBeginning
+	}
+	return fmt.Sprintf("%d-%d", ms-1, uint64(1<<64-1))
+}
+
+// resolveStartID determines where an XREAD subscription starts reading a stream.
+// A stored checkpoint wins over the configured start ID. StartNew is resolved to the
+// current last entry, so entries added after SubscribeHandler returns are not missed.
+func (r *RedisStream) resolveStartID(ctx context.Context, sub *Subscription, stream string) (string, error) {
+	if sub.checkpoint != nil {
+		id, err := sub.checkpoint.load(ctx)
+		if err != nil {
+			return "", err
+		}
+		if id != "" {
+			return id, nil
+		}
+	}
+
+	start := sub.opts.StartID
+	if start != "" && start != StartNew {
+		return start, nil
+	}
+
+	last, err := r.client.XRevRangeN(ctx, stream, "+", "-", 1).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to read last entry of stream %s: %w", stream, err)
+	}
+	if len(last) == 0 {
+		return StartBeginning, nil
+	}
+	return last[0].ID, nil
+}
@@ -0,0 +1,125 @@
+package redisstream
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// subscribeIDs subscribes with XREAD and forwards the IDs of handled events.
+func subscribeIDs(t *testing.T, rs *RedisStream, opts ...SubscriptionOption) (*Subscription, chan string) {
+	t.Helper()
+
+	ch := make(chan string, 100)
+	sub, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		ch <- event.ID()
+		return nil
+	}, opts...)
+	require.NoError(t, err)
+	return sub, ch
+}
+
+func receiveIDs(t *testing.T, ch chan string, n int) []string {
+	t.Helper()
+
+	var ids []string
+	for len(ids) < n {
+		select {
+		case id := <-ch:
+			ids = append(ids, id)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out after receiving %d of %d events", len(ids), n)
+		}
+	}
+	return ids
+}
+
+func publishIDs(t *testing.T, rs *RedisStream, ids ...string) {
+	t.Helper()
+
+	for _, id := range ids {
+		require.NoError(t, rs.Publish(context.Background(), "orders", newTestEvent(id)))
+	}
+}
+
+func TestXReadDoesNotSkipMessages(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+
+	ch := make(chan string, 100)
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		// A slow handler keeps the reader busy while further entries are added.
+		time.Sleep(10 * time.Millisecond)
+		ch <- event.ID()
+		return nil
+	}, WithMaxInFlight(1))
+	require.NoError(t, err)
+
+	var expected []string
+	for i := 0; i < 20; i++ {
+		expected = append(expected, fmt.Sprint(i))
+	}
+	publishIDs(t, rs, expected...)
+	assert.Equal(t, expected, receiveIDs(t, ch, len(expected)))
+}
+
+func TestXReadStartOptions(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+	ctx := context.Background()
+
+	publishIDs(t, rs, "1")
+	ids, err := rs.client.XRange(ctx, "orders", "-", "+").Result()
+	require.NoError(t, err)
+	first := ids[0].ID
+	time.Sleep(5 * time.Millisecond)
+	middle := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	publishIDs(t, rs, "2")
+
+	tests := []struct {
+		name     string
+		opt      SubscriptionOption
+		expected []string
+	}{
+		{"beginning", WithStartID(StartBeginning), []string{"1", "2"}},
+		{"after id", WithStartID(first), []string{"2"}},
+		{"start time", WithStartTime(middle), []string{"2"}},
+		{"new", WithStartID(StartNew), []string{"3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, ch := subscribeIDs(t, rs, tt.opt)
+			defer func() { _ = sub.Unsubscribe() }()
+
+			if tt.name == "new" {
+				publishIDs(t, rs, "3")
+			}
+			assert.ElementsMatch(t, tt.expected, receiveIDs(t, ch, len(tt.expected)))
+		})
+	}
+}
+
+func TestXReadCheckpointResumes(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+	ctx := context.Background()
+
+	sub, ch := subscribeIDs(t, rs, WithCheckpointKey("orders:checkpoint"), WithMaxInFlight(4))
+	publishIDs(t, rs, "1", "2")
+	assert.ElementsMatch(t, []string{"1", "2"}, receiveIDs(t, ch, 2))
+	require.NoError(t, sub.Drain(ctx))
+
+	// Entries added while the subscriber is down are delivered once it restarts.
+	publishIDs(t, rs, "3", "4")
+	_, ch = subscribeIDs(t, rs, WithCheckpointKey("orders:checkpoint"))
+	assert.ElementsMatch(t, []string{"3", "4"}, receiveIDs(t, ch, 2))
+
+	select {
+	case id := <-ch:
+		t.Fatalf("unexpected event %s", id)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,102 @@
+package redisstream
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newClient creates the Redis client for the configured topology:
+// a cluster client when ClusterAddrs is set, a Sentinel-backed failover client
+// when MasterName is set, and a single-node client otherwise.
+func newClient(cfg *RedisStreamConfig) (redis.UniversalClient, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dialTimeout := time.Duration(cfg.ConnectionTimeout) * time.Second
+
+	switch {
+	case len(cfg.ClusterAddrs) > 0:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:       cfg.ClusterAddrs,
+			Username:    cfg.Username,
+			Password:    cfg.Password,
+			MaxRetries:  cfg.MaxRetries,
+			PoolSize:    cfg.MaxConnections,
+			DialTimeout: dialTimeout,
+			TLSConfig:   tlsConfig,
+		}), nil
+	case cfg.MasterName != "":
+		if len(cfg.SentinelAddrs) == 0 {
+			return nil, errors.New("sentinelAddrs is required when masterName is set")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.SentinelAddrs,
+			SentinelUsername: cfg.SentinelUsername,
+			SentinelPassword: cfg.SentinelPassword,
+			Username:         cfg.Username,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+			MaxRetries:       cfg.MaxRetries,
+			PoolSize:         cfg.MaxConnections,
+			DialTimeout:      dialTimeout,
+			TLSConfig:        tlsConfig,
+		}), nil
+	default:
+		if cfg.URL == "" {
+			return nil, errors.New("url is required")
+		}
+		return redis.NewClient(&redis.Options{
+			Addr:        cfg.URL,
+			Username:    cfg.Username,
+			Password:    cfg.Password,
+			DB:          cfg.DB,
+			MaxRetries:  cfg.MaxRetries,
+			PoolSize:    cfg.MaxConnections,
+			DialTimeout: dialTimeout,
+			TLSConfig:   tlsConfig,
+		}), nil
+	}
+}
+
+// newTLSConfig builds the TLS configuration, or returns nil when TLS is disabled.
+// Certificate files imply TLS.
+func newTLSConfig(cfg *RedisStreamConfig) (*tls.Config, error) {
+	if !cfg.TLS && cfg.CAFile == "" && cfg.CertFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,137 @@
+package redisstream
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCert is a certificate with its PEM encoded files.
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// newTestCert creates a certificate signed by parent, or a self-signed CA when parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	c := &testCert{cert: cert, key: key, certFile: filepath.Join(dir, name+".crt"), keyFile: filepath.Join(dir, name+".key")}
+	require.NoError(t, os.WriteFile(c.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(c.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return c
+}
+
+func TestNewRedisStreamTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	server := newTestCert(t, "server", ca)
+	client := newTestCert(t, "client", ca)
+
+	serverCert, err := tls.LoadX509KeyPair(server.certFile, server.keyFile)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	mr, err := miniredis.RunTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+
+	cfg := RedisStreamConfig{
+		URL:               mr.Addr(),
+		CAFile:            ca.certFile,
+		ServerName:        "localhost",
+		ConnectionTimeout: 1,
+		MaxRetries:        -1,
+	}
+	_, err = NewRedisStream(&cfg)
+	assert.Error(t, err, "the server requires a client certificate")
+
+	cfg.CertFile, cfg.KeyFile = client.certFile, client.keyFile
+	rs, err := NewRedisStream(&cfg)
+	require.NoError(t, err)
+	require.NoError(t, rs.Close())
+}
+
+func TestNewRedisStreamReturnsErrors(t *testing.T) {
+	tests := map[string]RedisStreamConfig{
+		"unreachable":        {URL: "127.0.0.1:1", ConnectionTimeout: 1, MaxRetries: -1},
+		"missing url":        {},
+		"missing sentinels":  {MasterName: "mymaster"},
+		"missing CA file":    {URL: "127.0.0.1:1", CAFile: "does-not-exist.pem"},
+		"missing key file":   {URL: "127.0.0.1:1", CertFile: "client.crt"},
+		"TLS to plain redis": {TLS: true, ConnectionTimeout: 1, MaxRetries: -1},
+	}
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			if name == "TLS to plain redis" {
+				cfg.URL = miniredis.RunT(t).Addr()
+			}
+			_, err := NewRedisStream(&cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewClientTopology(t *testing.T) {
+	client, err := newClient(&RedisStreamConfig{ClusterAddrs: []string{"127.0.0.1:7000", "127.0.0.1:7001"}})
+	require.NoError(t, err)
+	assert.IsType(t, &redis.ClusterClient{}, client)
+
+	client, err = newClient(&RedisStreamConfig{
+		MasterName:     "mymaster",
+		SentinelAddrs:  []string{"127.0.0.1:26379"},
+		MaxConnections: 3,
+	})
+	require.NoError(t, err)
+	require.IsType(t, &redis.Client{}, client)
+	assert.Contains(t, client.(*redis.Client).String(), "FailoverClient")
+	assert.Equal(t, 3, client.(*redis.Client).Options().PoolSize)
+}
@@ -1,11 +1,48 @@
 package redisstream
 
+import "time"
+
 type RedisStreamConfig struct {
-	URL               string `mapstructure:"url"`
-	Username          string `mapstructure:"username"`
-	Password          string `mapstructure:"password"`
-	TLS               bool   `mapstructure:"tls"`               // Secure connection flag
-	MaxRetries        int    `mapstructure:"maxRetries"`        // For retry logic
-	MaxConnections    int    `mapstructure:"maxConnections"`    // Connection pooling config
-	ConnectionTimeout int    `mapstructure:"connectionTimeout"` // Timeout for connections
+	URL               string        `mapstructure:"url"` // host:port of a single Redis node
+	Username          string        `mapstructure:"username"`
+	Password          string        `mapstructure:"password"`
+	DB                int           `mapstructure:"db"`                // Database of single-node and Sentinel setups
+	TLS               bool          `mapstructure:"tls"`               // Secure connection flag
+	MaxRetries        int           `mapstructure:"maxRetries"`        // For retry logic, -1 disables retries
+	MaxConnections    int           `mapstructure:"maxConnections"`    // Connection pool size, 0 uses 10 per CPU
+	ConnectionTimeout int           `mapstructure:"connectionTimeout"` // Dial timeout in seconds, 0 uses 5 seconds
+	ClaimIdleTime     time.Duration `mapstructure:"claimIdleTime"`     // Pending entries idle this long are redelivered
+	ClaimInterval     time.Duration `mapstructure:"claimInterval"`     // How often consumers look for stale pending entries
+	MaxDeliveries     int           `mapstructure:"maxDeliveries"`     // Delivery attempts before dead-lettering, 0 means unlimited
+	ContentMode       string        `mapstructure:"contentMode"`       // "structured" (default) or "binary"; both are read
+
+	TopicRefreshInterval time.Duration `mapstructure:"topicRefreshInterval"` // How often wildcard subscriptions look for new streams, 0 uses 5 seconds
+
+	SchedulerEnabled  bool          `mapstructure:"schedulerEnabled"`  // Enables PublishAt and PublishAfter and the delivery of scheduled events
+	SchedulerKey      string        `mapstructure:"schedulerKey"`      // Sorted set of events scheduled with PublishAt, defaults to "{ebrick:scheduled}"
+	SchedulerInterval time.Duration `mapstructure:"schedulerInterval"` // How often due scheduled events are moved to their streams, 0 uses 1 second, -1 disables delivery by this bus
+
+	CAFile             string `mapstructure:"caFile"`             // PEM CA bundle used to verify the server
+	CertFile           string `mapstructure:"certFile"`           // PEM client certificate
+	KeyFile            string `mapstructure:"keyFile"`            // PEM client key
+	ServerName         string `mapstructure:"serverName"`         // Overrides the server name used for verification
+	InsecureSkipVerify bool   `mapstructure:"insecureSkipVerify"` // Disables server certificate verification
+
+	MasterName       string   `mapstructure:"masterName"`       // Sentinel master; enables Sentinel mode
+	SentinelAddrs    []string `mapstructure:"sentinelAddrs"`    // host:port of the Sentinels
+	SentinelUsername string   `mapstructure:"sentinelUsername"` // Credentials of the Sentinels, if different
+	SentinelPassword string   `mapstructure:"sentinelPassword"`
+	ClusterAddrs     []string `mapstructure:"clusterAddrs"` // host:port of cluster nodes; enables Cluster mode
+
+	Retention           RetentionPolicy            `mapstructure:"retention"`           // Default retention of all streams
+	TopicRetention      map[string]RetentionPolicy `mapstructure:"topicRetention"`      // Per-topic overrides of Retention
+	JanitorInterval     time.Duration              `mapstructure:"janitorInterval"`     // How often streams are trimmed and idle consumers removed, 0 disables the janitor
+	ConsumerIdleTimeout time.Duration              `mapstructure:"consumerIdleTimeout"` // Consumers without pending entries idle this long are deleted, 0 keeps them
+}
+
+// RetentionPolicy bounds the size of a stream. Trimming is approximate, so a stream
+// may briefly hold a few more entries than configured.
+type RetentionPolicy struct {
+	MaxLen int64         `mapstructure:"maxLen"` // Maximum number of entries, 0 means unlimited
+	MaxAge time.Duration `mapstructure:"maxAge"` // Entries older than this are trimmed, 0 means unlimited
 }
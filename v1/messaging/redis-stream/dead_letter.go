@@ -0,0 +1,223 @@
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// DeadLetterSuffix is appended to a topic to name its dead-letter stream.
+const DeadLetterSuffix = ".dlq"
+
+// Fields added to dead-lettered entries next to the original entry values.
+const (
+	dlqFieldError      = "dlq_error"
+	dlqFieldAttempts   = "dlq_attempts"
+	dlqFieldStream     = "dlq_stream"
+	dlqFieldGroup      = "dlq_group"
+	dlqFieldConsumer   = "dlq_consumer"
+	dlqFieldOriginalID = "dlq_original_id"
+	dlqFieldFailedAt   = "dlq_failed_at"
+)
+
+// replayFieldGroup marks a replayed entry with the consumer group it is meant for.
+// Other groups and XREAD subscriptions skip it.
+const replayFieldGroup = "replay_group"
+
+var errMaxDeliveriesExceeded = errors.New("maximum delivery attempts exceeded")
+
+// ErrDeadLetterNotFound is returned when a dead-lettered entry does not exist.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is an entry of a dead-letter stream together with its failure metadata.
+type DeadLetter struct {
+	ID          string                 // ID of the entry in the dead-letter stream
+	OriginalID  string                 // ID of the entry in the original stream
+	Stream      string                 // Original stream (topic)
+	Group       string                 // Consumer group that failed to process the entry
+	Consumer    string                 // Consumer that handled the last attempt
+	Error       string                 // Error of the last attempt
+	Attempts    int64                  // Number of delivery attempts
+	PublishedAt time.Time              // When the original entry was added
+	FailedAt    time.Time              // When the entry was dead-lettered
+	Values      map[string]interface{} // Original entry values
+}
+
+// Event decodes the CloudEvent carried by the dead-lettered entry.
+func (d *DeadLetter) Event() (cloudevents.Event, error) {
+	return parseMessage(redis.XMessage{ID: d.OriginalID, Values: d.Values})
+}
+
+// DeadLetterStream returns the name of the dead-letter stream for a topic.
+func DeadLetterStream(topic string) string {
+	return topic + DeadLetterSuffix
+}
+
+// deadLetter moves a message to the dead-letter stream and acknowledges the original.
+// Both commands run in a single MULTI/EXEC transaction.
+func (r *RedisStream) deadLetter(ctx context.Context, sub *Subscription, stream string, message redis.XMessage, cause error, attempts int64) {
+	values := make(map[string]interface{}, len(message.Values)+7)
+	for k, v := range message.Values {
+		values[k] = v
+	}
+	values[dlqFieldError] = cause.Error()
+	values[dlqFieldAttempts] = attempts
+	values[dlqFieldStream] = stream
+	values[dlqFieldGroup] = sub.group
+	values[dlqFieldConsumer] = sub.consumer
+	values[dlqFieldOriginalID] = message.ID
+	values[dlqFieldFailedAt] = time.Now().UTC().Format(time.RFC3339Nano)
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: DeadLetterStream(stream), Values: values})
+		pipe.XAck(ctx, stream, sub.group, message.ID)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Consumer group subscription: failed to dead-letter message %v: %v", message.ID, err)
+		return
+	}
+	log.Printf("Consumer group subscription: message %v moved to %s after %d attempts: %v", message.ID, DeadLetterStream(stream), attempts, cause)
+}
+
+// DeadLetters lists up to count dead-lettered entries of a topic, oldest first.
+// A count of 0 returns all entries.
+func (r *RedisStream) DeadLetters(ctx context.Context, topic string, count int64) ([]DeadLetter, error) {
+	var (
+		messages []redis.XMessage
+		err      error
+	)
+	if count > 0 {
+		messages, err = r.client.XRangeN(ctx, DeadLetterStream(topic), "-", "+", count).Result()
+	} else {
+		messages, err = r.client.XRange(ctx, DeadLetterStream(topic), "-", "+").Result()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	letters := make([]DeadLetter, 0, len(messages))
+	for _, m := range messages {
+		letters = append(letters, newDeadLetter(m))
+	}
+	return letters, nil
+}
+
+// DeadLetter returns a single dead-lettered entry of a topic.
+func (r *RedisStream) DeadLetter(ctx context.Context, topic, id string) (*DeadLetter, error) {
+	messages, err := r.client.XRange(ctx, DeadLetterStream(topic), id, id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter %s: %w", id, err)
+	}
+	if len(messages) == 0 {
+		return nil, ErrDeadLetterNotFound
+	}
+	letter := newDeadLetter(messages[0])
+	return &letter, nil
+}
+
+// ReplayDeadLetter publishes a dead-lettered entry to its topic again and removes it
+// from the dead-letter stream. It returns the ID of the republished entry. The entry is
+// handled only by the consumer group that dead-lettered it; the other groups of the
+// topic and XREAD subscriptions acknowledge or skip it. The topic's retention policy
+// applies as for Publish.
+func (r *RedisStream) ReplayDeadLetter(ctx context.Context, topic, id string) (string, error) {
+	letter, err := r.DeadLetter(ctx, topic, id)
+	if err != nil {
+		return "", err
+	}
+
+	values := make(map[string]interface{}, len(letter.Values)+1)
+	for k, v := range letter.Values {
+		values[k] = v
+	}
+	if letter.Group != "" {
+		values[replayFieldGroup] = letter.Group
+	}
+	args := &redis.XAddArgs{Stream: topic, Values: values}
+	r.applyRetention(args)
+
+	var add *redis.StringCmd
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		add = pipe.XAdd(ctx, args)
+		pipe.XDel(ctx, DeadLetterStream(topic), id)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to replay dead letter %s: %w", id, err)
+	}
+	return add.Val(), nil
+}
+
+// replayedFor reports whether a message is a replay meant for another consumer group.
+func replayedFor(message redis.XMessage, group string) bool {
+	target, ok := message.Values[replayFieldGroup].(string)
+	return ok && target != group
+}
+
+// PurgeDeadLetters deletes the given dead-lettered entries of a topic, or the whole
+// dead-letter stream when no IDs are given. It returns the number of deleted entries.
+func (r *RedisStream) PurgeDeadLetters(ctx context.Context, topic string, ids ...string) (int64, error) {
+	stream := DeadLetterStream(topic)
+	if len(ids) > 0 {
+		n, err := r.client.XDel(ctx, stream, ids...).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge dead letters: %w", err)
+		}
+		return n, nil
+	}
+
+	n, err := r.client.XLen(ctx, stream).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dead letters: %w", err)
+	}
+	if err := r.client.Del(ctx, stream).Err(); err != nil {
+		return 0, fmt.Errorf("failed to purge dead letters: %w", err)
+	}
+	return n, nil
+}
+
+// newDeadLetter splits a dead-letter stream entry into the original values and the failure metadata.
+func newDeadLetter(m redis.XMessage) DeadLetter {
+	letter := DeadLetter{ID: m.ID, Values: make(map[string]interface{}, len(m.Values))}
+	for k, v := range m.Values {
+		s, _ := v.(string)
+		switch k {
+		case dlqFieldError:
+			letter.Error = s
+		case dlqFieldAttempts:
+			letter.Attempts, _ = strconv.ParseInt(s, 10, 64)
+		case dlqFieldStream:
+			letter.Stream = s
+		case dlqFieldGroup:
+			letter.Group = s
+		case dlqFieldConsumer:
+			letter.Consumer = s
+		case dlqFieldOriginalID:
+			letter.OriginalID = s
+		case dlqFieldFailedAt:
+			letter.FailedAt, _ = time.Parse(time.RFC3339Nano, s)
+		default:
+			letter.Values[k] = v
+		}
+	}
+	letter.PublishedAt = idTime(letter.OriginalID)
+	return letter
+}
+
+// idTime extracts the millisecond timestamp of a stream entry ID.
+func idTime(id string) time.Time {
+	ms, _, _ := strings.Cut(id, "-")
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(n).UTC()
+}
@@ -0,0 +1,164 @@
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoisonMessageIsDeadLettered(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{
+		ClaimIdleTime: 50 * time.Millisecond,
+		ClaimInterval: 20 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	var attempts, healthy atomic.Int32
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		if healthy.Load() == 1 {
+			return nil
+		}
+		attempts.Add(1)
+		return errors.New("cannot process order")
+	}, WithConsumerGroup("billing"), WithConsumerName("worker-1"), WithMaxDeliveries(2))
+	require.NoError(t, err)
+	require.NoError(t, rs.Publish(ctx, "orders", newTestEvent("1")))
+
+	var letters []DeadLetter
+	require.Eventually(t, func() bool {
+		var err error
+		letters, err = rs.DeadLetters(ctx, "orders", 0)
+		return err == nil && len(letters) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	letter := letters[0]
+	assert.Equal(t, int32(2), attempts.Load())
+	assert.Equal(t, int64(2), letter.Attempts)
+	assert.Equal(t, "cannot process order", letter.Error)
+	assert.Equal(t, "orders", letter.Stream)
+	assert.Equal(t, "billing", letter.Group)
+	assert.Equal(t, "worker-1", letter.Consumer)
+	assert.NotEmpty(t, letter.OriginalID)
+	assert.False(t, letter.PublishedAt.IsZero())
+	assert.False(t, letter.FailedAt.IsZero())
+	assert.Equal(t, int64(0), pendingCount(t, rs, "orders", "billing"))
+
+	event, err := letter.Event()
+	require.NoError(t, err)
+	assert.Equal(t, "1", event.ID())
+
+	inspected, err := rs.DeadLetter(ctx, "orders", letter.ID)
+	require.NoError(t, err)
+	assert.Equal(t, letter, *inspected)
+
+	// Replaying hands the event to the (now healthy) consumers again.
+	healthy.Store(1)
+	_, err = rs.ReplayDeadLetter(ctx, "orders", letter.ID)
+	require.NoError(t, err)
+	letters, err = rs.DeadLetters(ctx, "orders", 0)
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+	assert.Eventually(t, func() bool {
+		n, err := rs.client.XLen(ctx, "orders").Result()
+		return err == nil && n == 2 && pendingCount(t, rs, "orders", "billing") == 0
+	}, 2*time.Second, 10*time.Millisecond)
+
+	_, err = rs.DeadLetter(ctx, "orders", letter.ID)
+	assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+}
+
+func TestReplayReachesOnlyTheFailingGroup(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{Retention: RetentionPolicy{MaxLen: 1}})
+	ctx := context.Background()
+
+	var billing, shipping, listener atomic.Int32
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		if billing.Add(1) == 1 {
+			return errors.New("cannot process order")
+		}
+		return nil
+	}, WithConsumerGroup("billing"), WithMaxDeliveries(1))
+	require.NoError(t, err)
+	_, err = rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		shipping.Add(1)
+		return nil
+	}, WithConsumerGroup("shipping"))
+	require.NoError(t, err)
+	require.NoError(t, rs.Subscribe("orders", func(ctx context.Context, event cloudevents.Event) {
+		listener.Add(1)
+	}))
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, rs.Publish(ctx, "orders", newTestEvent("1")))
+
+	var letters []DeadLetter
+	require.Eventually(t, func() bool {
+		letters, err = rs.DeadLetters(ctx, "orders", 0)
+		return err == nil && len(letters) == 1 && shipping.Load() == 1 && listener.Load() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	_, err = rs.ReplayDeadLetter(ctx, "orders", letters[0].ID)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return billing.Load() == 2 && pendingCount(t, rs, "orders", "billing") == 0 &&
+			pendingCount(t, rs, "orders", "shipping") == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), shipping.Load())
+	assert.Equal(t, int32(1), listener.Load())
+
+	// The replay is trimmed like any published entry.
+	n, err := rs.client.XLen(ctx, "orders").Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestMalformedMessageIsDeadLettered(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{MaxDeliveries: 3})
+	ctx := context.Background()
+
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	require.NoError(t, rs.client.XAdd(ctx, rawEntry("orders", "garbage")).Err())
+
+	require.Eventually(t, func() bool {
+		letters, err := rs.DeadLetters(ctx, "orders", 10)
+		return err == nil && len(letters) == 1 && letters[0].Attempts == 1
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestPurgeDeadLetters(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+	ctx := context.Background()
+
+	for _, v := range []string{"a", "b", "c"} {
+		require.NoError(t, rs.client.XAdd(ctx, rawEntry(DeadLetterStream("orders"), v)).Err())
+	}
+	letters, err := rs.DeadLetters(ctx, "orders", 2)
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+
+	n, err := rs.PurgeDeadLetters(ctx, "orders", letters[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = rs.PurgeDeadLetters(ctx, "orders")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	letters, err = rs.DeadLetters(ctx, "orders", 0)
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+// rawEntry builds a stream entry with an arbitrary event payload.
+func rawEntry(stream, event string) *redis.XAddArgs {
+	return &redis.XAddArgs{Stream: stream, Values: map[string]interface{}{"event": event}}
+}
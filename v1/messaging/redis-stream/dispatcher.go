@@ -0,0 +1,104 @@
+package redisstream
+
+import (
+	"context"
+	"hash/fnv"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// KeyFunc extracts the ordering key of an event. Events with the same key are
+// handled one at a time in the order they were read.
+type KeyFunc func(event cloudevents.Event) string
+
+// SubjectKey orders events by their CloudEvents subject.
+func SubjectKey(event cloudevents.Event) string {
+	return event.Subject()
+}
+
+// ExtensionKey orders events by the value of a CloudEvents extension, e.g. "partitionkey".
+func ExtensionKey(name string) KeyFunc {
+	return func(event cloudevents.Event) string {
+		v, ok := event.Extensions()[name]
+		if !ok {
+			return ""
+		}
+		if s, ok := v.(string); ok {
+			return s
+		}
+		return ""
+	}
+}
+
+// dispatcher runs handler invocations of a subscription.
+//   - Without limits every invocation runs in its own goroutine.
+//   - With maxInFlight, at most that many invocations run at once.
+//   - With an ordering key, invocations are sharded over maxInFlight workers by key.
+//
+// run blocks while the dispatcher is saturated, which pauses reading from the stream.
+type dispatcher struct {
+	ctx    context.Context
+	key    KeyFunc
+	sem    chan struct{}
+	queues []chan func()
+}
+
+func newDispatcher(ctx context.Context, maxInFlight int, key KeyFunc) *dispatcher {
+	d := &dispatcher{ctx: ctx, key: key}
+	switch {
+	case key != nil:
+		if maxInFlight <= 0 {
+			maxInFlight = 1
+		}
+		d.queues = make([]chan func(), maxInFlight)
+		for i := range d.queues {
+			d.queues[i] = make(chan func())
+			go d.work(d.queues[i])
+		}
+	case maxInFlight > 0:
+		d.sem = make(chan struct{}, maxInFlight)
+	}
+	return d
+}
+
+// run schedules fn for the event. It reports false if the dispatcher stopped
+// before fn could be scheduled.
+func (d *dispatcher) run(event cloudevents.Event, fn func()) bool {
+	switch {
+	case d.queues != nil:
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(d.key(event)))
+		select {
+		case d.queues[h.Sum32()%uint32(len(d.queues))] <- fn:
+			return true
+		case <-d.ctx.Done():
+			return false
+		}
+	case d.sem != nil:
+		select {
+		case d.sem <- struct{}{}:
+		case <-d.ctx.Done():
+			return false
+		}
+		go func() {
+			defer func() { <-d.sem }()
+			fn()
+		}()
+		return true
+	default:
+		go fn()
+		return true
+	}
+}
+
+// work runs queued invocations of one ordering shard until the dispatcher stops.
+func (d *dispatcher) work(queue chan func()) {
+	for {
+		select {
+		case fn := <-queue:
+			fn()
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
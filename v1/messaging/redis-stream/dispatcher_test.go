@@ -0,0 +1,90 @@
+package redisstream
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxInFlightBoundsConcurrency(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+	ctx := context.Background()
+
+	var running, peak, handled atomic.Int32
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+		handled.Add(1)
+		return nil
+	}, WithConsumerGroup("billing"), WithMaxInFlight(2))
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, rs.Publish(ctx, "orders", newTestEvent(fmt.Sprint(i))))
+	}
+
+	assert.Eventually(t, func() bool {
+		return handled.Load() == 10
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.LessOrEqual(t, peak.Load(), int32(2))
+}
+
+func TestOrderingKeyPreservesOrder(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	seen := map[string][]int{}
+	var handled atomic.Int32
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		seq, _ := strconv.Atoi(event.ID())
+
+		mu.Lock()
+		key := event.Extensions()["partitionkey"].(string)
+		seen[key] = append(seen[key], seq)
+		mu.Unlock()
+		handled.Add(1)
+		return nil
+	}, WithConsumerGroup("billing"), WithMaxInFlight(4), WithOrderingKey(ExtensionKey("partitionkey")))
+	require.NoError(t, err)
+
+	for i := 0; i < 30; i++ {
+		event := newTestEvent(fmt.Sprint(i))
+		event.SetExtension("partitionkey", fmt.Sprintf("customer-%d", i%3))
+		require.NoError(t, rs.Publish(ctx, "orders", event))
+	}
+
+	require.Eventually(t, func() bool {
+		return handled.Load() == 30
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for key, seqs := range seen {
+		assert.IsIncreasing(t, seqs, key)
+	}
+}
+
+func TestSubjectKey(t *testing.T) {
+	event := newTestEvent("1")
+	event.SetSubject("order-42")
+	assert.Equal(t, "order-42", SubjectKey(event))
+	assert.Equal(t, "", ExtensionKey("partitionkey")(event))
+}
@@ -0,0 +1,143 @@
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingLogger records the messages logged at error level.
+type recordingLogger struct {
+	logger.Logger
+	mu     sync.Mutex
+	errors []string
+}
+
+func (l *recordingLogger) Debug(msg string, fields ...logger.Field) {}
+
+func (l *recordingLogger) Error(msg string, fields ...logger.Field) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, msg)
+}
+
+func (l *recordingLogger) logged() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.errors...)
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, name)
+	}
+	publishInterceptor := func(name string) PublishInterceptor {
+		return func(ctx context.Context, topic string, event cloudevents.Event, next PublishFunc) error {
+			record(name)
+			return next(ctx, topic, event)
+		}
+	}
+	consumeInterceptor := func(name string) ConsumeInterceptor {
+		return func(ctx context.Context, event cloudevents.Event, info ConsumeInfo, next Handler) error {
+			record(name + ":" + info.Topic + ":" + info.Group)
+			return next(ctx, event)
+		}
+	}
+
+	rs, _ := newTestStream(t, RedisStreamConfig{},
+		WithPublishInterceptors(publishInterceptor("p1"), publishInterceptor("p2")),
+		WithConsumeInterceptors(consumeInterceptor("c1"), consumeInterceptor("c2")))
+
+	done := make(chan struct{})
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		record("handler")
+		close(done)
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	require.NoError(t, rs.Publish(context.Background(), "orders", newTestEvent("1")))
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"p1", "p2", "c1:orders:billing", "c2:orders:billing", "handler"}, calls)
+}
+
+func TestPublishInterceptorCanReject(t *testing.T) {
+	rejected := errors.New("rejected")
+	rs, _ := newTestStream(t, RedisStreamConfig{}, WithPublishInterceptors(
+		func(ctx context.Context, topic string, event cloudevents.Event, next PublishFunc) error {
+			return rejected
+		}))
+
+	assert.ErrorIs(t, rs.Publish(context.Background(), "orders", newTestEvent("1")), rejected)
+	n, err := rs.client.Exists(context.Background(), "orders").Result()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestRecoverAndRetryInterceptors(t *testing.T) {
+	log := &recordingLogger{}
+	rs, _ := newTestStream(t, RedisStreamConfig{}, WithConsumeInterceptors(
+		LogConsume(log),
+		RetryConsume(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		Recover(),
+	))
+
+	var attempts atomic.Int32
+	done := make(chan struct{})
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		if attempts.Add(1) < 3 {
+			panic("boom")
+		}
+		close(done)
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	require.NoError(t, rs.Publish(context.Background(), "orders", newTestEvent("1")))
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler was not retried")
+	}
+	assert.Equal(t, int32(3), attempts.Load())
+	assert.Empty(t, log.logged())
+	assert.Eventually(t, func() bool {
+		return pendingCount(t, rs, "orders", "billing") == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestLogConsumeLogsFailures(t *testing.T) {
+	log := &recordingLogger{}
+	handler := chainConsume([]ConsumeInterceptor{LogConsume(log)}, ConsumeInfo{Topic: "orders"},
+		func(ctx context.Context, event cloudevents.Event) error { return errors.New("boom") })
+
+	assert.Error(t, handler(context.Background(), newTestEvent("1")))
+	assert.Equal(t, []string{"Redis Stream: handler failed"}, log.logged())
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 400*time.Millisecond, policy.backoff(3))
+	assert.Equal(t, time.Second, policy.backoff(5))
+}
@@ -0,0 +1,160 @@
+package redisstream
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// retention returns the retention policy of a topic.
+func (r *RedisStream) retention(topic string) RetentionPolicy {
+	if policy, ok := r.cfg.TopicRetention[topic]; ok {
+		return policy
+	}
+	return r.cfg.Retention
+}
+
+// applyRetention adds the trimming strategy of the topic's retention to an XADD.
+// XADD accepts a single strategy, so MaxLen wins and MaxAge is left to the janitor
+// when both are set.
+func (r *RedisStream) applyRetention(args *redis.XAddArgs) {
+	policy := r.retention(args.Stream)
+	switch {
+	case policy.MaxLen > 0:
+		args.MaxLen = policy.MaxLen
+		args.Approx = true
+	case policy.MaxAge > 0:
+		args.MinID = minID(policy.MaxAge)
+		args.Approx = true
+	}
+}
+
+// minID returns the smallest entry ID younger than maxAge.
+func minID(maxAge time.Duration) string {
+	return fmt.Sprintf("%d-0", time.Now().Add(-maxAge).UnixMilli())
+}
+
+// Trim applies the retention policy of a topic to its stream and returns the number
+// of removed entries.
+func (r *RedisStream) Trim(ctx context.Context, topic string) (int64, error) {
+	policy := r.retention(topic)
+
+	var trimmed int64
+	if policy.MaxLen > 0 {
+		n, err := r.client.XTrimMaxLenApprox(ctx, topic, policy.MaxLen, 0).Result()
+		if err != nil {
+			return trimmed, fmt.Errorf("failed to trim stream %s: %w", topic, err)
+		}
+		trimmed += n
+	}
+	if policy.MaxAge > 0 {
+		n, err := r.client.XTrimMinIDApprox(ctx, topic, minID(policy.MaxAge), 0).Result()
+		if err != nil {
+			return trimmed, fmt.Errorf("failed to trim stream %s: %w", topic, err)
+		}
+		trimmed += n
+	}
+	return trimmed, nil
+}
+
+// DeleteIdleConsumers removes the consumers of every group on a topic that have been
+// idle for at least idle and hold no pending entries. It returns the number of
+// deleted consumers. Redis counts a blocked read as idle time, so idle should be well
+// above the one second read block; a live consumer deleted anyway is recreated by its next read.
+func (r *RedisStream) DeleteIdleConsumers(ctx context.Context, topic string, idle time.Duration) (int64, error) {
+	groups, err := r.client.XInfoGroups(ctx, topic).Result()
+	if err != nil {
+		if isNoSuchKey(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to list consumer groups of %s: %w", topic, err)
+	}
+
+	var deleted int64
+	for _, group := range groups {
+		consumers, err := r.client.XInfoConsumers(ctx, topic, group.Name).Result()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to list consumers of group %s: %w", group.Name, err)
+		}
+		for _, consumer := range consumers {
+			// Consumers with pending entries are left alone so the entries can be reclaimed.
+			if consumer.Pending > 0 || consumer.Idle < idle {
+				continue
+			}
+			if err := r.client.XGroupDelConsumer(ctx, topic, group.Name, consumer.Name).Err(); err != nil {
+				return deleted, fmt.Errorf("failed to delete consumer %s: %w", consumer.Name, err)
+			}
+			log.Printf("Redis Stream: deleted idle consumer %s of group %s on stream %s", consumer.Name, group.Name, topic)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func isNoSuchKey(err error) bool {
+	return strings.Contains(err.Error(), "no such key")
+}
+
+// startJanitor runs the janitor in the background until Shutdown.
+func (r *RedisStream) startJanitor() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.stopJanitor = cancel
+	r.janitor.Add(1)
+	go func() {
+		defer r.janitor.Done()
+		r.runJanitor(ctx)
+	}()
+}
+
+// runJanitor periodically trims the known streams and deletes idle consumers.
+// Streams are known once they are published to, subscribed to or listed in TopicRetention;
+// dead-letter streams are only trimmed when listed in TopicRetention.
+func (r *RedisStream) runJanitor(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.JanitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, topic := range r.knownTopics() {
+			if n, err := r.Trim(ctx, topic); err != nil {
+				log.Printf("Redis Stream: janitor: %v", err)
+			} else if n > 0 {
+				log.Printf("Redis Stream: janitor trimmed %d entries from stream %s", n, topic)
+			}
+
+			if r.cfg.ConsumerIdleTimeout > 0 {
+				if _, err := r.DeleteIdleConsumers(ctx, topic, r.cfg.ConsumerIdleTimeout); err != nil {
+					log.Printf("Redis Stream: janitor: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// knownTopics returns the topics the janitor takes care of.
+func (r *RedisStream) knownTopics() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	topics := make([]string, 0, len(r.topics))
+	for topic := range r.topics {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// addTopic registers a topic with the janitor.
+func (r *RedisStream) addTopic(topic string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.topics[topic] = struct{}{}
+}
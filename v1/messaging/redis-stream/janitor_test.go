@@ -0,0 +1,80 @@
+package redisstream
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishAppliesRetention(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{
+		Retention:      RetentionPolicy{MaxLen: 5},
+		TopicRetention: map[string]RetentionPolicy{"audit": {}},
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, rs.Publish(ctx, "orders", newTestEvent(fmt.Sprint(i))))
+		require.NoError(t, rs.Publish(ctx, "audit", newTestEvent(fmt.Sprint(i))))
+	}
+
+	// miniredis trims exactly; a real server may keep a few more entries.
+	assert.Equal(t, int64(5), rs.client.XLen(ctx, "orders").Val())
+	assert.Equal(t, int64(10), rs.client.XLen(ctx, "audit").Val())
+}
+
+func TestTrimMaxAge(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{
+		TopicRetention: map[string]RetentionPolicy{"orders": {MaxAge: 50 * time.Millisecond}},
+	})
+	ctx := context.Background()
+
+	require.NoError(t, rs.Publish(ctx, "orders", newTestEvent("1")))
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, rs.Publish(ctx, "orders", newTestEvent("2")))
+
+	// Publishing already trimmed the old entry.
+	assert.Equal(t, int64(1), rs.client.XLen(ctx, "orders").Val())
+
+	time.Sleep(100 * time.Millisecond)
+	n, err := rs.Trim(ctx, "orders")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, int64(0), rs.client.XLen(ctx, "orders").Val())
+}
+
+func TestJanitorDeletesIdleConsumers(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{
+		Retention:           RetentionPolicy{MaxLen: 2},
+		JanitorInterval:     20 * time.Millisecond,
+		ConsumerIdleTimeout: 50 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	require.NoError(t, rs.client.XGroupCreateMkStream(ctx, "orders", "billing", "$").Err())
+	// A consumer that read once and went away.
+	err := rs.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "billing", Consumer: "idle", Streams: []string{"orders", ">"}, Block: -1,
+	}).Err()
+	require.ErrorIs(t, err, redis.Nil)
+	for i := 0; i < 5; i++ {
+		// Bypass Publish so that only the janitor trims the stream.
+		require.NoError(t, rs.client.XAdd(ctx, &redis.XAddArgs{Stream: "orders", Values: map[string]interface{}{"n": i}}).Err())
+	}
+	// An entry pending on a consumer keeps it from being deleted.
+	require.NoError(t, rs.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "billing", Consumer: "busy", Streams: []string{"orders", ">"}, Count: 1, Block: -1,
+	}).Err())
+	rs.addTopic("orders")
+
+	assert.Eventually(t, func() bool {
+		consumers, err := rs.client.XInfoConsumers(ctx, "orders", "billing").Result()
+		require.NoError(t, err)
+		return len(consumers) == 1 && consumers[0].Name == "busy" && rs.client.XLen(ctx, "orders").Val() == 2
+	}, 2*time.Second, 20*time.Millisecond)
+}
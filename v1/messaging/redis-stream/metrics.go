@@ -0,0 +1,208 @@
+package redisstream
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	metricsNamespace = "ebrick"
+	metricsSubsystem = "redis_stream"
+
+	// metricsScrapeTimeout bounds the Redis queries of a scrape.
+	metricsScrapeTimeout = 5 * time.Second
+)
+
+// WithMetrics registers Prometheus metrics of the bus with registerer: published,
+// delivered, acknowledged, failed and undecodable events per topic and group, publish
+// and handler latency, and the lag and pending entries of the consumer groups. The
+// group gauges are read with XINFO GROUPS and XPENDING on every scrape, for the streams
+// the bus has published to or subscribed to, within metricsScrapeTimeout; streams
+// that cannot be read are left out of the scrape and counted in scrape_errors_total.
+// To register several buses with the same
+// registry, wrap it for each of them with prometheus.WrapRegistererWith and a
+// distinguishing label.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(opts *Options) {
+		opts.Registerer = registerer
+	}
+}
+
+// metrics holds the collectors of a bus. A nil *metrics records nothing.
+type metrics struct {
+	published       *prometheus.CounterVec
+	publishFailed   *prometheus.CounterVec
+	publishDuration *prometheus.HistogramVec
+	delivered       *prometheus.CounterVec
+	acked           *prometheus.CounterVec
+	failed          *prometheus.CounterVec
+	decodeErrors    *prometheus.CounterVec
+	handlerDuration *prometheus.HistogramVec
+	scrapeErrors    *prometheus.CounterVec
+
+	groupLag        *prometheus.Desc
+	groupPending    *prometheus.Desc
+	consumerPending *prometheus.Desc
+	rs              *RedisStream
+}
+
+func newMetrics(rs *RedisStream) *metrics {
+	counter := func(name, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace, Subsystem: metricsSubsystem, Name: name, Help: help,
+		}, labels)
+	}
+	histogram := func(name, help string, labels ...string) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace, Subsystem: metricsSubsystem, Name: name, Help: help,
+			Buckets: prometheus.DefBuckets,
+		}, labels)
+	}
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, metricsSubsystem, name), help, labels, nil)
+	}
+
+	return &metrics{
+		published:       counter("events_published_total", "Events published or scheduled.", "topic"),
+		publishFailed:   counter("publish_failures_total", "Events that failed to publish.", "topic"),
+		publishDuration: histogram("publish_duration_seconds", "Time to publish an event.", "topic"),
+		delivered:       counter("events_delivered_total", "Events delivered to handlers.", "topic", "group"),
+		acked:           counter("events_acked_total", "Consumer group entries acknowledged.", "topic", "group"),
+		failed:          counter("events_failed_total", "Events whose handler failed or panicked.", "topic", "group"),
+		decodeErrors:    counter("decode_errors_total", "Stream entries that could not be decoded into events.", "topic", "group"),
+		handlerDuration: histogram("handler_duration_seconds", "Time spent in handlers.", "topic", "group"),
+		scrapeErrors:    counter("scrape_errors_total", "Streams whose consumer groups could not be read for the group gauges.", "topic"),
+
+		groupLag:        desc("group_lag", "Entries of the stream not yet delivered to the consumer group, 0 when Redis cannot tell (before 7.0 or after deletions).", "topic", "group"),
+		groupPending:    desc("group_pending", "Entries delivered to the consumer group but not acknowledged.", "topic", "group"),
+		consumerPending: desc("consumer_pending", "Entries delivered to the consumer but not acknowledged.", "topic", "group", "consumer"),
+		rs:              rs,
+	}
+}
+
+// register adds the collectors to registerer, undoing the registrations on failure.
+func (m *metrics) register(registerer prometheus.Registerer) error {
+	var registered []prometheus.Collector
+	for _, c := range []prometheus.Collector{
+		m.published, m.publishFailed, m.publishDuration, m.delivered, m.acked,
+		m.failed, m.decodeErrors, m.handlerDuration, m.scrapeErrors, groupCollector{m},
+	} {
+		if err := registerer.Register(c); err != nil {
+			for _, r := range registered {
+				registerer.Unregister(r)
+			}
+			return err
+		}
+		registered = append(registered, c)
+	}
+	return nil
+}
+
+// publish is the publish interceptor recording published events and their latency.
+func (m *metrics) publish(ctx context.Context, topic string, event cloudevents.Event, next PublishFunc) error {
+	start := time.Now()
+	err := next(ctx, topic, event)
+	m.publishDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.publishFailed.WithLabelValues(topic).Inc()
+		return err
+	}
+	m.published.WithLabelValues(topic).Inc()
+	return nil
+}
+
+// consume is the consume interceptor recording deliveries, failures and handler latency.
+func (m *metrics) consume(ctx context.Context, event cloudevents.Event, info ConsumeInfo, next Handler) (err error) {
+	m.delivered.WithLabelValues(info.Topic, info.Group).Inc()
+	start := time.Now()
+	failed := true // Stays set when the handler panics
+	defer func() {
+		m.handlerDuration.WithLabelValues(info.Topic, info.Group).Observe(time.Since(start).Seconds())
+		if failed {
+			m.failed.WithLabelValues(info.Topic, info.Group).Inc()
+		}
+	}()
+
+	err = next(ctx, event)
+	failed = err != nil
+	return err
+}
+
+func (m *metrics) ackedEvent(topic, group string) {
+	if m != nil {
+		m.acked.WithLabelValues(topic, group).Inc()
+	}
+}
+
+func (m *metrics) decodeError(topic, group string) {
+	if m != nil {
+		m.decodeErrors.WithLabelValues(topic, group).Inc()
+	}
+}
+
+// groupCollector reports the consumer groups of the known streams when scraped.
+type groupCollector struct {
+	m *metrics
+}
+
+func (c groupCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.m.groupLag
+	ch <- c.m.groupPending
+	ch <- c.m.consumerPending
+}
+
+func (c groupCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsScrapeTimeout)
+	defer cancel()
+
+	// A stream that cannot be read loses its series for this scrape; the others are
+	// still reported.
+	for _, topic := range c.m.rs.knownTopics() {
+		if ctx.Err() != nil {
+			log.Printf("Redis Stream: metrics scrape timed out after %s, skipping the remaining streams", metricsScrapeTimeout)
+			c.m.scrapeErrors.WithLabelValues(topic).Inc()
+			return
+		}
+		if err := c.collectStream(ctx, ch, topic); err != nil {
+			log.Printf("Redis Stream: metrics: %v", err)
+			c.m.scrapeErrors.WithLabelValues(topic).Inc()
+		}
+	}
+}
+
+func (c groupCollector) collectStream(ctx context.Context, ch chan<- prometheus.Metric, topic string) error {
+	client := c.m.rs.client
+	groups, err := client.XInfoGroups(ctx, topic).Result()
+	if err != nil {
+		if isNoSuchKey(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to list consumer groups of %s: %w", topic, err)
+	}
+
+	for _, group := range groups {
+		ch <- prometheus.MustNewConstMetric(c.m.groupLag, prometheus.GaugeValue, float64(group.Lag), topic, group.Name)
+		ch <- prometheus.MustNewConstMetric(c.m.groupPending, prometheus.GaugeValue, float64(group.Pending), topic, group.Name)
+		if group.Pending == 0 {
+			continue
+		}
+
+		pending, err := client.XPending(ctx, topic, group.Name).Result()
+		if err != nil && err != redis.Nil {
+			return fmt.Errorf("failed to read pending entries of group %s: %w", group.Name, err)
+		}
+		if pending == nil {
+			continue
+		}
+		for consumer, n := range pending.Consumers {
+			ch <- prometheus.MustNewConstMetric(c.m.consumerPending, prometheus.GaugeValue, float64(n), topic, group.Name, consumer)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	rs, _ := newTestStream(t, RedisStreamConfig{}, WithMetrics(registry))
+	ctx := context.Background()
+
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		if event.ID() == "bad" {
+			return errors.New("boom")
+		}
+		return nil
+	}, WithConsumerGroup("workers"), WithConsumerName("c1"))
+	require.NoError(t, err)
+
+	require.NoError(t, rs.Publish(ctx, "orders", newTestEvent("1")))
+	require.NoError(t, rs.Publish(ctx, "orders", newTestEvent("bad")))
+	require.NoError(t, rs.client.XAdd(ctx, &redis.XAddArgs{Stream: "orders", Values: map[string]interface{}{"foo": "bar"}}).Err())
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(rs.metrics.published.WithLabelValues("orders")))
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(rs.metrics.failed.WithLabelValues("orders", "workers")) == 1 &&
+			testutil.ToFloat64(rs.metrics.decodeErrors.WithLabelValues("orders", "workers")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2.0, testutil.ToFloat64(rs.metrics.delivered.WithLabelValues("orders", "workers")))
+	// The undecodable entry is acknowledged as well, so it is not redelivered.
+	assert.Equal(t, 2.0, testutil.ToFloat64(rs.metrics.acked.WithLabelValues("orders", "workers")))
+
+	expected := `
+# HELP ebrick_redis_stream_consumer_pending Entries delivered to the consumer but not acknowledged.
+# TYPE ebrick_redis_stream_consumer_pending gauge
+ebrick_redis_stream_consumer_pending{consumer="c1",group="workers",topic="orders"} 1
+# HELP ebrick_redis_stream_group_pending Entries delivered to the consumer group but not acknowledged.
+# TYPE ebrick_redis_stream_group_pending gauge
+ebrick_redis_stream_group_pending{group="workers",topic="orders"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"ebrick_redis_stream_consumer_pending", "ebrick_redis_stream_group_pending"))
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "ebrick_redis_stream_group_lag"))
+}
+
+func TestMetricsSkipUnreadableStreams(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	rs, _ := newTestStream(t, RedisStreamConfig{}, WithMetrics(registry))
+	ctx := context.Background()
+
+	require.NoError(t, rs.client.XGroupCreateMkStream(ctx, "orders", "workers", "0").Err())
+	rs.addTopic("orders")
+	// XINFO GROUPS fails with WRONGTYPE on a key that is not a stream.
+	require.NoError(t, rs.client.Set(ctx, "payments", "not a stream", 0).Err())
+	rs.addTopic("payments")
+
+	expected := `
+# HELP ebrick_redis_stream_group_pending Entries delivered to the consumer group but not acknowledged.
+# TYPE ebrick_redis_stream_group_pending gauge
+ebrick_redis_stream_group_pending{group="workers",topic="orders"} 0
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "ebrick_redis_stream_group_pending"))
+	assert.Equal(t, 1.0, testutil.ToFloat64(rs.metrics.scrapeErrors.WithLabelValues("payments")))
+}
+
+func TestMetricsRegistration(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	_, mr := newTestStream(t, RedisStreamConfig{}, WithMetrics(registry))
+
+	_, err := NewRedisStream(&RedisStreamConfig{URL: mr.Addr()}, WithMetrics(registry))
+	assert.Error(t, err)
+}
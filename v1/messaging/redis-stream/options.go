@@ -0,0 +1,107 @@
+package redisstream
+
+import (
+	"context"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+)
+
+// Handler processes an event and reports whether it succeeded.
+// In consumer groups a message is acknowledged only when the handler returns nil;
+// otherwise it stays in the pending entries list and is redelivered later.
+type Handler func(ctx context.Context, event cloudevents.Event) error
+
+// SubscriptionOptions extends messaging.SubscriptionOptions with Redis Stream specific settings.
+type SubscriptionOptions struct {
+	messaging.SubscriptionOptions
+	ClaimIdleTime time.Duration // Idle time after which pending entries are reclaimed
+	MaxDeliveries int           // Delivery attempts before a message is dead-lettered, 0 means unlimited
+	MaxInFlight   int           // Maximum concurrent handler invocations, 0 means unlimited
+	OrderingKey   KeyFunc       // Handle events with the same key serially, in stream order
+	StartID       string        // Entries after this ID are delivered; StartNew by default
+	CheckpointKey string        // Redis key persisting the position of an XREAD subscription
+}
+
+// SubscriptionOption defines a function to set Redis Stream subscription options.
+type SubscriptionOption func(opts *SubscriptionOptions)
+
+// WithConsumerGroup specifies the consumer group.
+func WithConsumerGroup(group string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.Group = group
+	}
+}
+
+// WithConsumerName specifies the consumer name.
+func WithConsumerName(name string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.Name = name
+	}
+}
+
+// WithClaimIdleTime overrides RedisStreamConfig.ClaimIdleTime for a single subscription.
+func WithClaimIdleTime(idle time.Duration) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.ClaimIdleTime = idle
+	}
+}
+
+// WithMaxDeliveries overrides RedisStreamConfig.MaxDeliveries for a single subscription.
+func WithMaxDeliveries(n int) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.MaxDeliveries = n
+	}
+}
+
+// WithMaxInFlight bounds the number of concurrent handler invocations. When all
+// handlers are busy the subscription stops reading from the stream until one finishes.
+func WithMaxInFlight(n int) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.MaxInFlight = n
+	}
+}
+
+// WithOrderingKey preserves the order of events sharing a key, e.g. SubjectKey or
+// ExtensionKey("partitionkey"). Events with different keys are handled in parallel by
+// up to MaxInFlight workers; without MaxInFlight all events are handled serially.
+func WithOrderingKey(key KeyFunc) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.OrderingKey = key
+	}
+}
+
+// WithStartID delivers the entries after id, e.g. StartBeginning to replay the whole
+// stream. For consumer groups it only applies when the group is created.
+func WithStartID(id string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.StartID = id
+	}
+}
+
+// WithStartTime delivers the entries added at or after t.
+// For consumer groups it only applies when the group is created.
+func WithStartTime(t time.Time) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.StartID = startIDFromTime(t)
+	}
+}
+
+// WithCheckpointKey persists the position of an XREAD subscription under key, so a
+// restarted subscriber resumes after the last handled entry instead of at StartID.
+// Consumer groups track their position in Redis already and ignore this option.
+func WithCheckpointKey(key string) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		opts.CheckpointKey = key
+	}
+}
+
+// WithMessagingOptions applies the generic messaging subscription options.
+func WithMessagingOptions(options ...messaging.SubscriptionOption) SubscriptionOption {
+	return func(opts *SubscriptionOptions) {
+		for _, o := range options {
+			o(&opts.SubscriptionOptions)
+		}
+	}
+}
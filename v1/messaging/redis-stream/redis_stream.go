@@ -2,9 +2,10 @@ package redisstream
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
@@ -13,7 +14,19 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-const errorSleepDuration = time.Second
+const (
+	errorSleepDuration   = time.Second
+	defaultClaimIdleTime = 30 * time.Second
+	defaultClaimInterval = 10 * time.Second
+	claimBatchSize       = 100
+
+	// readBlockTimeout bounds blocking reads so that stopped subscriptions notice quickly.
+	readBlockTimeout = time.Second
+	// defaultShutdownTimeout is how long Close waits for in-flight handlers.
+	defaultShutdownTimeout = 10 * time.Second
+)
+
+var errClosed = errors.New("eventbus is closed")
 
 // Init loads configuration and sets up the default event bus.
 func Init() *RedisStream {
@@ -22,56 +35,221 @@ func Init() *RedisStream {
 	if err != nil {
 		log.Fatalf("Redis Stream: unable to load Redis config: %v", err)
 	}
-	return NewRedisStream(&cfg)
+	rs, err := NewRedisStream(&cfg)
+	if err != nil {
+		log.Fatalf("Redis Stream: %v", err)
+	}
+	return rs
 }
 
 // RedisStream wraps a Redis client.
 type RedisStream struct {
-	client *redis.Client
+	client redis.UniversalClient
+	cfg    RedisStreamConfig
+
+	mu     sync.Mutex // Protects closed, subs and topics
+	closed bool
+	subs   map[*Subscription]struct{}
+	topics map[string]struct{} // Streams maintained by the janitor
+
+	stopJanitor context.CancelFunc
+	janitor     sync.WaitGroup
+
+	stopScheduler context.CancelFunc
+	scheduler     sync.WaitGroup
+
+	publish      PublishFunc          // Publishing wrapped in the publish interceptors
+	interceptors []ConsumeInterceptor // Wrapped around every handler invocation
+	metrics      *metrics             // Set only when metrics are enabled
 }
 
 // NewRedisStream creates a new RedisStream and verifies the connection.
-func NewRedisStream(cfg *RedisStreamConfig) *RedisStream {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.URL,
-		Username: cfg.Username,
-		Password: cfg.Password,
-		DB:       0,
-	})
+// In Cluster mode, commands that span a topic and its dead-letter stream are not atomic
+// unless both streams hash to the same slot, e.g. by using a hash tag such as "{orders}".
+// With SchedulerEnabled, the bus also delivers events scheduled with PublishAt, unless
+// SchedulerInterval is negative.
+// Options add publish and consume interceptors and configure tracing and metrics.
+// Producer and consumer spans are always emitted, around all interceptors.
+func NewRedisStream(cfg *RedisStreamConfig, options ...Option) (*RedisStream, error) {
+	if err := validateContentMode(cfg.ContentMode); err != nil {
+		return nil, err
+	}
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis configuration: %w", err)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Redis Stream: unable to connect to Redis: %v", err)
+		_ = client.Close()
+		return nil, fmt.Errorf("unable to connect to Redis: %w", err)
 	}
 	log.Println("Redis Stream: Redis Stream initialized successfully")
 
-	return &RedisStream{
+	var opts Options
+	for _, o := range options {
+		o(&opts)
+	}
+
+	rs := &RedisStream{
 		client: client,
+		cfg:    *cfg,
+		subs:   make(map[*Subscription]struct{}),
+		topics: make(map[string]struct{}),
+	}
+	tracing := newTracing(opts)
+	publishInterceptors := []PublishInterceptor{tracing.publish}
+	consumeInterceptors := []ConsumeInterceptor{tracing.consume}
+	if opts.Registerer != nil {
+		rs.metrics = newMetrics(rs)
+		if err := rs.metrics.register(opts.Registerer); err != nil {
+			_ = client.Close()
+			return nil, fmt.Errorf("failed to register metrics: %w", err)
+		}
+		publishInterceptors = append(publishInterceptors, rs.metrics.publish)
+		consumeInterceptors = append(consumeInterceptors, rs.metrics.consume)
+	}
+	rs.interceptors = append(consumeInterceptors, opts.ConsumeInterceptors...)
+	rs.publish = chainPublish(append(publishInterceptors, opts.PublishInterceptors...), rs.send)
+	if rs.cfg.ClaimIdleTime <= 0 {
+		rs.cfg.ClaimIdleTime = defaultClaimIdleTime
+	}
+	if rs.cfg.ClaimInterval <= 0 {
+		rs.cfg.ClaimInterval = defaultClaimInterval
+	}
+	if rs.cfg.TopicRefreshInterval <= 0 {
+		rs.cfg.TopicRefreshInterval = defaultTopicRefreshInterval
+	}
+	if rs.cfg.SchedulerKey == "" {
+		rs.cfg.SchedulerKey = defaultSchedulerKey
+	}
+	if rs.cfg.SchedulerInterval == 0 {
+		rs.cfg.SchedulerInterval = defaultSchedulerInterval
 	}
+	for topic := range rs.cfg.TopicRetention {
+		rs.topics[topic] = struct{}{}
+	}
+	if rs.cfg.JanitorInterval > 0 {
+		rs.startJanitor()
+	}
+	if rs.cfg.SchedulerEnabled && rs.cfg.SchedulerInterval > 0 {
+		rs.startScheduler()
+	}
+	return rs, nil
 }
 
-// Close terminates the Redis connection.
+// Close stops all subscriptions, waits up to 10 seconds for in-flight handlers
+// and terminates the Redis connection. Use Shutdown to control the deadline.
 func (r *RedisStream) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	return r.Shutdown(ctx)
+}
+
+// Shutdown stops reading on all subscriptions, waits for in-flight handlers until
+// ctx expires and terminates the Redis connection. Handlers that did not finish
+// are reported in a *DrainError; their consumer group messages stay pending.
+func (r *RedisStream) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return errors.New("eventbus is already closed")
+	}
+	r.closed = true
+	subs := make([]*Subscription, 0, len(r.subs))
+	for sub := range r.subs {
+		subs = append(subs, sub)
+	}
+	r.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.cancel()
+	}
+	if r.stopJanitor != nil {
+		r.stopJanitor()
+		r.janitor.Wait()
+	}
+	if r.stopScheduler != nil {
+		r.stopScheduler()
+		r.scheduler.Wait()
+	}
+
+	var drainErr DrainError
+	for _, sub := range subs {
+		var err *DrainError
+		if errors.As(sub.Drain(ctx), &err) {
+			drainErr.Unfinished = append(drainErr.Unfinished, err.Unfinished...)
+		}
+	}
+
 	log.Println("Closing Redis connection")
-	return r.client.Close()
+	if err := r.client.Close(); err != nil {
+		return err
+	}
+	if len(drainErr.Unfinished) > 0 {
+		return &drainErr
+	}
+	return nil
+}
+
+func (r *RedisStream) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+// addSubscription registers a subscription unless the bus is closed.
+func (r *RedisStream) addSubscription(sub *Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return errClosed
+	}
+	r.subs[sub] = struct{}{}
+	return nil
 }
 
-// Publish serializes the event as JSON and adds it to the specified stream.
+func (r *RedisStream) removeSubscription(sub *Subscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.subs, sub)
+}
+
+// Publish serializes the event in the configured content mode and adds it to the specified
+// stream. The stream is trimmed approximately according to the topic's retention policy.
+// The publish interceptors run around the serialization and the XADD.
 func (r *RedisStream) Publish(ctx context.Context, topic string, event cloudevents.Event) error {
-	eventData, err := json.Marshal(event)
+	if r.isClosed() {
+		return errClosed
+	}
+	if isWildcard(topic) {
+		return fmt.Errorf("cannot publish to wildcard topic %s", topic)
+	}
+	return r.publish(ctx, topic, event)
+}
+
+// send serializes an event and adds it to a stream.
+func (r *RedisStream) send(ctx context.Context, topic string, event cloudevents.Event) error {
+	r.addTopic(topic)
+
+	values, err := encodeValues(event, r.cfg.ContentMode)
 	if err != nil {
 		log.Printf("Redis Stream: failed to serialize event: %v", err)
 		return err
 	}
+	if at, ok := ctx.Value(deliverAtKey{}).(time.Time); ok {
+		if event.ID() == "" {
+			return errors.New("scheduled events must have an ID")
+		}
+		return r.schedule(ctx, topic, values, event.ID(), at)
+	}
 
-	_, err = r.client.XAdd(ctx, &redis.XAddArgs{
-		Stream: topic,
-		Values: map[string]interface{}{
-			"event": string(eventData),
-		},
-	}).Result()
+	args := &redis.XAddArgs{Stream: topic, Values: values}
+	r.applyRetention(args)
+
+	_, err = r.client.XAdd(ctx, args).Result()
 	if err != nil {
 		log.Printf("Redis Stream: failed to publish event: %v", err)
 		return err
@@ -82,45 +260,132 @@ func (r *RedisStream) Publish(ctx context.Context, topic string, event cloudeven
 // Subscribe subscribes to a Redis stream.
 //   - If a consumer group is provided (SubscriptionOptions.Group is non-empty),
 //     it uses consumer group semantics (with XREADGroup and offset ">").
-//   - Otherwise, it uses a plain XREAD subscription with the fixed offset "$" for new messages.
+//   - Otherwise, it uses a plain XREAD subscription that delivers the messages added
+//     after the subscription started.
 //
-// The method accepts a context for cancellation.
+// Consumer group messages are acknowledged once the handler returns; use SubscribeHandler
+// to report failures and have messages redelivered, or to obtain a Subscription handle.
+// Subscriptions created here are stopped by Close or Shutdown.
 func (r *RedisStream) Subscribe(topic string, handler func(ctx context.Context, event cloudevents.Event), opts ...messaging.SubscriptionOption) error {
-	ctx := context.Background()
-	options := &messaging.SubscriptionOptions{}
+	_, err := r.SubscribeHandler(topic, func(ctx context.Context, event cloudevents.Event) error {
+		handler(ctx, event)
+		return nil
+	}, WithMessagingOptions(opts...))
+	return err
+}
+
+// SubscribeHandler subscribes to a Redis stream with a handler that reports failures.
+// In consumer groups a message is acknowledged only after the handler succeeds.
+// Failed messages stay pending and are reclaimed with XCLAIM by a live consumer
+// of the group once they have been idle for the configured claim idle time.
+// When MaxDeliveries is set, messages that fail that many times are moved to the
+// "<topic>.dlq" dead-letter stream.
+//
+// Topics are made of tokens separated by '.'. A topic containing the wildcard tokens
+// '*' (exactly one token) or '>' (one or more trailing tokens) reads every matching
+// stream. Matching streams are discovered with SCAN when subscribing and every
+// TopicRefreshInterval afterwards; streams discovered later are read from their first
+// entry. Dead-letter streams are only matched by patterns ending in ".dlq". In Cluster
+// mode the matching streams must hash to the same slot, e.g. "{orders}.*", because
+// they are read with a single command.
+func (r *RedisStream) SubscribeHandler(topic string, handler Handler, opts ...SubscriptionOption) (*Subscription, error) {
+	if r.isClosed() {
+		return nil, errClosed
+	}
+	wildcard := isWildcard(topic)
+	if wildcard {
+		if err := validatePattern(topic); err != nil {
+			return nil, err
+		}
+	}
+
+	options := &SubscriptionOptions{
+		ClaimIdleTime: r.cfg.ClaimIdleTime,
+		MaxDeliveries: r.cfg.MaxDeliveries,
+	}
 	for _, opt := range opts {
 		opt(options)
 	}
 
-	if options.Group != "" {
+	if wildcard && options.CheckpointKey != "" && options.Group == "" {
+		return nil, fmt.Errorf("checkpoints are not supported on wildcard topic %s", topic)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &Subscription{
+		stream:  topic,
+		group:   options.Group,
+		handler: handler,
+		opts:    *options,
+		bus:     r,
+		pool:    newDispatcher(ctx, options.MaxInFlight, options.OrderingKey),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	if sub.group != "" {
 		// Consumer group subscription.
-		groupName := options.Group
-		consumerName := options.Name
-		if consumerName == "" {
-			consumerName = fmt.Sprintf("consumer_%s", topic)
+		sub.consumer = options.Name
+		if sub.consumer == "" {
+			sub.consumer = fmt.Sprintf("consumer_%s", topic)
 		}
 
-		// Create the consumer group; only new messages (after group creation) are processed.
-		if err := r.createConsumerGroup(ctx, topic, groupName); err != nil {
-			return err
+		// Create the consumer groups; unless a start ID is given only new messages
+		// (after group creation) are processed.
+		if err := r.startStreams(ctx, sub, wildcard); err != nil {
+			cancel()
+			return nil, err
+		}
+		if err := r.addSubscription(sub); err != nil {
+			cancel()
+			return nil, err
 		}
 
 		// Start reading messages using consumer group semantics (XREADGroup with ">")
-		go r.readMessagesConsumerGroup(ctx, topic, groupName, consumerName, handler)
-		log.Printf("Subscribed to events of type: %s with consumer group: %s and consumer: %s", topic, groupName, consumerName)
+		sub.goRead(func() { r.readMessagesConsumerGroup(sub) })
+		// Redeliver messages whose handler failed or whose consumer died.
+		sub.goRead(func() { r.reclaimPending(sub) })
+		log.Printf("Subscribed to events of type: %s with consumer group: %s and consumer: %s", sub.stream, sub.group, sub.consumer)
 	} else {
-		// Non-consumer group subscription using XREAD.
-		// Always use "$" to only get new messages.
-		go r.readMessagesXRead(ctx, topic, handler)
+		if options.CheckpointKey != "" {
+			sub.checkpoint = newCheckpoint(r.client, options.CheckpointKey)
+		}
+		if err := r.startStreams(ctx, sub, wildcard); err != nil {
+			cancel()
+			return nil, err
+		}
+		if err := r.addSubscription(sub); err != nil {
+			cancel()
+			return nil, err
+		}
+
+		// Non-consumer group subscription using XREAD, continuing after the last read ID.
+		sub.goRead(func() { r.readMessagesXRead(sub) })
 		log.Printf("Subscribed to events of type: %s using XREAD", topic)
 	}
-	return nil
+	if wildcard {
+		sub.goRead(func() { r.refreshStreams(sub) })
+	}
+	return sub, nil
+}
+
+// startStreams sets up the streams a new subscription reads: the topic itself, or
+// the streams currently matching a wildcard topic.
+func (r *RedisStream) startStreams(ctx context.Context, sub *Subscription, wildcard bool) error {
+	if !wildcard {
+		return r.addStreams(ctx, sub, []string{sub.stream}, false)
+	}
+	streams, err := r.matchingStreams(ctx, sub.stream)
+	if err != nil {
+		return err
+	}
+	return r.addStreams(ctx, sub, streams, false)
 }
 
 // createConsumerGroup creates a consumer group for the stream.
 // It ignores the BUSYGROUP error if the group already exists.
-func (r *RedisStream) createConsumerGroup(ctx context.Context, stream, groupName string) error {
-	err := r.client.XGroupCreateMkStream(ctx, stream, groupName, "$").Err()
+func (r *RedisStream) createConsumerGroup(ctx context.Context, stream, groupName, start string) error {
+	err := r.client.XGroupCreateMkStream(ctx, stream, groupName, start).Err()
 	if err != nil {
 		if err.Error() == "BUSYGROUP Consumer Group name already exists" {
 			log.Printf("Redis Stream: consumer group %s already exists, continuing...", groupName)
@@ -134,102 +399,342 @@ func (r *RedisStream) createConsumerGroup(ctx context.Context, stream, groupName
 
 // readMessagesConsumerGroup continuously reads messages from the stream using XREADGroup
 // and invokes the handler. It uses ">" as the stream offset to fetch new messages.
-func (r *RedisStream) readMessagesConsumerGroup(ctx context.Context, stream, group, consumer string, handler func(ctx context.Context, event cloudevents.Event)) {
+func (r *RedisStream) readMessagesConsumerGroup(sub *Subscription) {
 	for {
 		select {
-		case <-ctx.Done():
-			log.Printf("Consumer group subscription: stopping message reading for stream %s, consumer %s", stream, consumer)
+		case <-sub.ctx.Done():
+			log.Printf("Consumer group subscription: stopping message reading for stream %s, consumer %s", sub.stream, sub.consumer)
 			return
 		default:
 		}
 
-		res, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
-			Group:    group,
-			Consumer: consumer,
-			Streams:  []string{stream, ">"},
-			Block:    0, // Block indefinitely until a message arrives.
+		streams := sub.readStreams()
+		if len(streams) == 0 {
+			// No stream matches the wildcard topic yet.
+			sleep(sub.ctx, readBlockTimeout)
+			continue
+		}
+		res, err := r.client.XReadGroup(sub.ctx, &redis.XReadGroupArgs{
+			Group:    sub.group,
+			Consumer: sub.consumer,
+			Streams:  streams,
+			Count:    int64(sub.opts.MaxInFlight), // Do not claim more than the handlers can take.
+			Block:    readBlockTimeout,
 		}).Result()
+		if errors.Is(err, redis.Nil) || sub.ctx.Err() != nil {
+			continue
+		}
 		if err != nil {
 			log.Printf("Consumer group subscription: error reading from stream: %v", err)
 			time.Sleep(errorSleepDuration)
 			continue
 		}
-		r.processMessages(ctx, res, stream, group, handler)
+		for _, s := range res {
+			// Messages read with ">" are delivered for the first time.
+			r.processMessages(sub, s.Stream, s.Messages, nil)
+		}
 	}
 }
 
-// readMessagesXRead continuously reads messages from the stream using XREAD
-// and invokes the handler using a fixed start offset of "$" to process only new messages.
-func (r *RedisStream) readMessagesXRead(ctx context.Context, stream string, handler func(ctx context.Context, event cloudevents.Event)) {
+// readMessagesXRead continuously reads messages from the streams using XREAD
+// and invokes the handler. Each read continues after the last ID returned by the
+// previous one, so messages added between two reads are not skipped.
+func (r *RedisStream) readMessagesXRead(sub *Subscription) {
 	for {
 		select {
-		case <-ctx.Done():
-			log.Printf("XREAD subscription: stopping message reading for stream %s", stream)
+		case <-sub.ctx.Done():
+			log.Printf("XREAD subscription: stopping message reading for stream %s", sub.stream)
 			return
 		default:
 		}
 
-		res, err := r.client.XRead(ctx, &redis.XReadArgs{
-			Streams: []string{stream, "$"},
-			Block:   0, // Block indefinitely until a message arrives.
+		streams := sub.readStreams()
+		if len(streams) == 0 {
+			// No stream matches the wildcard topic yet.
+			sleep(sub.ctx, readBlockTimeout)
+			continue
+		}
+		res, err := r.client.XRead(sub.ctx, &redis.XReadArgs{
+			Streams: streams,
+			Count:   int64(sub.opts.MaxInFlight),
+			Block:   readBlockTimeout,
 		}).Result()
+		if errors.Is(err, redis.Nil) || sub.ctx.Err() != nil {
+			continue
+		}
 		if err != nil {
 			log.Printf("XREAD subscription: error reading from stream: %v", err)
 			time.Sleep(errorSleepDuration)
 			continue
 		}
-		r.processMessagesXRead(ctx, res, stream, handler)
+		if !r.processMessagesXRead(sub, res) {
+			continue
+		}
+		for _, s := range res {
+			if n := len(s.Messages); n > 0 {
+				sub.advance(s.Stream, s.Messages[n-1].ID)
+			}
+		}
 	}
 }
 
-// processMessages processes each message from consumer group subscriptions,
-// calling the handler and acknowledging the message.
-func (r *RedisStream) processMessages(ctx context.Context, streams []redis.XStream, stream, group string, handler func(ctx context.Context, event cloudevents.Event)) {
-	for _, s := range streams {
-		for _, message := range s.Messages {
-			event, err := parseMessage(message)
-			if err != nil {
-				log.Printf("Consumer group subscription: error parsing message %v: %v", message.ID, err)
+// reclaimPending periodically claims pending entries of the group that have been idle
+// for at least the claim idle time and hands them to the handler again.
+func (r *RedisStream) reclaimPending(sub *Subscription) {
+	ctx := sub.ctx
+	ticker := time.NewTicker(r.cfg.ClaimInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, stream := range sub.Streams() {
+			if !r.reclaimStream(sub, stream) {
+				return
+			}
+		}
+	}
+}
+
+// reclaimStream claims the idle pending entries of one stream. It reports false
+// once the subscription stopped. Entries this consumer still handles, or has queued,
+// only look idle and are left alone, so their delivery counter is not bumped.
+func (r *RedisStream) reclaimStream(sub *Subscription, stream string) bool {
+	ctx := sub.ctx
+	start := "-"
+	for {
+		pending, err := r.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: stream,
+			Group:  sub.group,
+			Idle:   sub.opts.ClaimIdleTime,
+			Start:  start,
+			End:    "+",
+			Count:  claimBatchSize,
+		}).Result()
+		if ctx.Err() != nil {
+			return false
+		}
+		if err != nil {
+			log.Printf("Consumer group subscription: error listing pending messages on stream %s: %v", stream, err)
+			return true
+		}
+
+		ids := make([]string, 0, len(pending))
+		for _, p := range pending {
+			if p.Consumer == sub.consumer && sub.isHandling(stream, p.ID) {
 				continue
 			}
+			ids = append(ids, p.ID)
+		}
+		if len(ids) > 0 {
+			// XCLAIM checks the idle time again, so entries claimed by another consumer
+			// in the meantime are skipped.
+			messages, err := r.client.XClaim(ctx, &redis.XClaimArgs{
+				Stream:   stream,
+				Group:    sub.group,
+				Consumer: sub.consumer,
+				MinIdle:  sub.opts.ClaimIdleTime,
+				Messages: ids,
+			}).Result()
+			if ctx.Err() != nil {
+				return false
+			}
+			if err != nil {
+				log.Printf("Consumer group subscription: error claiming pending messages on stream %s: %v", stream, err)
+				return true
+			}
+			if len(messages) > 0 {
+				log.Printf("Consumer group subscription: consumer %s claimed %d pending messages on stream %s", sub.consumer, len(messages), stream)
+				r.processMessages(sub, stream, messages, r.deliveryCounts(ctx, sub, stream, messages))
+			}
+		}
+		if len(pending) < claimBatchSize {
+			return true
+		}
+		start = "(" + pending[len(pending)-1].ID
+	}
+}
+
+// deliveryCounts looks up the PEL delivery counter of claimed messages, which is
+// reported as ConsumeInfo.Attempt and checked against MaxDeliveries. Each message is
+// looked up by its exact ID, as the claimed IDs need not be contiguous in the PEL.
+func (r *RedisStream) deliveryCounts(ctx context.Context, sub *Subscription, stream string, messages []redis.XMessage) map[string]int64 {
+	cmds := make([]*redis.XPendingExtCmd, len(messages))
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, message := range messages {
+			cmds[i] = pipe.XPendingExt(ctx, &redis.XPendingExtArgs{
+				Stream: stream,
+				Group:  sub.group,
+				Start:  message.ID,
+				End:    message.ID,
+				Count:  1,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Consumer group subscription: error reading delivery counts on stream %s: %v", stream, err)
+		return nil
+	}
+
+	counts := make(map[string]int64, len(messages))
+	for _, cmd := range cmds {
+		for _, p := range cmd.Val() {
+			counts[p.ID] = p.RetryCount
+		}
+	}
+	return counts
+}
+
+// processMessages processes each message read from a stream by a consumer group subscription,
+// calling the handler and acknowledging the message once it succeeds.
+// deliveries holds the delivery counter of each message; messages missing from it
+// are being delivered for the first time.
+// Once the subscription stops, remaining messages are left pending for redelivery.
+func (r *RedisStream) processMessages(sub *Subscription, stream string, messages []redis.XMessage, deliveries map[string]int64) {
+	// Handlers and acknowledgements outlive the reading context so they can be drained.
+	ctx := context.Background()
+	maxDeliveries := int64(sub.opts.MaxDeliveries)
+
+	sub.startHandling(stream, messages)
+	for i, message := range messages {
+		attempt := deliveries[message.ID]
+		if attempt == 0 {
+			attempt = 1
+		}
+
+		// Replays of dead letters are handled only by the group that failed them.
+		if replayedFor(message, sub.group) {
+			if err := r.client.XAck(ctx, stream, sub.group, message.ID).Err(); err != nil {
+				log.Printf("Consumer group subscription: failed to acknowledge message %v: %v", message.ID, err)
+			}
+			sub.doneHandling(stream, message.ID)
+			continue
+		}
 
-			// Process the event concurrently.
-			// For high volume, consider limiting concurrency.
-			go handler(ctx, event)
+		event, err := parseMessage(message)
+		if err != nil {
+			// A malformed entry can never be handled, so do not keep redelivering it.
+			log.Printf("Consumer group subscription: error parsing message %v: %v", message.ID, err)
+			r.metrics.decodeError(stream, sub.group)
+			if maxDeliveries > 0 {
+				r.deadLetter(ctx, sub, stream, message, err, attempt)
+			} else {
+				r.ack(ctx, stream, sub.group, message.ID)
+			}
+			sub.doneHandling(stream, message.ID)
+			continue
+		}
 
-			if _, ackErr := r.client.XAck(ctx, stream, group, message.ID).Result(); ackErr != nil {
-				log.Printf("Consumer group subscription: failed to acknowledge message %v: %v", message.ID, ackErr)
+		// The consumer holding this message crashed or stalled too many times.
+		if maxDeliveries > 0 && attempt > maxDeliveries {
+			r.deadLetter(ctx, sub, stream, message, errMaxDeliveriesExceeded, attempt-1)
+			sub.doneHandling(stream, message.ID)
+			continue
+		}
+
+		// Process the event on the subscription's handler pool; this blocks while it is saturated.
+		info := ConsumeInfo{Topic: stream, Group: sub.group, Consumer: sub.consumer, Attempt: int(attempt)}
+		if !sub.dispatch(event, func() {
+			defer sub.doneHandling(stream, message.ID)
+			if err := invokeHandler(ctx, r.intercept(sub.handler, info), event); err != nil {
+				if maxDeliveries > 0 && attempt >= maxDeliveries {
+					r.deadLetter(ctx, sub, stream, message, err, attempt)
+					return
+				}
+				// Leave the message in the pending entries list for redelivery.
+				log.Printf("Consumer group subscription: handler failed for message %v: %v", message.ID, err)
+				return
 			}
+			r.ack(ctx, stream, sub.group, message.ID)
+		}) {
+			for _, rest := range messages[i:] {
+				sub.doneHandling(stream, rest.ID)
+			}
+			return
 		}
 	}
 }
 
+// ack acknowledges a consumer group message.
+func (r *RedisStream) ack(ctx context.Context, stream, group, id string) {
+	if _, err := r.client.XAck(ctx, stream, group, id).Result(); err != nil {
+		log.Printf("Consumer group subscription: failed to acknowledge message %v: %v", id, err)
+		return
+	}
+	r.metrics.ackedEvent(stream, group)
+}
+
+// intercept wraps a handler in the consume interceptors for one delivery.
+func (r *RedisStream) intercept(handler Handler, info ConsumeInfo) Handler {
+	return chainConsume(r.interceptors, info, handler)
+}
+
+// invokeHandler calls the handler, converting a panic into an error.
+func invokeHandler(ctx context.Context, handler Handler, event cloudevents.Event) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("handler panicked: %v", rec)
+		}
+	}()
+	return handler(ctx, event)
+}
+
 // processMessagesXRead processes each message from non-consumer group subscriptions
-// and calls the handler.
-func (r *RedisStream) processMessagesXRead(ctx context.Context, streams []redis.XStream, stream string, handler func(ctx context.Context, event cloudevents.Event)) {
+// and calls the handler. It reports false if the subscription stopped before all
+// messages were dispatched.
+func (r *RedisStream) processMessagesXRead(sub *Subscription, streams []redis.XStream) bool {
+	ctx := context.Background()
 	for _, s := range streams {
 		for _, message := range s.Messages {
+			id := message.ID
+			// Replays are meant for a consumer group; this subscription saw the original.
+			if replayedFor(message, "") {
+				if sub.checkpoint != nil {
+					sub.checkpoint.track(id)
+					sub.checkpoint.complete(id)
+				}
+				continue
+			}
 			event, err := parseMessage(message)
 			if err != nil {
-				log.Printf("XREAD subscription: error parsing message %v: %v", message.ID, err)
+				log.Printf("XREAD subscription: error parsing message %v: %v", id, err)
+				r.metrics.decodeError(s.Stream, "")
+				if sub.checkpoint != nil {
+					sub.checkpoint.track(id)
+					sub.checkpoint.complete(id)
+				}
 				continue
 			}
-			go handler(ctx, event)
+			if sub.checkpoint != nil {
+				sub.checkpoint.track(id)
+			}
+			info := ConsumeInfo{Topic: s.Stream, Attempt: 1}
+			if !sub.dispatch(event, func() {
+				if err := invokeHandler(ctx, r.intercept(sub.handler, info), event); err != nil {
+					log.Printf("XREAD subscription: handler failed for message %v: %v", id, err)
+				}
+				if sub.checkpoint != nil {
+					sub.checkpoint.complete(id)
+				}
+			}) {
+				return false
+			}
 		}
 	}
+	return true
 }
 
-// parseMessage unmarshals the event from the Redis stream message.
-func parseMessage(message redis.XMessage) (cloudevents.Event, error) {
-	eventData, ok := message.Values["event"].(string)
-	if !ok {
-		return cloudevents.Event{}, fmt.Errorf("invalid message format: missing 'event' field")
+// sleep waits for d or until ctx is cancelled.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
 	}
-
-	var event cloudevents.Event
-	if err := json.Unmarshal([]byte(eventData), &event); err != nil {
-		return cloudevents.Event{}, fmt.Errorf("failed to parse event data: %v", err)
-	}
-
-	return event, nil
 }
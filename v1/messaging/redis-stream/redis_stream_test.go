@@ -0,0 +1,246 @@
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStream(t *testing.T, cfg RedisStreamConfig, options ...Option) (*RedisStream, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	cfg.URL = mr.Addr()
+	rs, err := NewRedisStream(&cfg, options...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = rs.Close() })
+	return rs, mr
+}
+
+func newTestEvent(id string) cloudevents.Event {
+	event := cloudevents.NewEvent()
+	event.SetID(id)
+	event.SetType("test.event")
+	event.SetSource("redis_stream_test")
+	_ = event.SetData(cloudevents.ApplicationJSON, map[string]string{"id": id})
+	return event
+}
+
+func pendingCount(t *testing.T, rs *RedisStream, stream, group string) int64 {
+	t.Helper()
+
+	pending, err := rs.client.XPending(context.Background(), stream, group).Result()
+	require.NoError(t, err)
+	return pending.Count
+}
+
+func TestSubscribeXRead(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+
+	received := make(chan string, 1)
+	require.NoError(t, rs.Subscribe("orders", func(ctx context.Context, event cloudevents.Event) {
+		received <- event.ID()
+	}))
+
+	require.NoError(t, rs.Publish(context.Background(), "orders", newTestEvent("1")))
+	select {
+	case id := <-received:
+		assert.Equal(t, "1", id)
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not delivered")
+	}
+}
+
+func TestSubscribeAcksAfterHandler(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+
+	received := make(chan string, 1)
+	require.NoError(t, rs.Subscribe("orders", func(ctx context.Context, event cloudevents.Event) {
+		received <- event.ID()
+	}, messaging.WithConsumerGroup("billing")))
+	require.NoError(t, rs.Publish(context.Background(), "orders", newTestEvent("1")))
+
+	select {
+	case id := <-received:
+		assert.Equal(t, "1", id)
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	assert.Eventually(t, func() bool {
+		return pendingCount(t, rs, "orders", "billing") == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestFailedHandlerIsRedelivered(t *testing.T) {
+	reported := make(chan int, 3)
+	rs, _ := newTestStream(t, RedisStreamConfig{
+		ClaimIdleTime: 50 * time.Millisecond,
+		ClaimInterval: 20 * time.Millisecond,
+	}, WithConsumeInterceptors(func(ctx context.Context, event cloudevents.Event, info ConsumeInfo, next Handler) error {
+		reported <- info.Attempt
+		return next(ctx, event)
+	}))
+
+	var attempts atomic.Int32
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		switch attempts.Add(1) {
+		case 1:
+			return errors.New("temporary failure")
+		case 2:
+			panic("boom")
+		}
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	require.NoError(t, rs.Publish(context.Background(), "orders", newTestEvent("1")))
+
+	assert.Eventually(t, func() bool {
+		return attempts.Load() == 3 && pendingCount(t, rs, "orders", "billing") == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	// Reclaimed deliveries report their attempt even when deliveries are not limited.
+	assert.Equal(t, []int{1, 2, 3}, []int{<-reported, <-reported, <-reported})
+}
+
+func TestFailedHandlerStaysPending(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{ClaimIdleTime: time.Hour})
+
+	var attempts atomic.Int32
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		attempts.Add(1)
+		return errors.New("permanent failure")
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	require.NoError(t, rs.Publish(context.Background(), "orders", newTestEvent("1")))
+
+	assert.Eventually(t, func() bool {
+		return attempts.Load() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(1), pendingCount(t, rs, "orders", "billing"))
+}
+
+func TestUnsubscribeStopsDelivery(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+
+	received := make(chan string, 1)
+	sub, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		received <- event.ID()
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+	assert.Equal(t, "orders", sub.Topic())
+	assert.Equal(t, "billing", sub.Group())
+
+	require.NoError(t, sub.Drain(context.Background()))
+	require.NoError(t, rs.Publish(context.Background(), "orders", newTestEvent("1")))
+
+	select {
+	case id := <-received:
+		t.Fatalf("unexpected event %s after unsubscribe", id)
+	case <-time.After(300 * time.Millisecond):
+	}
+	assert.Equal(t, int64(0), pendingCount(t, rs, "orders", "billing"))
+}
+
+func TestShutdownWaitsForHandlers(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+
+	var finished atomic.Bool
+	started := make(chan struct{})
+	require.NoError(t, rs.Subscribe("orders", func(ctx context.Context, event cloudevents.Event) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		finished.Store(true)
+	}, messaging.WithConsumerGroup("billing")))
+	require.NoError(t, rs.Publish(context.Background(), "orders", newTestEvent("1")))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, rs.Shutdown(ctx))
+	assert.True(t, finished.Load())
+
+	assert.Error(t, rs.Publish(context.Background(), "orders", newTestEvent("2")))
+	assert.Error(t, rs.Subscribe("orders", func(ctx context.Context, event cloudevents.Event) {}))
+	assert.Error(t, rs.Close())
+}
+
+func TestShutdownReportsUnfinishedHandlers(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	require.NoError(t, rs.Subscribe("orders", func(ctx context.Context, event cloudevents.Event) {
+		close(started)
+		<-release
+	}, messaging.WithConsumerGroup("billing"), messaging.WithConsumerName("worker-1")))
+	require.NoError(t, rs.Publish(context.Background(), "orders", newTestEvent("1")))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := rs.Shutdown(ctx)
+
+	var drainErr *DrainError
+	require.ErrorAs(t, err, &drainErr)
+	assert.Equal(t, []UnfinishedHandlers{{Topic: "orders", Group: "billing", Consumer: "worker-1", InFlight: 1}}, drainErr.Unfinished)
+}
+
+func TestSlowHandlerIsNotReclaimedFromItself(t *testing.T) {
+	reported := make(chan int, 10)
+	rs, _ := newTestStream(t, RedisStreamConfig{
+		ClaimIdleTime: 20 * time.Millisecond,
+		ClaimInterval: 10 * time.Millisecond,
+	}, WithConsumeInterceptors(func(ctx context.Context, event cloudevents.Event, info ConsumeInfo, next Handler) error {
+		reported <- info.Attempt
+		return next(ctx, event)
+	}))
+
+	// With one handler at a time, the second message waits behind the slow first one.
+	var calls atomic.Int32
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		calls.Add(1)
+		time.Sleep(150 * time.Millisecond)
+		return nil
+	}, WithConsumerGroup("billing"), WithMaxInFlight(1))
+	require.NoError(t, err)
+	require.NoError(t, rs.Publish(context.Background(), "orders", newTestEvent("1")))
+	require.NoError(t, rs.Publish(context.Background(), "orders", newTestEvent("2")))
+
+	assert.Eventually(t, func() bool {
+		return pendingCount(t, rs, "orders", "billing") == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, []int{1, 1}, []int{<-reported, <-reported})
+}
+
+func TestDeliveryCountsOfScatteredMessages(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+	ctx := context.Background()
+	require.NoError(t, rs.client.XGroupCreateMkStream(ctx, "orders", "billing", "0").Err())
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		id, err := rs.client.XAdd(ctx, &redis.XAddArgs{Stream: "orders", Values: map[string]any{"n": i}}).Result()
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	// The consumer holds every entry but claims only the entries 0 and 2 again.
+	_, err := rs.client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "billing", Consumer: "b", Streams: []string{"orders", ">"}}).Result()
+	require.NoError(t, err)
+	claimed, err := rs.client.XClaim(ctx, &redis.XClaimArgs{Stream: "orders", Group: "billing", Consumer: "b", Messages: []string{ids[0], ids[2]}}).Result()
+	require.NoError(t, err)
+
+	sub := &Subscription{group: "billing", consumer: "b"}
+	assert.Equal(t, map[string]int64{ids[0]: 2, ids[2]: 2}, rs.deliveryCounts(ctx, sub, "orders", claimed))
+}
@@ -0,0 +1,252 @@
+package redisstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// defaultSchedulerKey is hash tagged, so that the set and the hash of the scheduled
+	// events can be updated in one transaction in Cluster mode.
+	defaultSchedulerKey      = "{ebrick:scheduled}"
+	defaultSchedulerInterval = time.Second
+	scheduleBatchSize        = 100
+)
+
+// ErrNotScheduled is returned by CancelScheduled when no event with the ID is waiting for delivery.
+var ErrNotScheduled = errors.New("event is not scheduled")
+
+var errSchedulerDisabled = errors.New("scheduled delivery requires schedulerEnabled")
+
+// deliverAtKey carries the delivery time of PublishAt through the publish interceptors.
+type deliverAtKey struct{}
+
+// deliverScript moves a due event from the scheduler keys into its stream.
+//
+// KEYS: scheduled set, scheduled events hash, stream
+// ARGV: event ID, current time in milliseconds, trimming arguments of XADD
+var deliverScript = redis.NewScript(`
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not score or tonumber(score) > tonumber(ARGV[2]) then
+	return 0
+end
+local payload = redis.call('HGET', KEYS[2], ARGV[1])
+if not payload then
+	return 0
+end
+local entry = cjson.decode(payload)
+if entry.topic ~= KEYS[3] then
+	-- Rescheduled to another topic since it was read.
+	return 0
+end
+local args = {}
+for i = 3, #ARGV do
+	args[#args + 1] = ARGV[i]
+end
+args[#args + 1] = '*'
+for _, value in ipairs(entry.values) do
+	args[#args + 1] = value
+end
+redis.call('XADD', KEYS[3], unpack(args))
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+return 1
+`)
+
+// scheduledEntry is the value stored in the scheduled events hash.
+type scheduledEntry struct {
+	Topic  string   `json:"topic"`
+	Values []string `json:"values"` // Stream entry fields and values, alternating
+}
+
+// PublishAt adds an event to topic at the given time. Until then the event is kept in a
+// sorted set (SchedulerKey) and a hash (SchedulerKey + ":events"), keyed by event ID.
+// The publish interceptors run when the event is scheduled, not when it is delivered.
+// Scheduling an event ID that is already scheduled replaces it; times in the past are
+// delivered on the next scheduler run. The scheduler must be enabled with
+// SchedulerEnabled.
+//
+// Due events are moved into their stream by a Lua script, so delivery is atomic. In
+// Cluster mode the script needs the scheduler keys and the topic in the same hash slot,
+// e.g. SchedulerKey "{orders}:scheduled" for topics such as "{orders}.created".
+func (r *RedisStream) PublishAt(ctx context.Context, topic string, event cloudevents.Event, at time.Time) error {
+	if !r.cfg.SchedulerEnabled {
+		return errSchedulerDisabled
+	}
+	return r.Publish(context.WithValue(ctx, deliverAtKey{}, at), topic, event)
+}
+
+// PublishAfter adds an event to topic once delay has passed. See PublishAt.
+func (r *RedisStream) PublishAfter(ctx context.Context, topic string, event cloudevents.Event, delay time.Duration) error {
+	return r.PublishAt(ctx, topic, event, time.Now().Add(delay))
+}
+
+// CancelScheduled removes a scheduled event before it is delivered. It returns
+// ErrNotScheduled if the event is unknown or was already delivered.
+func (r *RedisStream) CancelScheduled(ctx context.Context, id string) error {
+	if !r.cfg.SchedulerEnabled {
+		return errSchedulerDisabled
+	}
+	if r.isClosed() {
+		return errClosed
+	}
+
+	var removed *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.ZRem(ctx, r.cfg.SchedulerKey, id)
+		pipe.HDel(ctx, r.scheduledEventsKey(), id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel scheduled event: %w", err)
+	}
+	if removed.Val() == 0 {
+		return ErrNotScheduled
+	}
+	return nil
+}
+
+// scheduledEventsKey returns the name of the hash holding the scheduled events.
+func (r *RedisStream) scheduledEventsKey() string {
+	return r.cfg.SchedulerKey + ":events"
+}
+
+// schedule stores a serialized event until it is due.
+func (r *RedisStream) schedule(ctx context.Context, topic string, values map[string]interface{}, id string, at time.Time) error {
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	entry := scheduledEntry{Topic: topic, Values: make([]string, 0, 2*len(fields))}
+	for _, field := range fields {
+		entry.Values = append(entry.Values, field, fmt.Sprint(values[field]))
+	}
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, r.scheduledEventsKey(), id, payload)
+		pipe.ZAdd(ctx, r.cfg.SchedulerKey, redis.Z{Score: float64(at.UnixMilli()), Member: id})
+		return nil
+	})
+	if err != nil {
+		log.Printf("Redis Stream: failed to schedule event: %v", err)
+		return err
+	}
+	return nil
+}
+
+// startScheduler moves due scheduled events into their streams in the background until Shutdown.
+func (r *RedisStream) startScheduler() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.stopScheduler = cancel
+	r.scheduler.Add(1)
+	go func() {
+		defer r.scheduler.Done()
+
+		ticker := time.NewTicker(r.cfg.SchedulerInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if _, err := r.DeliverScheduled(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Redis Stream: scheduler: %v", err)
+			}
+		}
+	}()
+}
+
+// DeliverScheduled moves all due scheduled events into their streams and returns how
+// many were delivered. The bus calls it every SchedulerInterval; buses running
+// concurrently never deliver an event twice. Events that fail to be delivered are
+// retried on the next call.
+func (r *RedisStream) DeliverScheduled(ctx context.Context) (int, error) {
+	var delivered int
+	for {
+		now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		ids, err := r.client.ZRangeByScore(ctx, r.cfg.SchedulerKey, &redis.ZRangeBy{
+			Min: "-inf", Max: now, Count: scheduleBatchSize,
+		}).Result()
+		if err != nil {
+			return delivered, fmt.Errorf("failed to read scheduled events: %w", err)
+		}
+		if len(ids) == 0 {
+			return delivered, nil
+		}
+		payloads, err := r.client.HMGet(ctx, r.scheduledEventsKey(), ids...).Result()
+		if err != nil {
+			return delivered, fmt.Errorf("failed to read scheduled events: %w", err)
+		}
+
+		var errs []error
+		for i, id := range ids {
+			var entry scheduledEntry
+			payload, ok := payloads[i].(string)
+			if !ok || json.Unmarshal([]byte(payload), &entry) != nil || entry.Topic == "" {
+				// Scheduling writes both keys in one transaction, so this is not a pending write.
+				log.Printf("Redis Stream: dropping scheduled event %s without a valid payload", id)
+				if err := r.dropScheduled(ctx, id); err != nil {
+					errs = append(errs, err)
+				}
+				continue
+			}
+
+			keys := []string{r.cfg.SchedulerKey, r.scheduledEventsKey(), entry.Topic}
+			args := append([]interface{}{id, now}, r.trimArgs(entry.Topic)...)
+			n, err := deliverScript.Run(ctx, r.client, keys, args...).Int()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to deliver scheduled event %s to stream %s: %w", id, entry.Topic, err))
+				continue
+			}
+			delivered += n
+		}
+		// Failed events stay due; stop rather than read them again.
+		if len(errs) > 0 {
+			return delivered, errors.Join(errs...)
+		}
+		if len(ids) < scheduleBatchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// dropScheduled removes a scheduled event without delivering it.
+func (r *RedisStream) dropScheduled(ctx context.Context, id string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, r.cfg.SchedulerKey, id)
+		pipe.HDel(ctx, r.scheduledEventsKey(), id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to drop scheduled event %s: %w", id, err)
+	}
+	return nil
+}
+
+// trimArgs returns the XADD trimming arguments of the topic's retention policy.
+func (r *RedisStream) trimArgs(topic string) []interface{} {
+	args := &redis.XAddArgs{Stream: topic}
+	r.applyRetention(args)
+	switch {
+	case args.MaxLen > 0:
+		return []interface{}{"MAXLEN", "~", args.MaxLen}
+	case args.MinID != "":
+		return []interface{}{"MINID", "~", args.MinID}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package redisstream
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func streamEntries(t *testing.T, rs *RedisStream, stream string) []redis.XMessage {
+	t.Helper()
+
+	messages, err := rs.client.XRange(context.Background(), stream, "-", "+").Result()
+	require.NoError(t, err)
+	return messages
+}
+
+func TestPublishAfter(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{SchedulerEnabled: true, SchedulerInterval: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	start := time.Now()
+	require.NoError(t, rs.PublishAfter(ctx, "reminders", newTestEvent("1"), 200*time.Millisecond))
+	assert.Empty(t, streamEntries(t, rs, "reminders"))
+
+	require.Eventually(t, func() bool {
+		return len(streamEntries(t, rs, "reminders")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	event, err := parseMessage(streamEntries(t, rs, "reminders")[0])
+	require.NoError(t, err)
+	assert.Equal(t, "1", event.ID())
+	assert.Zero(t, rs.client.ZCard(ctx, rs.cfg.SchedulerKey).Val())
+	assert.Zero(t, rs.client.HLen(ctx, rs.scheduledEventsKey()).Val())
+}
+
+func TestDeliverScheduled(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{
+		SchedulerEnabled:  true,
+		SchedulerInterval: -1,
+		ContentMode:       ContentModeBinary,
+		Retention:         RetentionPolicy{MaxLen: 100},
+	})
+	ctx := context.Background()
+
+	require.NoError(t, rs.PublishAt(ctx, "reminders", newTestEvent("due"), time.Now().Add(-time.Second)))
+	require.NoError(t, rs.PublishAt(ctx, "reminders", newTestEvent("later"), time.Now().Add(time.Hour)))
+	// Scheduling an ID again replaces the earlier schedule.
+	require.NoError(t, rs.PublishAt(ctx, "reminders", newTestEvent("moved"), time.Now().Add(time.Hour)))
+	require.NoError(t, rs.PublishAt(ctx, "reminders", newTestEvent("moved"), time.Now().Add(-time.Second)))
+
+	n, err := rs.DeliverScheduled(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	var ids []string
+	for _, message := range streamEntries(t, rs, "reminders") {
+		event, err := parseMessage(message)
+		require.NoError(t, err)
+		assert.Equal(t, `{"id":"`+event.ID()+`"}`, string(event.Data()))
+		ids = append(ids, event.ID())
+	}
+	assert.ElementsMatch(t, []string{"due", "moved"}, ids)
+	assert.Equal(t, int64(1), rs.client.ZCard(ctx, rs.cfg.SchedulerKey).Val())
+}
+
+func TestCancelScheduled(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{SchedulerEnabled: true, SchedulerInterval: -1})
+	ctx := context.Background()
+
+	require.NoError(t, rs.PublishAfter(ctx, "reminders", newTestEvent("1"), -time.Second))
+	require.NoError(t, rs.CancelScheduled(ctx, "1"))
+	assert.ErrorIs(t, rs.CancelScheduled(ctx, "1"), ErrNotScheduled)
+
+	n, err := rs.DeliverScheduled(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Empty(t, streamEntries(t, rs, "reminders"))
+}
+
+func TestDeliverScheduledConcurrently(t *testing.T) {
+	rs, mr := newTestStream(t, RedisStreamConfig{SchedulerEnabled: true, SchedulerInterval: -1})
+	other, err := NewRedisStream(&RedisStreamConfig{URL: mr.Addr(), SchedulerEnabled: true, SchedulerInterval: -1})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = other.Close() })
+	ctx := context.Background()
+
+	const events = 250
+	for i := 0; i < events; i++ {
+		require.NoError(t, rs.PublishAt(ctx, "reminders", newTestEvent(strconv.Itoa(i)), time.Now()))
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		delivered int
+	)
+	for _, bus := range []*RedisStream{rs, other, rs, other} {
+		wg.Add(1)
+		go func(bus *RedisStream) {
+			defer wg.Done()
+			n, err := bus.DeliverScheduled(ctx)
+			assert.NoError(t, err)
+			mu.Lock()
+			delivered += n
+			mu.Unlock()
+		}(bus)
+	}
+	wg.Wait()
+
+	assert.Equal(t, events, delivered)
+	assert.Len(t, streamEntries(t, rs, "reminders"), events)
+}
+
+func TestSchedulerIsOptIn(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+	ctx := context.Background()
+
+	assert.Nil(t, rs.stopScheduler, "no scheduler runs unless enabled")
+	assert.ErrorIs(t, rs.PublishAfter(ctx, "reminders", newTestEvent("1"), time.Second), errSchedulerDisabled)
+	assert.ErrorIs(t, rs.CancelScheduled(ctx, "1"), errSchedulerDisabled)
+	assert.Zero(t, rs.client.Exists(ctx, defaultSchedulerKey).Val())
+}
+
+func TestDefaultSchedulerKeysShareSlot(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{SchedulerEnabled: true, SchedulerInterval: -1})
+
+	assert.Equal(t, "{ebrick:scheduled}", rs.cfg.SchedulerKey)
+	assert.Equal(t, "{ebrick:scheduled}:events", rs.scheduledEventsKey())
+}
@@ -0,0 +1,230 @@
+package redisstream
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Subscription is a handle to an active subscription created by SubscribeHandler.
+type Subscription struct {
+	stream   string // Topic the subscription was created with, possibly a wildcard
+	group    string
+	consumer string
+	handler  Handler
+	opts     SubscriptionOptions
+
+	bus        *RedisStream
+	pool       *dispatcher
+	checkpoint *checkpoint     // Position of an XREAD subscription, if persisted
+	ctx        context.Context // Cancelled when the subscription stops reading
+	cancel     context.CancelFunc
+	readers    sync.WaitGroup // Reading and reclaiming goroutines
+	handlers   sync.WaitGroup // In-flight handler invocations
+	inFlight   atomic.Int64
+
+	streamsMu sync.Mutex        // Protects streams and positions
+	streams   []string          // Streams being read, in the order they were added
+	positions map[string]string // Read position of each stream
+
+	handlingMu sync.Mutex         // Protects handling
+	handling   map[entry]struct{} // Consumer group entries read and not yet handled
+}
+
+// entry identifies a message of a stream.
+type entry struct {
+	stream string
+	id     string
+}
+
+// Topic returns the topic the subscription was created with, which may be a wildcard.
+func (s *Subscription) Topic() string {
+	return s.stream
+}
+
+// Streams returns the streams the subscription currently reads from.
+func (s *Subscription) Streams() []string {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	return append([]string(nil), s.streams...)
+}
+
+// Group returns the consumer group of the subscription, or "" for XREAD subscriptions.
+func (s *Subscription) Group() string {
+	return s.group
+}
+
+// InFlight returns the number of handler invocations currently running.
+func (s *Subscription) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// Unsubscribe stops reading new messages. Handlers already running are not waited for;
+// in consumer groups their messages are still acknowledged when they succeed.
+func (s *Subscription) Unsubscribe() error {
+	s.cancel()
+	s.bus.removeSubscription(s)
+	return nil
+}
+
+// Drain stops reading new messages and waits for in-flight handlers to finish.
+// If ctx expires first, a *DrainError describing the unfinished handlers is returned.
+func (s *Subscription) Drain(ctx context.Context) error {
+	_ = s.Unsubscribe()
+
+	// Readers must stop before waiting on the handlers so no new handler is started.
+	if !wait(ctx, &s.readers) || !wait(ctx, &s.handlers) {
+		return &DrainError{Unfinished: []UnfinishedHandlers{s.unfinished()}}
+	}
+	return nil
+}
+
+// dispatch runs fn as a tracked handler invocation for event. It blocks while the
+// subscription's handler pool is saturated and reports false if the subscription
+// stopped before fn could be scheduled.
+func (s *Subscription) dispatch(event cloudevents.Event, fn func()) bool {
+	if s.ctx.Err() != nil {
+		return false
+	}
+
+	s.handlers.Add(1)
+	s.inFlight.Add(1)
+	scheduled := s.pool.run(event, func() {
+		defer s.handlers.Done()
+		defer s.inFlight.Add(-1)
+		fn()
+	})
+	if !scheduled {
+		s.inFlight.Add(-1)
+		s.handlers.Done()
+	}
+	return scheduled
+}
+
+// startHandling records that this consumer is handling messages of a stream, or has
+// queued them for handling, so they are not reclaimed from itself.
+func (s *Subscription) startHandling(stream string, messages []redis.XMessage) {
+	s.handlingMu.Lock()
+	defer s.handlingMu.Unlock()
+	if s.handling == nil {
+		s.handling = make(map[entry]struct{})
+	}
+	for _, message := range messages {
+		s.handling[entry{stream: stream, id: message.ID}] = struct{}{}
+	}
+}
+
+// doneHandling records that this consumer no longer handles a message.
+func (s *Subscription) doneHandling(stream, id string) {
+	s.handlingMu.Lock()
+	defer s.handlingMu.Unlock()
+	delete(s.handling, entry{stream: stream, id: id})
+}
+
+// isHandling reports whether this consumer is handling a message or has queued it.
+func (s *Subscription) isHandling(stream, id string) bool {
+	s.handlingMu.Lock()
+	defer s.handlingMu.Unlock()
+	_, ok := s.handling[entry{stream: stream, id: id}]
+	return ok
+}
+
+// addStream starts reading stream from position.
+func (s *Subscription) addStream(stream, position string) {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	if s.positions == nil {
+		s.positions = make(map[string]string)
+	}
+	if _, ok := s.positions[stream]; !ok {
+		s.streams = append(s.streams, stream)
+	}
+	s.positions[stream] = position
+}
+
+func (s *Subscription) hasStream(stream string) bool {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	_, ok := s.positions[stream]
+	return ok
+}
+
+// readStreams returns the STREAMS arguments of XREAD and XREADGROUP: the stream
+// names followed by their positions.
+func (s *Subscription) readStreams() []string {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	args := make([]string, 0, 2*len(s.streams))
+	args = append(args, s.streams...)
+	for _, stream := range s.streams {
+		args = append(args, s.positions[stream])
+	}
+	return args
+}
+
+// advance moves the read position of an XREAD subscription past id.
+func (s *Subscription) advance(stream, id string) {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	s.positions[stream] = id
+}
+
+// goRead starts a tracked reading goroutine.
+func (s *Subscription) goRead(fn func()) {
+	s.readers.Add(1)
+	go func() {
+		defer s.readers.Done()
+		fn()
+	}()
+}
+
+func (s *Subscription) unfinished() UnfinishedHandlers {
+	return UnfinishedHandlers{Topic: s.stream, Group: s.group, Consumer: s.consumer, InFlight: s.InFlight()}
+}
+
+// UnfinishedHandlers describes handlers of a subscription that were still running
+// when a drain deadline expired.
+type UnfinishedHandlers struct {
+	Topic    string
+	Group    string
+	Consumer string
+	InFlight int64
+}
+
+// DrainError is returned by Drain and Shutdown when handlers did not finish in time.
+type DrainError struct {
+	Unfinished []UnfinishedHandlers
+}
+
+func (e *DrainError) Error() string {
+	parts := make([]string, 0, len(e.Unfinished))
+	for _, u := range e.Unfinished {
+		if u.Group != "" {
+			parts = append(parts, fmt.Sprintf("%s (group %s, consumer %s): %d", u.Topic, u.Group, u.Consumer, u.InFlight))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s: %d", u.Topic, u.InFlight))
+		}
+	}
+	return "handlers did not finish before the deadline: " + strings.Join(parts, ", ")
+}
+
+// wait blocks until wg is done or ctx expires and reports whether wg finished.
+func wait(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
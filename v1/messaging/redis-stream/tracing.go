@@ -0,0 +1,143 @@
+package redisstream
+
+import (
+	"context"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/ebrickdev/extensions/v1/messaging/redis-stream"
+
+var messagingSystem = semconv.MessagingSystemKey.String("redis")
+
+// WithTracerProvider sets the provider of the producer and consumer spans.
+// By default the global provider is used.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(opts *Options) {
+		opts.TracerProvider = provider
+	}
+}
+
+// WithPropagator sets how trace context is carried in events. By default the W3C
+// traceparent and tracestate headers are written to the CloudEvents distributed
+// tracing extension.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(opts *Options) {
+		opts.Propagator = propagator
+	}
+}
+
+// tracing emits producer and consumer spans and propagates trace context through
+// event extensions. It runs as the outermost interceptor, so the spans cover the
+// configured interceptors and their contexts carry the span.
+type tracing struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+func newTracing(opts Options) *tracing {
+	provider := opts.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	propagator := opts.Propagator
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+	return &tracing{tracer: provider.Tracer(tracerName), propagator: propagator}
+}
+
+// publish starts a producer span and injects its context into a copy of the event.
+func (t *tracing) publish(ctx context.Context, topic string, event cloudevents.Event, next PublishFunc) error {
+	ctx, span := t.tracer.Start(ctx, "publish "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			messagingSystem,
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingOperationName("publish"),
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingMessageID(event.ID()),
+			semconv.CloudeventsEventID(event.ID()),
+			semconv.CloudeventsEventSource(event.Source()),
+			semconv.CloudeventsEventType(event.Type()),
+		))
+	defer span.End()
+
+	// Events share their attributes between copies; do not modify the caller's event.
+	event = event.Clone()
+	t.propagator.Inject(ctx, eventCarrier{event: &event})
+
+	err := next(ctx, topic, event)
+	recordError(span, err)
+	return err
+}
+
+// consume extracts the producer's trace context from the event and runs the handler
+// in a consumer span that is its child.
+func (t *tracing) consume(ctx context.Context, event cloudevents.Event, info ConsumeInfo, next Handler) error {
+	ctx = t.propagator.Extract(ctx, eventCarrier{event: &event})
+	attrs := []attribute.KeyValue{
+		messagingSystem,
+		semconv.MessagingOperationTypeProcess,
+		semconv.MessagingOperationName("process"),
+		semconv.MessagingDestinationName(info.Topic),
+		semconv.MessagingMessageID(event.ID()),
+		semconv.CloudeventsEventID(event.ID()),
+		semconv.CloudeventsEventSource(event.Source()),
+		semconv.CloudeventsEventType(event.Type()),
+	}
+	if info.Group != "" {
+		attrs = append(attrs, semconv.MessagingConsumerGroupName(info.Group))
+	}
+	ctx, span := t.tracer.Start(ctx, "process "+info.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...))
+	defer span.End()
+
+	err := next(ctx, event)
+	recordError(span, err)
+	return err
+}
+
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// eventCarrier adapts the extensions of an event to a propagation.TextMapCarrier.
+type eventCarrier struct {
+	event *cloudevents.Event
+}
+
+func (c eventCarrier) Get(key string) string {
+	value, ok := c.event.Extensions()[key]
+	if !ok {
+		return ""
+	}
+	s, err := types.ToString(value)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+func (c eventCarrier) Set(key, value string) {
+	c.event.SetExtension(key, value)
+}
+
+func (c eventCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.event.Extensions()))
+	for key := range c.event.Extensions() {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,74 @@
+package redisstream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracePropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	rs, _ := newTestStream(t, RedisStreamConfig{}, WithTracerProvider(provider))
+
+	received := make(chan trace.SpanContext, 1)
+	_, err := rs.SubscribeHandler("orders", func(ctx context.Context, event cloudevents.Event) error {
+		received <- trace.SpanContextFromContext(ctx)
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	event := newTestEvent("1")
+	require.NoError(t, rs.Publish(ctx, "orders", event))
+	parent.End()
+	assert.NotContains(t, event.Extensions(), "traceparent", "the caller's event must not be modified")
+
+	var consumed trace.SpanContext
+	select {
+	case consumed = <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	assert.Equal(t, parent.SpanContext().TraceID(), consumed.TraceID())
+
+	require.Eventually(t, func() bool { return len(recorder.Ended()) == 3 }, time.Second, 10*time.Millisecond)
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	publish, process := spans["publish orders"], spans["process orders"]
+	require.NotNil(t, publish)
+	require.NotNil(t, process)
+	assert.Equal(t, trace.SpanKindProducer, publish.SpanKind())
+	assert.Equal(t, trace.SpanKindConsumer, process.SpanKind())
+	assert.Equal(t, parent.SpanContext().SpanID(), publish.Parent().SpanID())
+	assert.Equal(t, publish.SpanContext().SpanID(), process.Parent().SpanID())
+	assert.Equal(t, consumed.SpanID(), process.SpanContext().SpanID())
+
+	attrs := make(map[string]string)
+	for _, kv := range process.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	assert.Equal(t, "redis", attrs["messaging.system"])
+	assert.Equal(t, "orders", attrs["messaging.destination.name"])
+	assert.Equal(t, "billing", attrs["messaging.consumer.group.name"])
+	assert.Equal(t, "1", attrs["messaging.message.id"])
+}
+
+func TestEventCarrier(t *testing.T) {
+	event := newTestEvent("1")
+	carrier := eventCarrier{event: &event}
+
+	carrier.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", carrier.Get("traceparent"))
+	assert.Equal(t, "", carrier.Get("tracestate"))
+	assert.Equal(t, []string{"traceparent"}, carrier.Keys())
+}
@@ -0,0 +1,193 @@
+package redisstream
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultTopicRefreshInterval is how often wildcard subscriptions look for new streams.
+const defaultTopicRefreshInterval = 5 * time.Second
+
+// scanBatchSize is the COUNT hint of the SCAN calls discovering streams.
+const scanBatchSize = 100
+
+// isWildcard reports whether a topic contains a wildcard token. Topics are made of
+// tokens separated by '.'; '*' matches a single token and '>' matches one or more
+// trailing tokens, as in NATS.
+func isWildcard(topic string) bool {
+	for _, token := range strings.Split(topic, ".") {
+		if token == "*" || token == ">" {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePattern checks a wildcard topic.
+func validatePattern(pattern string) error {
+	tokens := strings.Split(pattern, ".")
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return fmt.Errorf("invalid topic %q: empty token", pattern)
+		case token == ">" && i != len(tokens)-1:
+			return fmt.Errorf("invalid topic %q: '>' must be the last token", pattern)
+		case token != "*" && token != ">" && strings.ContainsAny(token, "*>"):
+			return fmt.Errorf("invalid topic %q: wildcards must be whole tokens", pattern)
+		}
+	}
+	return nil
+}
+
+// matchTopic reports whether a stream name matches a wildcard topic. Dead-letter
+// streams only match patterns that end in the dead-letter suffix themselves.
+func matchTopic(pattern, stream string) bool {
+	if strings.HasSuffix(stream, DeadLetterSuffix) && !strings.HasSuffix(pattern, DeadLetterSuffix) {
+		return false
+	}
+
+	patterns := strings.Split(pattern, ".")
+	tokens := strings.Split(stream, ".")
+	for i, p := range patterns {
+		switch {
+		case p == ">":
+			return len(tokens) > i
+		case i >= len(tokens):
+			return false
+		case p != "*" && p != tokens[i]:
+			return false
+		}
+	}
+	return len(patterns) == len(tokens)
+}
+
+// scanPattern returns a SCAN MATCH glob selecting the candidates of a wildcard topic:
+// its literal prefix up to the first wildcard followed by '*'.
+func scanPattern(pattern string) string {
+	var prefix []string
+	for _, token := range strings.Split(pattern, ".") {
+		if token == "*" || token == ">" {
+			break
+		}
+		prefix = append(prefix, token)
+	}
+	glob := strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(strings.Join(prefix, "."))
+	if glob != "" {
+		glob += "."
+	}
+	return glob + "*"
+}
+
+// matchingStreams lists the streams matching a wildcard topic, in name order.
+func (r *RedisStream) matchingStreams(ctx context.Context, pattern string) ([]string, error) {
+	streams, err := r.scanStreams(ctx, scanPattern(pattern), func(stream string) bool {
+		return matchTopic(pattern, stream)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover streams matching %s: %w", pattern, err)
+	}
+	return streams, nil
+}
+
+// scanStreams lists the streams matching a SCAN glob that keep accepts, in name order.
+// In Cluster mode every master is scanned.
+func (r *RedisStream) scanStreams(ctx context.Context, glob string, keep func(stream string) bool) ([]string, error) {
+	var (
+		mu      sync.Mutex
+		streams []string
+	)
+	scan := func(ctx context.Context, client redis.UniversalClient) error {
+		iter := client.ScanType(ctx, 0, glob, scanBatchSize, "stream").Iterator()
+		for iter.Next(ctx) {
+			if keep(iter.Val()) {
+				mu.Lock()
+				streams = append(streams, iter.Val())
+				mu.Unlock()
+			}
+		}
+		return iter.Err()
+	}
+
+	var err error
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return scan(ctx, client)
+		})
+	} else {
+		err = scan(ctx, r.client)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(streams)
+	return streams, nil
+}
+
+// addStreams starts reading streams that the subscription does not read yet.
+// Streams matched when the subscription starts begin at its start ID. Streams discovered
+// later were created after the subscription started, so they are read from the beginning.
+func (r *RedisStream) addStreams(ctx context.Context, sub *Subscription, streams []string, discovered bool) error {
+	for _, stream := range streams {
+		if sub.hasStream(stream) {
+			continue
+		}
+
+		var position string
+		if sub.group != "" {
+			start := sub.opts.StartID
+			if discovered {
+				start = StartBeginning
+			} else if start == "" {
+				start = StartNew
+			}
+			if err := r.createConsumerGroup(ctx, stream, sub.group, start); err != nil {
+				return err
+			}
+			position = ">"
+		} else if discovered {
+			position = StartBeginning
+		} else {
+			start, err := r.resolveStartID(ctx, sub, stream)
+			if err != nil {
+				return err
+			}
+			position = start
+		}
+
+		sub.addStream(stream, position)
+		r.addTopic(stream)
+		if discovered {
+			log.Printf("Redis Stream: subscription on %s now reads stream %s", sub.stream, stream)
+		}
+	}
+	return nil
+}
+
+// refreshStreams periodically adds the streams created after a wildcard subscription started.
+func (r *RedisStream) refreshStreams(sub *Subscription) {
+	ticker := time.NewTicker(r.cfg.TopicRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sub.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		streams, err := r.matchingStreams(sub.ctx, sub.stream)
+		if err == nil {
+			err = r.addStreams(sub.ctx, sub, streams, true)
+		}
+		if err != nil && sub.ctx.Err() == nil {
+			log.Printf("Redis Stream: %v", err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package redisstream
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		pattern, stream string
+		match           bool
+	}{
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders.created.eu", false},
+		{"orders.*", "orders", false},
+		{"orders.>", "orders.created.eu", true},
+		{"orders.>", "orders", false},
+		{"*.created", "orders.created", true},
+		{">", "orders.created", true},
+		{"orders.*", "orders.created.dlq", false},
+		{"orders.>", "orders.created.dlq", false},
+		{"orders.*.dlq", "orders.created.dlq", true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.match, matchTopic(c.pattern, c.stream), "%s ~ %s", c.pattern, c.stream)
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	assert.NoError(t, validatePattern("orders.*.eu"))
+	assert.NoError(t, validatePattern("orders.>"))
+	assert.Error(t, validatePattern("orders.>.eu"))
+	assert.Error(t, validatePattern("orders..*"))
+	assert.Error(t, validatePattern("orders.cre*"))
+}
+
+func TestScanPattern(t *testing.T) {
+	assert.Equal(t, "orders.*", scanPattern("orders.*.eu"))
+	assert.Equal(t, "*", scanPattern("*.created"))
+	assert.Equal(t, "{orders}.*", scanPattern("{orders}.>"))
+	assert.Equal(t, `a\[1\].*`, scanPattern("a[1].*"))
+}
+
+func TestWildcardSubscription(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{TopicRefreshInterval: 50 * time.Millisecond})
+	ctx := context.Background()
+	require.NoError(t, rs.Publish(ctx, "orders.created", newTestEvent("old")))
+
+	received := make(chan string, 10)
+	sub, err := rs.SubscribeHandler("orders.*", func(ctx context.Context, event cloudevents.Event) error {
+		received <- event.ID()
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orders.created"}, sub.Streams())
+
+	// Existing streams start at the end; streams created later are read from the beginning.
+	require.NoError(t, rs.Publish(ctx, "orders.created", newTestEvent("1")))
+	require.NoError(t, rs.Publish(ctx, "orders.shipped", newTestEvent("2")))
+	require.NoError(t, rs.Publish(ctx, "payments.created", newTestEvent("3")))
+
+	ids := receiveIDs(t, received, 2)
+	sort.Strings(ids)
+	assert.Equal(t, []string{"1", "2"}, ids)
+	assert.Equal(t, []string{"orders.created", "orders.shipped"}, sub.Streams())
+}
+
+func TestWildcardConsumerGroup(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{TopicRefreshInterval: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	received := make(chan string, 10)
+	_, err := rs.SubscribeHandler("orders.>", func(ctx context.Context, event cloudevents.Event) error {
+		received <- event.ID()
+		return nil
+	}, WithConsumerGroup("billing"))
+	require.NoError(t, err)
+
+	require.NoError(t, rs.Publish(ctx, "orders.created", newTestEvent("1")))
+	require.NoError(t, rs.Publish(ctx, "orders.eu.shipped", newTestEvent("2")))
+
+	ids := receiveIDs(t, received, 2)
+	sort.Strings(ids)
+	assert.Equal(t, []string{"1", "2"}, ids)
+	assert.Eventually(t, func() bool {
+		return pendingCount(t, rs, "orders.created", "billing") == 0 &&
+			pendingCount(t, rs, "orders.eu.shipped", "billing") == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWildcardRejections(t *testing.T) {
+	rs, _ := newTestStream(t, RedisStreamConfig{})
+	handler := func(ctx context.Context, event cloudevents.Event) error { return nil }
+
+	assert.Error(t, rs.Publish(context.Background(), "orders.*", newTestEvent("1")))
+	_, err := rs.SubscribeHandler("orders.>.eu", handler)
+	assert.Error(t, err)
+	_, err = rs.SubscribeHandler("orders.*", handler, WithCheckpointKey("orders-checkpoint"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// Violation is a single failed JSON Schema constraint.
+type Violation struct {
+	Path    string // JSON Pointer of the offending value within the event data
+	Keyword string // JSON Pointer of the failed keyword within the schema
+	Message string
+}
+
+// ValidationError is returned for events that do not match their schema. Either Err
+// explains why the event could not be validated at all, or Violations lists the
+// constraints the data fails.
+type ValidationError struct {
+	EventID    string
+	Type       string
+	Source     string
+	DataSchema string
+	Violations []Violation
+	Err        error
+}
+
+func newValidationError(event cloudevents.Event, err error) *ValidationError {
+	return &ValidationError{
+		EventID:    event.ID(),
+		Type:       event.Type(),
+		Source:     event.Source(),
+		DataSchema: event.DataSchema(),
+		Err:        err,
+	}
+}
+
+func (e *ValidationError) Error() string {
+	prefix := fmt.Sprintf("event %s of type %s", e.EventID, e.Type)
+	if e.DataSchema != "" {
+		prefix += " does not match schema " + e.DataSchema
+	} else {
+		prefix += " is invalid"
+	}
+	if e.Err != nil {
+		return prefix + ": " + e.Err.Error()
+	}
+
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		path := v.Path
+		if path == "" {
+			path = "/"
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", path, v.Message))
+	}
+	return prefix + ": " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
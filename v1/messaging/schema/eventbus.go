@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"context"
+	"log"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+)
+
+// ValidatingEventBus wraps an event bus and validates events against a Registry
+// before publishing them. Subscriptions are passed through unchanged.
+type ValidatingEventBus struct {
+	messaging.EventBus
+	registry *Registry
+}
+
+// NewValidatingEventBus wraps bus so that every published event is validated with
+// registry. It can replace messaging.DefaultEventBus.
+func NewValidatingEventBus(bus messaging.EventBus, registry *Registry) *ValidatingEventBus {
+	return &ValidatingEventBus{EventBus: bus, registry: registry}
+}
+
+// Publish validates the event and publishes it on the wrapped bus. Invalid events
+// are not published; a *ValidationError describing the failure is returned.
+func (b *ValidatingEventBus) Publish(ctx context.Context, topic string, event cloudevents.Event) error {
+	if err := b.registry.Validate(event); err != nil {
+		log.Printf("Schema: rejected event for topic %s: %v", topic, err)
+		return err
+	}
+	return b.EventBus.Publish(ctx, topic, event)
+}
+
+// Unwrap returns the wrapped event bus, e.g. to reach backend specific methods
+// such as SubscribeHandler.
+func (b *ValidatingEventBus) Unwrap() messaging.EventBus {
+	return b.EventBus
+}
@@ -0,0 +1,163 @@
+// Package schema adds JSON Schema validation and typed handlers to the event buses.
+//
+// A Registry holds JSON Schemas keyed by the URI that events name in their dataschema
+// attribute. ValidatingEventBus checks events against the registry before publishing
+// them, and Typed and SubscribeTyped decode inbound event data into Go values, handing
+// events that cannot be decoded or validated to an ErrorHandler.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+var (
+	// ErrUnknownSchema is wrapped by validation errors of events naming a dataschema
+	// that is not registered.
+	ErrUnknownSchema = errors.New("schema is not registered")
+	// ErrMissingSchema is wrapped by validation errors of events without a dataschema
+	// when the registry requires one.
+	ErrMissingSchema = errors.New("event has no dataschema")
+	// ErrUnsupportedContentType is wrapped by validation errors of events whose data
+	// is not JSON.
+	ErrUnsupportedContentType = errors.New("data content type is not JSON")
+)
+
+// Registry holds compiled JSON Schemas keyed by dataschema URI. It is safe for
+// concurrent use.
+type Registry struct {
+	opts RegistryOptions
+
+	mu       sync.RWMutex
+	compiler *jsonschema.Compiler
+	schemas  map[string]*jsonschema.Schema
+}
+
+// RegistryOptions configures a Registry.
+type RegistryOptions struct {
+	RequireSchema bool // Reject events without a dataschema attribute
+}
+
+// RegistryOption defines a function to set registry options.
+type RegistryOption func(opts *RegistryOptions)
+
+// WithRequireSchema rejects events that do not name a dataschema. By default they are
+// not validated.
+func WithRequireSchema() RegistryOption {
+	return func(opts *RegistryOptions) {
+		opts.RequireSchema = true
+	}
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry(options ...RegistryOption) *Registry {
+	var opts RegistryOptions
+	for _, o := range options {
+		o(&opts)
+	}
+	return &Registry{
+		opts:     opts,
+		compiler: jsonschema.NewCompiler(),
+		schemas:  make(map[string]*jsonschema.Schema),
+	}
+}
+
+// Register compiles a JSON Schema document and registers it under uri, the value
+// events carry in their dataschema attribute. Schemas registered earlier can be
+// referenced with $ref.
+func (r *Registry) Register(uri string, document []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.compiler.AddResource(uri, bytes.NewReader(document)); err != nil {
+		return fmt.Errorf("invalid schema %s: %w", uri, err)
+	}
+	compiled, err := r.compiler.Compile(uri)
+	if err != nil {
+		return fmt.Errorf("invalid schema %s: %w", uri, err)
+	}
+	r.schemas[uri] = compiled
+	return nil
+}
+
+// RegisterFile registers the JSON Schema stored at path under uri.
+func (r *Registry) RegisterFile(uri, path string) error {
+	document, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read schema %s: %w", uri, err)
+	}
+	return r.Register(uri, document)
+}
+
+func (r *Registry) lookup(uri string) (*jsonschema.Schema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	compiled, ok := r.schemas[uri]
+	return compiled, ok
+}
+
+// Validate checks the data of an event against the schema named by its dataschema
+// attribute. Failures are reported as a *ValidationError.
+func (r *Registry) Validate(event cloudevents.Event) error {
+	uri := event.DataSchema()
+	if uri == "" {
+		if r.opts.RequireSchema {
+			return newValidationError(event, ErrMissingSchema)
+		}
+		return nil
+	}
+
+	compiled, ok := r.lookup(uri)
+	if !ok {
+		return newValidationError(event, ErrUnknownSchema)
+	}
+	if !isJSON(event.DataContentType()) {
+		return newValidationError(event, fmt.Errorf("%w: %s", ErrUnsupportedContentType, event.DataContentType()))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(event.Data()))
+	decoder.UseNumber()
+	var instance any
+	if err := decoder.Decode(&instance); err != nil {
+		return newValidationError(event, fmt.Errorf("invalid JSON data: %w", err))
+	}
+
+	if err := compiled.Validate(instance); err != nil {
+		var schemaErr *jsonschema.ValidationError
+		if !errors.As(err, &schemaErr) {
+			return newValidationError(event, err)
+		}
+		verr := newValidationError(event, nil)
+		verr.Violations = violations(schemaErr, nil)
+		return verr
+	}
+	return nil
+}
+
+// isJSON reports whether a data content type carries JSON. Events without a content
+// type default to application/json.
+func isJSON(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	return mediaType == "" || mediaType == "application/json" || mediaType == "text/json" ||
+		strings.HasSuffix(mediaType, "+json")
+}
+
+// violations flattens the leaves of a JSON Schema error tree.
+func violations(err *jsonschema.ValidationError, out []Violation) []Violation {
+	if len(err.Causes) == 0 {
+		return append(out, Violation{Path: err.InstanceLocation, Keyword: err.KeywordLocation, Message: err.Message})
+	}
+	for _, cause := range err.Causes {
+		out = violations(cause, out)
+	}
+	return out
+}
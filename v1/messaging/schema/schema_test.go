@@ -0,0 +1,159 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const orderSchemaURI = "https://schemas.example.com/order.json"
+
+const orderSchema = `{
+	"type": "object",
+	"required": ["id", "amount"],
+	"properties": {
+		"id": {"type": "string"},
+		"amount": {"type": "number", "minimum": 0}
+	}
+}`
+
+type order struct {
+	ID     string  `json:"id"`
+	Amount float64 `json:"amount"`
+}
+
+func newTestRegistry(t *testing.T, options ...RegistryOption) *Registry {
+	t.Helper()
+
+	registry := NewRegistry(options...)
+	require.NoError(t, registry.Register(orderSchemaURI, []byte(orderSchema)))
+	return registry
+}
+
+func newOrderEvent(data any) cloudevents.Event {
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetType("order.created")
+	event.SetSource("schema_test")
+	event.SetDataSchema(orderSchemaURI)
+	_ = event.SetData(cloudevents.ApplicationJSON, data)
+	return event
+}
+
+// recordingBus records published events.
+type recordingBus struct {
+	messaging.EventBus
+	published []cloudevents.Event
+}
+
+func (b *recordingBus) Publish(ctx context.Context, topic string, event cloudevents.Event) error {
+	b.published = append(b.published, event)
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	assert.NoError(t, registry.Validate(newOrderEvent(order{ID: "a", Amount: 10})))
+
+	err := registry.Validate(newOrderEvent(map[string]any{"id": 1, "amount": -5}))
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, orderSchemaURI, verr.DataSchema)
+	paths := make([]string, 0, len(verr.Violations))
+	for _, v := range verr.Violations {
+		paths = append(paths, v.Path)
+	}
+	assert.ElementsMatch(t, []string{"/id", "/amount"}, paths)
+
+	unknown := newOrderEvent(order{ID: "a"})
+	unknown.SetDataSchema("https://schemas.example.com/unknown.json")
+	assert.ErrorIs(t, registry.Validate(unknown), ErrUnknownSchema)
+}
+
+func TestValidateWithoutDataSchema(t *testing.T) {
+	event := newOrderEvent(order{ID: "a"})
+	event.SetDataSchema("")
+
+	assert.NoError(t, newTestRegistry(t).Validate(event))
+	assert.ErrorIs(t, newTestRegistry(t, WithRequireSchema()).Validate(event), ErrMissingSchema)
+}
+
+func TestRegisterRejectsInvalidSchema(t *testing.T) {
+	assert.Error(t, NewRegistry().Register("https://schemas.example.com/bad.json", []byte(`{"type": 5}`)))
+}
+
+func TestValidatingEventBus(t *testing.T) {
+	next := &recordingBus{}
+	bus := NewValidatingEventBus(next, newTestRegistry(t))
+
+	require.NoError(t, bus.Publish(context.Background(), "orders", newOrderEvent(order{ID: "a", Amount: 1})))
+	var verr *ValidationError
+	assert.ErrorAs(t, bus.Publish(context.Background(), "orders", newOrderEvent(map[string]any{"id": "b"})), &verr)
+	assert.Len(t, next.published, 1)
+}
+
+func TestTyped(t *testing.T) {
+	var got order
+	handler := Typed(func(ctx context.Context, event cloudevents.Event, data order) error {
+		got = data
+		return nil
+	})
+	require.NoError(t, handler(context.Background(), newOrderEvent(order{ID: "a", Amount: 2})))
+	assert.Equal(t, order{ID: "a", Amount: 2}, got)
+
+	failing := Typed(func(ctx context.Context, event cloudevents.Event, data order) error {
+		return errors.New("boom")
+	})
+	assert.EqualError(t, failing(context.Background(), newOrderEvent(order{ID: "a"})), "boom")
+}
+
+func TestTypedRoutesInvalidEventsToErrorHandler(t *testing.T) {
+	var errs []error
+	called := false
+	handler := Typed(func(ctx context.Context, event cloudevents.Event, data order) error {
+		called = true
+		return nil
+	}, WithValidation(newTestRegistry(t)), WithErrorHandler(func(ctx context.Context, event cloudevents.Event, err error) {
+		errs = append(errs, err)
+	}))
+
+	undecodable := newOrderEvent(nil)
+	undecodable.SetDataSchema("")
+	_ = undecodable.SetData(cloudevents.ApplicationJSON, []byte(`{"id":`))
+	assert.NoError(t, handler(context.Background(), undecodable))
+	assert.NoError(t, handler(context.Background(), newOrderEvent(map[string]any{"amount": 1})))
+
+	assert.False(t, called)
+	require.Len(t, errs, 2)
+	var decodeErr *DecodeError
+	assert.ErrorAs(t, errs[0], &decodeErr)
+	var verr *ValidationError
+	assert.ErrorAs(t, errs[1], &verr)
+}
+
+func TestSubscribeTyped(t *testing.T) {
+	bus, err := messaging.NewMemoryEventBus()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bus.Close() })
+
+	received := make(chan order, 1)
+	require.NoError(t, SubscribeTyped(bus, "orders", func(ctx context.Context, event cloudevents.Event, data order) error {
+		received <- data
+		return nil
+	}))
+	require.NoError(t, bus.Publish(context.Background(), "orders", newOrderEvent(order{ID: "a", Amount: 3})))
+
+	select {
+	case data := <-received:
+		assert.Equal(t, order{ID: "a", Amount: 3}, data)
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not delivered")
+	}
+}
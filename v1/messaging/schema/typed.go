@@ -0,0 +1,115 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/ebrickdev/ebrick/messaging"
+)
+
+// TypedHandler handles the event data decoded into a T. The event is passed along
+// for its metadata.
+type TypedHandler[T any] func(ctx context.Context, event cloudevents.Event, data T) error
+
+// ErrorHandler receives inbound events that could not be decoded or validated.
+// Such events are never redelivered, so the handler is the place to park them,
+// e.g. by publishing them to a dead-letter topic.
+type ErrorHandler func(ctx context.Context, event cloudevents.Event, err error)
+
+// Options configures typed handlers.
+type Options struct {
+	ErrorHandler  ErrorHandler                   // Receives undecodable events, defaults to logging them
+	Registry      *Registry                      // Validates inbound events before decoding, if set
+	Subscriptions []messaging.SubscriptionOption // Passed to EventBus.Subscribe by SubscribeTyped
+}
+
+// Option defines a function to set typed handler options.
+type Option func(opts *Options)
+
+// WithErrorHandler sets the handler of events that cannot be decoded or validated.
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(opts *Options) {
+		opts.ErrorHandler = handler
+	}
+}
+
+// WithValidation validates inbound events with registry before decoding them.
+func WithValidation(registry *Registry) Option {
+	return func(opts *Options) {
+		opts.Registry = registry
+	}
+}
+
+// WithSubscriptionOptions sets the options SubscribeTyped passes to EventBus.Subscribe.
+func WithSubscriptionOptions(options ...messaging.SubscriptionOption) Option {
+	return func(opts *Options) {
+		opts.Subscriptions = append(opts.Subscriptions, options...)
+	}
+}
+
+// logError is the default ErrorHandler.
+func logError(ctx context.Context, event cloudevents.Event, err error) {
+	log.Printf("Schema: dropping event %s of type %s: %v", event.ID(), event.Type(), err)
+}
+
+// Typed returns an event handler that decodes the event data into a T according to
+// its data content type and calls handler. Events that fail validation or decoding are
+// passed to the error handler and reported as handled, so they are not redelivered;
+// errors of handler are returned unchanged. The result can be passed to the
+// SubscribeHandler methods of the event buses.
+func Typed[T any](handler TypedHandler[T], options ...Option) func(ctx context.Context, event cloudevents.Event) error {
+	opts := Options{ErrorHandler: logError}
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return func(ctx context.Context, event cloudevents.Event) error {
+		if opts.Registry != nil {
+			if err := opts.Registry.Validate(event); err != nil {
+				opts.ErrorHandler(ctx, event, err)
+				return nil
+			}
+		}
+
+		var data T
+		if err := event.DataAs(&data); err != nil {
+			opts.ErrorHandler(ctx, event, &DecodeError{EventID: event.ID(), Type: event.Type(), Err: err})
+			return nil
+		}
+		return handler(ctx, event, data)
+	}
+}
+
+// SubscribeTyped subscribes handler to topic on bus, decoding event data into a T.
+// EventBus.Subscribe cannot report failures, so handler errors are logged; use Typed
+// with a bus's SubscribeHandler to have failed events redelivered.
+func SubscribeTyped[T any](bus messaging.EventBus, topic string, handler TypedHandler[T], options ...Option) error {
+	var opts Options
+	for _, o := range options {
+		o(&opts)
+	}
+
+	typed := Typed(handler, options...)
+	return bus.Subscribe(topic, func(ctx context.Context, event cloudevents.Event) {
+		if err := typed(ctx, event); err != nil {
+			log.Printf("Schema: handler failed for event %s on topic %s: %v", event.ID(), topic, err)
+		}
+	}, opts.Subscriptions...)
+}
+
+// DecodeError is passed to the ErrorHandler for events whose data cannot be decoded.
+type DecodeError struct {
+	EventID string
+	Type    string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode data of event %s of type %s: %v", e.EventID, e.Type, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
@@ -4,6 +4,7 @@ go 1.22.5
 
 require (
 	github.com/ebrickdev/ebrick v0.11.0
+	github.com/gin-gonic/gin v1.10.0
 	golang.org/x/time v0.8.0
 )
 
@@ -14,7 +15,6 @@ require (
 	github.com/cloudwego/iasm v0.2.0 // indirect
 	github.com/gabriel-vasile/mimetype v1.4.3 // indirect
 	github.com/gin-contrib/sse v0.1.0 // indirect
-	github.com/gin-gonic/gin v1.10.0 // indirect
 	github.com/go-playground/locales v0.14.1 // indirect
 	github.com/go-playground/universal-translator v0.18.1 // indirect
 	github.com/go-playground/validator/v10 v10.20.0 // indirect